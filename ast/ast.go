@@ -3,6 +3,7 @@ package ast
 import (
 	"Interp/token"
 	"bytes"
+	"math/big"
 	"strings"
 )
 
@@ -34,22 +35,62 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
-// String 返回当前program中所有语句的字符串，实际工作委托给statement的String方法
+// String 返回当前program中所有语句的字符串，每条语句一行，实际工作委托给statement的String方法。
+// 输出可以重新解析为同样的语法树
 func (p *Program) String() string {
 	var out bytes.Buffer //声明一个bytes.Buffer
-	//遍历p.Statements，将每个语句的字符串拼接起来
-	for _, s := range p.Statements {
+	writeStatements(&out, p.Statements, "\n")
+	return out.String()
+}
+
+// writeStatements 用sep连接语句。表达式语句后面补上分号，否则下一条语句开头的(或[会被解析为调用或索引
+func writeStatements(out *bytes.Buffer, statements []Statement, sep string) {
+	for i, s := range statements {
+		if i > 0 {
+			out.WriteString(sep)
+		}
 		out.WriteString(s.String())
+		if _, ok := s.(*ExpressionStatement); ok && i < len(statements)-1 {
+			out.WriteString(";")
+		}
 	}
+}
 
+// quoteString 按照词法分析器支持的转义输出字符串字面量，其余字符原样保留
+func quoteString(value string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
 	return out.String()
 }
 
+// Pattern 绑定模式，可以出现在let语句和函数参数中。标识符是最简单的模式
+type Pattern interface {
+	Expression
+	patternNode()
+}
+
 // LetStatement let语句
 type LetStatement struct {
-	Token token.Token // token.LET 标识符
-	Name  *Identifier // 标识符
-	Value Expression  // 表达式
+	Token   token.Token // token.LET 标识符
+	Name    *Identifier // 标识符
+	Pattern Pattern     // 解构模式，不为nil时Name为nil
+	Value   Expression  // 表达式
 }
 
 // statementNode 表示这是一个语句，实现了Statement接口
@@ -67,8 +108,12 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer //声明一个bytes.Buffer
 	//拼接字符串
 	out.WriteString(ls.TokenLiteral() + " ") //let
-	out.WriteString(ls.Name.String())        //标识符
-	out.WriteString(" = ")                   // =
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String()) //解构模式
+	} else {
+		out.WriteString(ls.Name.String()) //标识符
+	}
+	out.WriteString(" = ") // =
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String()) //表达式
 	}
@@ -80,6 +125,21 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token // token.IDENT 标识符
 	Value string      // 标识符的值
+	// 以下字段由resolver填写。Resolved为true时，变量保存在向外Depth层的环境的第Slot个位置，
+	// 否则按名字查找
+	Resolved bool
+	Depth    int
+	Slot     int
+	// Fallbacks 变量在外层函数中、函数定义之后才声明时，那里的let执行之前依次改为读取的位置
+	Fallbacks []Location
+	// FallbackByName 外层没有声明这个名字，Fallbacks也没有值时按名字查找内置函数
+	FallbackByName bool
+}
+
+// Location 变量在环境中的位置，向外Depth层的环境的第Slot个位置
+type Location struct {
+	Depth int
+	Slot  int
 }
 
 // expressionNode 表示这是一个表达式，实现了Expression接口
@@ -96,6 +156,11 @@ func (i *Identifier) String() string {
 	return i.Value
 }
 
+// patternNode 表示标识符也可以作为绑定模式，实现了Pattern接口
+func (i *Identifier) patternNode() {
+
+}
+
 // ReturnStatement return语句
 type ReturnStatement struct {
 	Token       token.Token // token.RETURN 标识符
@@ -162,6 +227,39 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// BigIntegerLiteral 超出int64范围的整数字面量，求值为BigInt
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode() {
+
+}
+func (bl *BigIntegerLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+func (bl *BigIntegerLiteral) String() string {
+	return bl.Token.Literal
+}
+
+// DecimalLiteral 精确小数字面量，值为Value * 10^-Scale，例如1.25d的Value是125，Scale是2
+type DecimalLiteral struct {
+	Token token.Token
+	Value *big.Int
+	Scale int
+}
+
+func (dl *DecimalLiteral) expressionNode() {
+
+}
+func (dl *DecimalLiteral) TokenLiteral() string {
+	return dl.Token.Literal
+}
+func (dl *DecimalLiteral) String() string {
+	return dl.Token.Literal
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -233,10 +331,13 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 func (bs *BlockStatement) String() string {
-	var out bytes.Buffer
-	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+	if len(bs.Statements) == 0 {
+		return "{ }"
 	}
+	var out bytes.Buffer
+	out.WriteString("{ ")
+	writeStatements(&out, bs.Statements, " ")
+	out.WriteString(" }")
 	return out.String()
 }
 
@@ -255,12 +356,12 @@ func (ie *IfExpression) TokenLiteral() string {
 }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
-	out.WriteString("if")
+	out.WriteString("if (")
 	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") ")
 	out.WriteString(ie.Consequence.String())
 	if ie.Alternative != nil {
-		out.WriteString("else ")
+		out.WriteString(" else ")
 		out.WriteString(ie.Alternative.String())
 	}
 	return out.String()
@@ -268,8 +369,9 @@ func (ie *IfExpression) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []*Identifier
+	Parameters []Pattern // 参数可以是标识符或者解构模式
 	Body       *BlockStatement
+	Name       string // 通过let绑定时记录的函数名，用于调用栈，匿名函数为空
 }
 
 func (fl *FunctionLiteral) expressionNode() {
@@ -287,7 +389,7 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(")")
+	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -297,6 +399,7 @@ type CallExpression struct {
 	Token     token.Token //(词法单元
 	Function  Expression  // 标识符或函数字面量
 	Arguments []Expression
+	Tail      bool // 调用处于函数的尾位置，由MarkTailCalls标记
 }
 
 func (ce *CallExpression) expressionNode() {
@@ -318,3 +421,381 @@ func (ce *CallExpression) String() string {
 	return out.String()
 
 }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode() {
+
+}
+func (sl *StringLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+func (sl *StringLiteral) String() string {
+	return quoteString(sl.Value)
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // [ 词法单元
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {
+
+}
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	var elements []string
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashPair 哈希字面量中的一个键值对
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral 哈希字面量，键值对按照源代码中的顺序保存
+type HashLiteral struct {
+	Token token.Token // { 词法单元
+	Pairs []*HashPair
+}
+
+func (hl *HashLiteral) expressionNode() {
+
+}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+	var pairs []string
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token // [ 词法单元
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {
+
+}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+// ArrayPattern 数组解构模式，例如 [a, b, ...rest]
+type ArrayPattern struct {
+	Token    token.Token // [ 词法单元
+	Elements []Pattern
+	Rest     *Identifier // ...rest，可以为nil
+}
+
+func (ap *ArrayPattern) expressionNode() {
+
+}
+func (ap *ArrayPattern) patternNode() {
+
+}
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+	var elements []string
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashPatternPair 哈希解构模式中的一项，Key是哈希中的字符串键，Value是绑定的目标
+type HashPatternPair struct {
+	Key   *Identifier
+	Value Pattern
+}
+
+// HashPattern 哈希解构模式，例如 {name, age: years}
+type HashPattern struct {
+	Token token.Token // { 词法单元
+	Pairs []*HashPatternPair
+}
+
+func (hp *HashPattern) expressionNode() {
+
+}
+func (hp *HashPattern) patternNode() {
+
+}
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+	var pairs []string
+	for _, pair := range hp.Pairs {
+		// 简写形式 {name} 等价于 {name: name}
+		if ident, ok := pair.Value.(*Identifier); ok && ident.Value == pair.Key.Value {
+			pairs = append(pairs, pair.Key.String())
+		} else {
+			pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+		}
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// ThrowStatement throw语句，抛出一个值作为错误
+type ThrowStatement struct {
+	Token token.Token // token.THROW 标识符
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {
+
+}
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// ExportStatement export语句，导出let绑定的变量，只能出现在模块的最外层
+type ExportStatement struct {
+	Token     token.Token // token.EXPORT 标识符
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode() {
+
+}
+func (es *ExportStatement) TokenLiteral() string {
+	return es.Token.Literal
+}
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// ImportExpression import表达式，值是模块导出的变量组成的哈希表。
+// Resolved是加载模块时解析出的模块文件的绝对路径，执行时按照它查找模块
+type ImportExpression struct {
+	Token    token.Token // token.IMPORT 标识符
+	Path     string
+	Resolved string
+}
+
+func (ie *ImportExpression) expressionNode() {
+
+}
+func (ie *ImportExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " " + quoteString(ie.Path)
+}
+
+// TryExpression try表达式，Catch和Finally至少有一个不为nil
+type TryExpression struct {
+	Token      token.Token // token.TRY 标识符
+	Block      *BlockStatement
+	CatchParam *Identifier // catch (e) 中绑定错误的标识符
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {
+
+}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	if te.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.CatchParam.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
+// PostfixExpression 后缀表达式，例如 result?
+type PostfixExpression struct {
+	Token    token.Token // 后缀运算符词法单元
+	Left     Expression
+	Operator string
+}
+
+func (pe *PostfixExpression) expressionNode() {
+
+}
+func (pe *PostfixExpression) TokenLiteral() string {
+	return pe.Token.Literal
+}
+func (pe *PostfixExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(pe.Operator)
+	out.WriteString(")")
+	return out.String()
+}
+
+// MacroLiteral 宏字面量，参数在展开时绑定为未求值的语法树
+type MacroLiteral struct {
+	Token      token.Token // token.MACRO 标识符
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {
+
+}
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+	var params []string
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+	return out.String()
+}
+
+// UnquotePattern 出现在quote中绑定位置上的unquote调用，例如 fn(unquote(name)) {...}。
+// 展开时替换为求值得到的模式，通常与gensym配合生成不会冲突的绑定
+type UnquotePattern struct {
+	Token    token.Token // unquote 标识符
+	Argument Expression
+}
+
+func (up *UnquotePattern) expressionNode() {
+
+}
+func (up *UnquotePattern) patternNode() {
+
+}
+func (up *UnquotePattern) TokenLiteral() string {
+	return up.Token.Literal
+}
+func (up *UnquotePattern) String() string {
+	return "unquote(" + up.Argument.String() + ")"
+}
+
+// TokenOf 返回节点的词法单元的指针，用于读取或修改节点的位置信息。Program没有词法单元，返回nil
+func TokenOf(node Node) *token.Token {
+	switch n := node.(type) {
+	case *LetStatement:
+		return &n.Token
+	case *Identifier:
+		return &n.Token
+	case *ReturnStatement:
+		return &n.Token
+	case *ExpressionStatement:
+		return &n.Token
+	case *IntegerLiteral:
+		return &n.Token
+	case *BigIntegerLiteral:
+		return &n.Token
+	case *DecimalLiteral:
+		return &n.Token
+	case *PrefixExpression:
+		return &n.Token
+	case *InfixExpression:
+		return &n.Token
+	case *Boolean:
+		return &n.Token
+	case *BlockStatement:
+		return &n.Token
+	case *IfExpression:
+		return &n.Token
+	case *FunctionLiteral:
+		return &n.Token
+	case *CallExpression:
+		return &n.Token
+	case *StringLiteral:
+		return &n.Token
+	case *ArrayLiteral:
+		return &n.Token
+	case *HashLiteral:
+		return &n.Token
+	case *IndexExpression:
+		return &n.Token
+	case *ArrayPattern:
+		return &n.Token
+	case *HashPattern:
+		return &n.Token
+	case *ThrowStatement:
+		return &n.Token
+	case *ExportStatement:
+		return &n.Token
+	case *ImportExpression:
+		return &n.Token
+	case *TryExpression:
+		return &n.Token
+	case *PostfixExpression:
+		return &n.Token
+	case *MacroLiteral:
+		return &n.Token
+	case *UnquotePattern:
+		return &n.Token
+	default:
+		return nil
+	}
+}
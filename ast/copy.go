@@ -0,0 +1,165 @@
+package ast
+
+// Copy 深拷贝以node为根的语法树，返回的树与原来的树不共享任何节点。
+// 在用Modify改写之前先拷贝，可以避免改写共享的语法树，例如函数体中的quote每次调用都会展开不同的值
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *Program:
+		return &Program{Statements: copyStatements(n.Statements)}
+	case *LetStatement:
+		c := &LetStatement{Token: n.Token}
+		if n.Name != nil {
+			c.Name = Copy(n.Name).(*Identifier)
+		}
+		if n.Pattern != nil {
+			c.Pattern = Copy(n.Pattern).(Pattern)
+		}
+		c.Value = copyExpression(n.Value)
+		return c
+	case *ReturnStatement:
+		return &ReturnStatement{Token: n.Token, ReturnValue: copyExpression(n.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: n.Token, Expression: copyExpression(n.Expression)}
+	case *ThrowStatement:
+		return &ThrowStatement{Token: n.Token, Value: copyExpression(n.Value)}
+	case *ExportStatement:
+		return &ExportStatement{Token: n.Token, Statement: Copy(n.Statement).(*LetStatement)}
+	case *ImportExpression:
+		c := *n
+		return &c
+	case *BlockStatement:
+		return copyBlock(n)
+	case *Identifier:
+		c := *n
+		return &c
+	case *IntegerLiteral:
+		return &IntegerLiteral{Token: n.Token, Value: n.Value}
+	case *BigIntegerLiteral:
+		// Value不会被修改，可以共享
+		return &BigIntegerLiteral{Token: n.Token, Value: n.Value}
+	case *DecimalLiteral:
+		// Value不会被修改，可以共享
+		return &DecimalLiteral{Token: n.Token, Value: n.Value, Scale: n.Scale}
+	case *Boolean:
+		return &Boolean{Token: n.Token, Value: n.Value}
+	case *StringLiteral:
+		return &StringLiteral{Token: n.Token, Value: n.Value}
+	case *PrefixExpression:
+		return &PrefixExpression{Token: n.Token, Operator: n.Operator, Right: copyExpression(n.Right)}
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    n.Token,
+			Left:     copyExpression(n.Left),
+			Operator: n.Operator,
+			Right:    copyExpression(n.Right),
+		}
+	case *PostfixExpression:
+		return &PostfixExpression{Token: n.Token, Left: copyExpression(n.Left), Operator: n.Operator}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       n.Token,
+			Condition:   copyExpression(n.Condition),
+			Consequence: copyBlock(n.Consequence),
+			Alternative: copyBlock(n.Alternative),
+		}
+	case *FunctionLiteral:
+		c := &FunctionLiteral{Token: n.Token, Body: copyBlock(n.Body), Name: n.Name}
+		for _, p := range n.Parameters {
+			c.Parameters = append(c.Parameters, Copy(p).(Pattern))
+		}
+		return c
+	case *MacroLiteral:
+		c := &MacroLiteral{Token: n.Token, Body: copyBlock(n.Body)}
+		for _, p := range n.Parameters {
+			c.Parameters = append(c.Parameters, Copy(p).(*Identifier))
+		}
+		return c
+	case *CallExpression:
+		return &CallExpression{
+			Token:     n.Token,
+			Function:  copyExpression(n.Function),
+			Arguments: copyExpressions(n.Arguments),
+			Tail:      n.Tail,
+		}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: n.Token, Elements: copyExpressions(n.Elements)}
+	case *HashLiteral:
+		c := &HashLiteral{Token: n.Token, Pairs: []*HashPair{}}
+		for _, pair := range n.Pairs {
+			c.Pairs = append(c.Pairs, &HashPair{Key: copyExpression(pair.Key), Value: copyExpression(pair.Value)})
+		}
+		return c
+	case *IndexExpression:
+		return &IndexExpression{Token: n.Token, Left: copyExpression(n.Left), Index: copyExpression(n.Index)}
+	case *TryExpression:
+		c := &TryExpression{
+			Token:   n.Token,
+			Block:   copyBlock(n.Block),
+			Catch:   copyBlock(n.Catch),
+			Finally: copyBlock(n.Finally),
+		}
+		if n.CatchParam != nil {
+			c.CatchParam = Copy(n.CatchParam).(*Identifier)
+		}
+		return c
+	case *ArrayPattern:
+		c := &ArrayPattern{Token: n.Token}
+		for _, e := range n.Elements {
+			c.Elements = append(c.Elements, Copy(e).(Pattern))
+		}
+		if n.Rest != nil {
+			c.Rest = Copy(n.Rest).(*Identifier)
+		}
+		return c
+	case *HashPattern:
+		c := &HashPattern{Token: n.Token}
+		for _, pair := range n.Pairs {
+			c.Pairs = append(c.Pairs, &HashPatternPair{
+				Key:   Copy(pair.Key).(*Identifier),
+				Value: Copy(pair.Value).(Pattern),
+			})
+		}
+		return c
+	case *UnquotePattern:
+		return &UnquotePattern{Token: n.Token, Argument: copyExpression(n.Argument)}
+	}
+	return node
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	return Copy(exp).(Expression)
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	c := make([]Expression, len(exps))
+	for i, e := range exps {
+		c[i] = copyExpression(e)
+	}
+	return c
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	c := make([]Statement, len(stmts))
+	for i, s := range stmts {
+		c[i] = Copy(s).(Statement)
+	}
+	return c
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: copyStatements(block.Statements)}
+}
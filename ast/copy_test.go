@@ -0,0 +1,60 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Pattern: &HashPattern{Pairs: []*HashPatternPair{{Key: &Identifier{Value: "k"}, Value: &Identifier{Value: "v"}}}},
+				Value:   &HashLiteral{Pairs: []*HashPair{{Key: &StringLiteral{Value: "k"}, Value: &IntegerLiteral{Value: 1}}}},
+			},
+			&ExpressionStatement{Expression: &MacroLiteral{
+				Parameters: []*Identifier{{Value: "m"}},
+				Body:       &BlockStatement{Statements: []Statement{&ThrowStatement{Value: &Identifier{Value: "m"}}}},
+			}},
+			&ExpressionStatement{Expression: &TryExpression{
+				Block: &BlockStatement{Statements: []Statement{
+					&ReturnStatement{ReturnValue: &CallExpression{
+						Function: &FunctionLiteral{
+							Parameters: []Pattern{&ArrayPattern{Elements: []Pattern{&Identifier{Value: "a"}}, Rest: &Identifier{Value: "r"}}},
+							Body:       &BlockStatement{Statements: []Statement{}},
+							Name:       "f",
+						},
+						Arguments: []Expression{&IndexExpression{Left: &ArrayLiteral{}, Index: &PostfixExpression{Left: &Boolean{Value: true}, Operator: "?"}}},
+					}},
+				}},
+				CatchParam: &Identifier{Value: "e", Resolved: true, Depth: 1, Slot: 2},
+				Catch: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &IfExpression{
+					Condition:   &PrefixExpression{Operator: "!", Right: &Identifier{Value: "e"}},
+					Consequence: &BlockStatement{},
+				}}}},
+			}},
+		},
+	}
+
+	copied := Copy(program)
+	if !reflect.DeepEqual(copied, program) {
+		t.Fatalf("copy not equal to original.\ngot=%#v\nwant=%#v", copied, program)
+	}
+
+	// 改写拷贝不影响原来的树
+	Modify(copied, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok {
+			ident.Value = "renamed"
+		}
+		return node
+	})
+	if program.String() == copied.String() {
+		t.Errorf("modifying the copy changed the original: %q", program.String())
+	}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "renamed" {
+			t.Errorf("original tree shares identifier nodes with the copy")
+		}
+		return true
+	})
+}
@@ -0,0 +1,106 @@
+package ast
+
+// ModifierFunc 接收一个节点，返回用来替换它的节点，返回原节点表示不做修改
+type ModifierFunc func(Node) Node
+
+// Modify 按照后序遍历改写以node为根的语法树：先改写所有子节点，再对node本身调用modifier，返回改写后的根节点。
+// modifier返回的节点必须能放回原来的位置，例如表达式的位置只能放表达式，否则该位置会被置为nil
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		for i, statement := range n.Statements {
+			n.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	case *LetStatement:
+		if n.Pattern != nil {
+			n.Pattern, _ = Modify(n.Pattern, modifier).(Pattern)
+		} else if n.Name != nil {
+			n.Name, _ = Modify(n.Name, modifier).(*Identifier)
+		}
+		if n.Value != nil {
+			n.Value, _ = Modify(n.Value, modifier).(Expression)
+		}
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			n.ReturnValue, _ = Modify(n.ReturnValue, modifier).(Expression)
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			n.Expression, _ = Modify(n.Expression, modifier).(Expression)
+		}
+	case *ThrowStatement:
+		if n.Value != nil {
+			n.Value, _ = Modify(n.Value, modifier).(Expression)
+		}
+	case *ExportStatement:
+		n.Statement, _ = Modify(n.Statement, modifier).(*LetStatement)
+	case *BlockStatement:
+		for i, statement := range n.Statements {
+			n.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	case *PrefixExpression:
+		n.Right, _ = Modify(n.Right, modifier).(Expression)
+	case *InfixExpression:
+		n.Left, _ = Modify(n.Left, modifier).(Expression)
+		n.Right, _ = Modify(n.Right, modifier).(Expression)
+	case *PostfixExpression:
+		n.Left, _ = Modify(n.Left, modifier).(Expression)
+	case *IfExpression:
+		n.Condition, _ = Modify(n.Condition, modifier).(Expression)
+		n.Consequence, _ = Modify(n.Consequence, modifier).(*BlockStatement)
+		if n.Alternative != nil {
+			n.Alternative, _ = Modify(n.Alternative, modifier).(*BlockStatement)
+		}
+	case *FunctionLiteral:
+		for i, parameter := range n.Parameters {
+			n.Parameters[i], _ = Modify(parameter, modifier).(Pattern)
+		}
+		n.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+	case *MacroLiteral:
+		for i, parameter := range n.Parameters {
+			n.Parameters[i], _ = Modify(parameter, modifier).(*Identifier)
+		}
+		n.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+	case *CallExpression:
+		n.Function, _ = Modify(n.Function, modifier).(Expression)
+		for i, argument := range n.Arguments {
+			n.Arguments[i], _ = Modify(argument, modifier).(Expression)
+		}
+	case *ArrayLiteral:
+		for i, element := range n.Elements {
+			n.Elements[i], _ = Modify(element, modifier).(Expression)
+		}
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			pair.Key, _ = Modify(pair.Key, modifier).(Expression)
+			pair.Value, _ = Modify(pair.Value, modifier).(Expression)
+		}
+	case *IndexExpression:
+		n.Left, _ = Modify(n.Left, modifier).(Expression)
+		n.Index, _ = Modify(n.Index, modifier).(Expression)
+	case *TryExpression:
+		n.Block, _ = Modify(n.Block, modifier).(*BlockStatement)
+		if n.Catch != nil {
+			n.CatchParam, _ = Modify(n.CatchParam, modifier).(*Identifier)
+			n.Catch, _ = Modify(n.Catch, modifier).(*BlockStatement)
+		}
+		if n.Finally != nil {
+			n.Finally, _ = Modify(n.Finally, modifier).(*BlockStatement)
+		}
+	case *ArrayPattern:
+		for i, element := range n.Elements {
+			n.Elements[i], _ = Modify(element, modifier).(Pattern)
+		}
+		if n.Rest != nil {
+			n.Rest, _ = Modify(n.Rest, modifier).(*Identifier)
+		}
+	case *HashPattern:
+		for _, pair := range n.Pairs {
+			pair.Value, _ = Modify(pair.Value, modifier).(Pattern)
+		}
+	case *UnquotePattern:
+		n.Argument, _ = Modify(n.Argument, modifier).(Expression)
+	}
+
+	return modifier(node)
+}
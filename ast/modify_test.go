@@ -0,0 +1,215 @@
+package ast
+
+import (
+	"Interp/token"
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		if integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{
+			one(),
+			two(),
+		},
+		{
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: one()},
+				},
+			},
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: two()},
+				},
+			},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&PostfixExpression{Left: one(), Operator: "?"},
+			&PostfixExpression{Left: two(), Operator: "?"},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&ThrowStatement{Value: one()},
+			&ThrowStatement{Value: two()},
+		},
+		{
+			&LetStatement{Name: &Identifier{Value: "a"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "a"}, Value: two()},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []Pattern{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []Pattern{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), two()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&HashLiteral{Pairs: []*HashPair{{Key: one(), Value: one()}}},
+			&HashLiteral{Pairs: []*HashPair{{Key: two(), Value: two()}}},
+		},
+		{
+			&TryExpression{
+				Block:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				CatchParam: &Identifier{Value: "e"},
+				Catch:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Finally:    &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&TryExpression{
+				Block:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				CatchParam: &Identifier{Value: "e"},
+				Catch:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Finally:    &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+func TestModifyRenamesBindings(t *testing.T) {
+	renameX := func(node Node) Node {
+		ident, ok := node.(*Identifier)
+		if !ok || ident.Value != "x" {
+			return node
+		}
+		return &Identifier{Value: "y"}
+	}
+
+	input := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Pattern: &ArrayPattern{
+					Elements: []Pattern{&Identifier{Value: "x"}},
+					Rest:     &Identifier{Value: "x"},
+				},
+				Value: &Identifier{Value: "x"},
+			},
+			&ExpressionStatement{Expression: &FunctionLiteral{
+				Token: token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Parameters: []Pattern{
+					&Identifier{Value: "x"},
+					&HashPattern{Pairs: []*HashPatternPair{
+						{Key: &Identifier{Value: "x"}, Value: &Identifier{Value: "x"}},
+					}},
+				},
+				Body: &BlockStatement{Statements: []Statement{}},
+			}},
+		},
+	}
+
+	modified := Modify(input, renameX)
+	expected := "let [y, ...y] = y;\nfn(y, {x: y}) { }"
+	if modified.String() != expected {
+		t.Errorf("wrong result. want=%q, got=%q", expected, modified.String())
+	}
+}
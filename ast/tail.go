@@ -0,0 +1,60 @@
+package ast
+
+// MarkTailCalls 标记函数体中处于尾位置的调用：函数体的最后一个表达式，
+// 包括作为最后一个表达式的if的各个分支中的最后一个表达式，以及return的值。
+// try中的调用不是尾调用，调用返回之后还要处理错误和执行finally。
+// 嵌套的函数字面量在解析时单独标记
+func MarkTailCalls(body *BlockStatement) {
+	markTailBlock(body, true)
+}
+
+func markTailBlock(block *BlockStatement, tail bool) {
+	if block == nil {
+		return
+	}
+	for i, statement := range block.Statements {
+		switch statement := statement.(type) {
+		case *ExpressionStatement:
+			markTail(statement.Expression, tail && i == len(block.Statements)-1)
+		case *ReturnStatement:
+			markTail(statement.ReturnValue, true)
+		default:
+			markTail(statement, false)
+		}
+	}
+}
+
+// markTail tail表示node本身是否处于尾位置。不在尾位置的节点中仍然可能有return，所以要继续向下查找
+func markTail(node Node, tail bool) {
+	switch node := node.(type) {
+	case nil:
+	case *CallExpression:
+		// quote的参数不会被求值
+		if node.Function.TokenLiteral() == "quote" {
+			return
+		}
+		node.Tail = tail
+		markTail(node.Function, false)
+		for _, arg := range node.Arguments {
+			markTail(arg, false)
+		}
+	case *IfExpression:
+		markTail(node.Condition, false)
+		markTailBlock(node.Consequence, tail)
+		markTailBlock(node.Alternative, tail)
+	case *BlockStatement:
+		markTailBlock(node, tail)
+	case *ReturnStatement:
+		markTail(node.ReturnValue, true)
+	case *FunctionLiteral, *MacroLiteral, *TryExpression:
+	default:
+		// 其余节点的子节点都不在尾位置
+		Inspect(node, func(child Node) bool {
+			if child == node {
+				return true
+			}
+			markTail(child, false)
+			return false
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recorder 记录访问过的节点类型，nil表示一个节点的子节点已经访问完毕
+type recorder struct {
+	visited []string
+}
+
+func (r *recorder) Visit(node Node) Visitor {
+	if node == nil {
+		r.visited = append(r.visited, "end")
+		return nil
+	}
+	r.visited = append(r.visited, fmt.Sprintf("%T", node))
+	return r
+}
+
+func TestWalkVisitsEveryNode(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	integer := func(v int64) *IntegerLiteral { return &IntegerLiteral{Value: v} }
+	block := func(exp Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: exp}}}
+	}
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{Name: ident("a"), Value: &PrefixExpression{Operator: "-", Right: integer(1)}},
+			&LetStatement{
+				Pattern: &ArrayPattern{
+					Elements: []Pattern{&HashPattern{Pairs: []*HashPatternPair{{Key: ident("k"), Value: ident("v")}}}},
+					Rest:     ident("r"),
+				},
+				Value: &ArrayLiteral{Elements: []Expression{&StringLiteral{Value: "s"}}},
+			},
+			&ReturnStatement{ReturnValue: &InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)}},
+			&ThrowStatement{Value: &PostfixExpression{Left: ident("x"), Operator: "?"}},
+			&ExpressionStatement{Expression: &IfExpression{
+				Condition:   &Boolean{Value: true},
+				Consequence: block(integer(1)),
+				Alternative: block(integer(2)),
+			}},
+			&ExpressionStatement{Expression: &CallExpression{
+				Function: &FunctionLiteral{
+					Parameters: []Pattern{ident("p")},
+					Body:       block(ident("p")),
+				},
+				Arguments: []Expression{&HashLiteral{Pairs: []*HashPair{{Key: integer(1), Value: integer(2)}}}},
+			}},
+			&ExpressionStatement{Expression: &TryExpression{
+				Block:      block(&IndexExpression{Left: ident("h"), Index: integer(0)}),
+				CatchParam: ident("e"),
+				Catch:      block(ident("e")),
+				Finally:    block(ident("f")),
+			}},
+		},
+	}
+
+	r := &recorder{}
+	Walk(r, program)
+
+	expected := []string{
+		"*ast.Program",
+		"*ast.LetStatement", "*ast.Identifier", "end",
+		"*ast.PrefixExpression", "*ast.IntegerLiteral", "end", "end", "end",
+		"*ast.LetStatement", "*ast.ArrayPattern", "*ast.HashPattern", "*ast.Identifier", "end", "end",
+		"*ast.Identifier", "end", "end",
+		"*ast.ArrayLiteral", "*ast.StringLiteral", "end", "end", "end",
+		"*ast.ReturnStatement", "*ast.InfixExpression", "*ast.IntegerLiteral", "end", "*ast.IntegerLiteral", "end", "end", "end",
+		"*ast.ThrowStatement", "*ast.PostfixExpression", "*ast.Identifier", "end", "end", "end",
+		"*ast.ExpressionStatement", "*ast.IfExpression", "*ast.Boolean", "end",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IntegerLiteral", "end", "end", "end",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IntegerLiteral", "end", "end", "end", "end", "end",
+		"*ast.ExpressionStatement", "*ast.CallExpression", "*ast.FunctionLiteral", "*ast.Identifier", "end",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.Identifier", "end", "end", "end", "end",
+		"*ast.HashLiteral", "*ast.IntegerLiteral", "end", "*ast.IntegerLiteral", "end", "end", "end", "end",
+		"*ast.ExpressionStatement", "*ast.TryExpression",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IndexExpression", "*ast.Identifier", "end",
+		"*ast.IntegerLiteral", "end", "end", "end", "end",
+		"*ast.Identifier", "end",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.Identifier", "end", "end", "end",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.Identifier", "end", "end", "end", "end", "end",
+		"end",
+	}
+
+	if !reflect.DeepEqual(r.visited, expected) {
+		t.Errorf("wrong visiting order.\nwant=%v\ngot =%v", expected, r.visited)
+	}
+}
+
+func TestInspectPrunesSubtrees(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &CallExpression{
+				Function: &Identifier{Value: "outer"},
+				Arguments: []Expression{&FunctionLiteral{
+					Parameters: []Pattern{&Identifier{Value: "inner"}},
+					Body:       &BlockStatement{},
+				}},
+			}},
+		},
+	}
+
+	var names []string
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *FunctionLiteral:
+			// 不进入函数体
+			return false
+		case *Identifier:
+			names = append(names, node.Value)
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(names, []string{"outer"}) {
+		t.Errorf("wrong identifiers. want=%v, got=%v", []string{"outer"}, names)
+	}
+}
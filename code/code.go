@@ -0,0 +1,237 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions 字节码指令序列，每条指令由一个字节的操作码和若干大端序的操作数组成
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(def.Name)
+	for _, operand := range operands {
+		fmt.Fprintf(&out, " %d", operand)
+	}
+	return out.String()
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // OpConstant 将常量池中的常量压栈
+	OpPop                    // OpPop 弹出栈顶
+
+	OpAdd // OpAdd 以及下面的二元运算符弹出两个操作数，压入运算结果
+	OpSub
+	OpMul
+	OpDiv
+	OpExactDiv // OpExactDiv 精确除法//
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+
+	OpMinus // OpMinus 取负
+	OpBang  // OpBang 取反
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJump          // OpJump 无条件跳转到操作数指定的位置
+	OpJumpNotTruthy // OpJumpNotTruthy 弹出栈顶，不为真时跳转
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetFree
+	OpCurrentClosure // OpCurrentClosure 压入正在执行的闭包，用于局部函数的递归
+
+	OpArray // OpArray 用栈顶的n个元素构造数组
+	OpHash  // OpHash 用栈顶的n个元素构造哈希表，n是键和值的总数
+	OpIndex
+
+	OpCall        // OpCall 调用函数，操作数是实参的个数
+	OpReturnValue // OpReturnValue 从函数返回栈顶的值
+	OpReturn      // OpReturn 从函数返回null
+
+	OpClosure // OpClosure 用常量池中的函数和栈顶的n个自由变量构造闭包
+
+	OpThrow    // OpThrow 弹出栈顶的值并抛出
+	OpSetupTry // OpSetupTry 注册一个错误处理器，出错时恢复栈并跳转到操作数指定的位置
+	OpPopTry   // OpPopTry 注销最近注册的错误处理器
+
+	OpArrayPattern // OpArrayPattern 按数组模式解构栈顶的值，依次压入剩余元素和各个元素
+	OpHashPattern  // OpHashPattern 按哈希模式解构，栈上依次是哈希表和n个键，压入对应的值
+	OpUnwrap       // OpUnwrap ?运算符，Ok和Some解包后跳转，Err和None留在栈顶交给后面的返回指令
+
+	OpImport // OpImport 压入已经加载的模块导出的哈希表
+
+	OpCaptureLocal // OpCaptureLocal 压入局部变量的Cell，用于构造闭包，变量之后的赋值对闭包可见
+	OpCaptureFree  // OpCaptureFree 压入当前闭包捕获的Cell，传给内层的闭包
+
+	OpGetFreeIfSet // OpGetFreeIfSet 自由变量已经赋值时压栈并跳转，否则继续执行后面读取外层同名变量的指令
+)
+
+// Definition 操作码的名字和每个操作数占用的字节数
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:         {"OpAdd", []int{}},
+	OpSub:         {"OpSub", []int{}},
+	OpMul:         {"OpMul", []int{}},
+	OpDiv:         {"OpDiv", []int{}},
+	OpExactDiv:    {"OpExactDiv", []int{}},
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	// 常量池中函数的下标，自由变量的个数
+	OpClosure: {"OpClosure", []int{2, 1}},
+
+	OpThrow:    {"OpThrow", []int{}},
+	OpSetupTry: {"OpSetupTry", []int{2}},
+	OpPopTry:   {"OpPopTry", []int{}},
+
+	// 模式源代码在常量池中的下标（用于错误信息），元素的个数，是否有剩余元素
+	OpArrayPattern: {"OpArrayPattern", []int{2, 1, 1}},
+	// 模式源代码在常量池中的下标，键的个数
+	OpHashPattern: {"OpHashPattern", []int{2, 1}},
+	OpUnwrap:      {"OpUnwrap", []int{2}},
+
+	// 源代码中的路径和解析出的路径在常量池中的下标
+	OpImport: {"OpImport", []int{2, 2}},
+
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}},
+
+	// 自由变量的下标，已经赋值时跳转到的位置
+	OpGetFreeIfSet: {"OpGetFreeIfSet", []int{1, 2}},
+}
+
+// Lookup 查找操作码的定义
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make 将操作码和操作数编码为一条指令，操作码未定义时返回空切片
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands 按照定义解码操作数，返回操作数和读取的字节数
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,23 @@
+package code
+
+import "sort"
+
+// SourcePosition 记录从Offset开始的指令对应的源代码位置。
+// Line为0表示这些指令没有对应的源代码，例如编译器为解构参数生成的指令
+type SourcePosition struct {
+	Offset   int
+	Line     int
+	Position string // Position 形如 3:10，宏展开的代码同时包含调用处，与token.Token.Position相同
+}
+
+// SourceMap 按Offset递增排列的位置表，一个位置覆盖到下一个位置开始之前的所有指令
+type SourceMap []SourcePosition
+
+// Lookup 查找偏移量为offset的指令对应的位置，没有对应的源代码时返回false
+func (m SourceMap) Lookup(offset int) (SourcePosition, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if i == 0 || m[i-1].Line == 0 {
+		return SourcePosition{}, false
+	}
+	return m[i-1], true
+}
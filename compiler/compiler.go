@@ -0,0 +1,844 @@
+package compiler
+
+import (
+	"Interp/ast"
+	"Interp/code"
+	"Interp/evaluator"
+	"Interp/object"
+	"fmt"
+	"math"
+	"sort"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	builtins map[string]int // builtins 内置函数在常量池中的下标
+	position position       // position 正在编译的节点的位置
+	err      error          // err 第一个超出指令编码范围的操作数
+}
+
+// EmittedInstruction 记录发出的指令，用于删除或替换最后一条指令
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope 每个函数体有自己的指令序列
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	sourceMap           code.SourceMap
+	tries               []*tryContext
+}
+
+// tryContext 记录正在编译的try表达式。return离开try时需要先注销错误处理器并执行finally块
+type tryContext struct {
+	handlers int // handlers 当前位置有效的错误处理器个数
+	finally  *ast.BlockStatement
+}
+
+type position struct {
+	line int
+	text string
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+		builtins:    make(map[string]int),
+	}
+}
+
+// NewWithState 沿用之前的符号表和常量池，使REPL中的多次输入可以共享全局变量
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// Compile 编译节点。局部变量、实参、常量、全局变量或跳转偏移量超出指令能编码的范围时返回错误
+func (c *Compiler) Compile(node ast.Node) error {
+	if err := c.compile(node); err != nil {
+		return err
+	}
+	return c.err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	if tok := ast.TokenOf(node); tok != nil && tok.Line > 0 {
+		saved := c.position
+		c.position = position{line: tok.Line, text: tok.Position()}
+		defer func() { c.position = saved }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Pattern != nil {
+			return c.compilePattern(node.Pattern)
+		}
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		return c.emitReturn()
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case "//":
+			c.emit(code.OpExactDiv)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.PostfixExpression:
+		if node.Operator != "?" {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		// Ok和Some解包后跳过返回指令，Err和None留在栈顶从函数返回
+		unwrapPos := c.emit(code.OpUnwrap, 9999)
+		if err := c.emitReturn(); err != nil {
+			return err
+		}
+		c.changeOperand(unwrapPos, len(c.currentInstructions()))
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		// 跳转的目标暂时未知，先填入一个假的偏移量，编译完分支后再回填
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileBlockValue(node.Consequence); err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		afterConsequencePos := len(c.currentInstructions())
+		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else {
+			if err := c.compileBlockValue(node.Alternative); err != nil {
+				return err
+			}
+		}
+
+		afterAlternativePos := len(c.currentInstructions())
+		c.changeOperand(jumpPos, afterAlternativePos)
+
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.ImportExpression:
+		path := c.addConstant(&object.String{Value: node.Path})
+		resolved := c.addConstant(&object.String{Value: node.Resolved})
+		c.emit(code.OpImport, path, resolved)
+
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+
+	case *ast.BigIntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Value}))
+
+	case *ast.DecimalLiteral:
+		decimal := &object.Decimal{Value: node.Value, Scale: node.Scale}
+		c.emit(code.OpConstant, c.addConstant(decimal))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Identifier:
+		c.loadName(node.Value)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		// 按照源代码中的顺序压栈，保持键的插入顺序
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return fmt.Errorf("quote is not supported by the compiler")
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macro literals must be expanded before compiling")
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+	c.symbolTable.Declare(declaredNames(node.Body))
+
+	// 参数依次占据前几个局部变量的位置，需要解构的参数先存放在匿名的位置上
+	slots := make([]Symbol, len(node.Parameters))
+	for i, p := range node.Parameters {
+		if ident, ok := p.(*ast.Identifier); ok {
+			slots[i] = c.symbolTable.DefineNew(ident.Value)
+		} else {
+			slots[i] = c.symbolTable.DefineHidden()
+		}
+	}
+
+	// 解构参数的指令没有位置，出错时由调用处记录位置，与解释器一致
+	saved := c.position
+	c.position = position{}
+	for i, p := range node.Parameters {
+		if _, ok := p.(*ast.Identifier); ok {
+			continue
+		}
+		c.emit(code.OpGetLocal, slots[i].Index)
+		if err := c.compilePattern(p); err != nil {
+			return err
+		}
+	}
+	c.position = saved
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	if numLocals > math.MaxUint8 && c.err == nil {
+		c.err = fmt.Errorf("%s, the limit is %d", operandLimit(code.OpGetLocal, 0), math.MaxUint8)
+	}
+	localNames := c.symbolTable.Names()
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumFree:       len(freeSymbols),
+		Name:          node.Name,
+		SourceMap:     sourceMap,
+		LocalNames:    localNames,
+		Source:        node.String(),
+		Globals:       globalReads(instructions, c.constants),
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+	return nil
+}
+
+// globalReads 按第一次出现的顺序返回指令和其中创建的闭包读取的全局变量的下标，
+// 内层函数的Globals必须已经计算过
+func globalReads(ins code.Instructions, constants []object.Object) []int {
+	var globals []int
+	seen := make(map[int]bool)
+	add := func(index int) {
+		if !seen[index] {
+			seen[index] = true
+			globals = append(globals, index)
+		}
+	}
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			break
+		}
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+		switch code.Opcode(ins[ip]) {
+		case code.OpGetGlobal:
+			add(operands[0])
+		case code.OpClosure:
+			if fn, ok := constants[operands[0]].(*object.CompiledFunction); ok {
+				for _, index := range fn.Globals {
+					add(index)
+				}
+			}
+		}
+		ip += 1 + read
+	}
+	return globals
+}
+
+// declaredNames 返回函数体中用let声明的名字。内层函数和catch块有自己的作用域，其中的声明不计入
+func declaredNames(body *ast.BlockStatement) []string {
+	var names []string
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.CallExpression:
+			return node.Function.TokenLiteral() != "quote"
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, inspect)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, inspect)
+			}
+			return false
+		case *ast.LetStatement:
+			if node.Pattern != nil {
+				names = patternNames(node.Pattern, names)
+			} else {
+				names = append(names, node.Name.Value)
+			}
+		}
+		return true
+	}
+	ast.Inspect(body, inspect)
+	return names
+}
+
+func patternNames(pattern ast.Pattern, names []string) []string {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		names = append(names, pattern.Value)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			names = patternNames(element, names)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Value)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			names = patternNames(pair.Value, names)
+		}
+	}
+	return names
+}
+
+// compileTryExpression 编译try表达式。
+// 有finally时先注册一个处理器，出错时执行finally块后重新抛出；有catch时再注册一个处理器跳转到catch块
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	ctx := &tryContext{finally: node.Finally}
+
+	var finallyHandlerPos, catchHandlerPos int
+	if node.Finally != nil {
+		finallyHandlerPos = c.emit(code.OpSetupTry, 9999)
+		ctx.handlers++
+	}
+	if node.Catch != nil {
+		catchHandlerPos = c.emit(code.OpSetupTry, 9999)
+		ctx.handlers++
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, ctx)
+
+	if err := c.compileBlockValue(node.Block); err != nil {
+		return err
+	}
+
+	if node.Catch != nil {
+		c.emit(code.OpPopTry)
+		ctx.handlers--
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		// 虚拟机跳转到这里时已经注销了catch的处理器，并将错误值压栈
+		c.changeOperand(catchHandlerPos, len(c.currentInstructions()))
+		symbol, restore := c.symbolTable.Shadow(node.CatchParam.Value)
+		c.storeSymbol(symbol)
+		if err := c.compileBlockValue(node.Catch); err != nil {
+			return err
+		}
+		restore()
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+
+	if node.Finally != nil {
+		c.emit(code.OpPopTry)
+		if err := c.compileBlockValue(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		// 出错的路径：错误值在栈顶，执行finally块后重新抛出
+		c.changeOperand(finallyHandlerPos, len(c.currentInstructions()))
+		if err := c.compileBlockValue(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		c.emit(code.OpThrow)
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// emitReturn 从函数返回栈顶的值。途经的try要先注销错误处理器，再执行它们的finally块
+func (c *Compiler) emitReturn() error {
+	scope := &c.scopes[c.scopeIndex]
+	tries := scope.tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= 0; i-- {
+		for j := 0; j < tries[i].handlers; j++ {
+			c.emit(code.OpPopTry)
+		}
+		if tries[i].finally != nil {
+			// finally块中的return只需要经过更外层的try
+			c.scopes[c.scopeIndex].tries = tries[:i]
+			if err := c.compileBlockValue(tries[i].finally); err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+		}
+	}
+
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// compileBlockValue 编译块语句并在栈上留下它的值，最后一条语句不是表达式时留下null
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// compilePattern 将栈顶的值按照模式的形状绑定到变量上
+func (c *Compiler) compilePattern(pattern ast.Pattern) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		c.storeSymbol(c.symbolTable.Define(pattern.Value))
+
+	case *ast.ArrayPattern:
+		hasRest := 0
+		if pattern.Rest != nil {
+			hasRest = 1
+		}
+		source := c.addConstant(&object.String{Value: pattern.String()})
+		c.emit(code.OpArrayPattern, source, len(pattern.Elements), hasRest)
+		for _, element := range pattern.Elements {
+			if err := c.compilePattern(element); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			return c.compilePattern(pattern.Rest)
+		}
+
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: pair.Key.Value}))
+		}
+		source := c.addConstant(&object.String{Value: pattern.String()})
+		c.emit(code.OpHashPattern, source, len(pattern.Pairs))
+		for _, pair := range pattern.Pairs {
+			if err := c.compilePattern(pair.Value); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("cannot compile binding pattern %s", pattern.String())
+	}
+	return nil
+}
+
+// loadName 将名字的值压栈。找不到的名字依次当作内置函数和之后才定义的全局变量，
+// 这样函数可以引用在它之后定义的全局函数，真正不存在的名字在运行时报错
+func (c *Compiler) loadName(name string) {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		c.loadSymbol(symbol)
+		return
+	}
+	c.loadUnresolved(name)
+}
+
+// loadUnresolved 将外层都没有定义的名字当作内置函数或者之后才定义的全局变量
+func (c *Compiler) loadUnresolved(name string) {
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		index, ok := c.builtins[name]
+		if !ok {
+			index = c.addConstant(builtin)
+			c.builtins[name] = index
+		}
+		c.emit(code.OpConstant, index)
+		return
+	}
+	c.loadSymbol(c.symbolTable.Global().Define(name))
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	if s.Fallback != nil {
+		// 外层函数中之后才声明的变量，let执行之前读取外层的同名变量
+		pos := c.emit(code.OpGetFreeIfSet, s.Index, 9999)
+		c.loadSymbol(*s.Fallback)
+		c.changeOperand(pos, s.Index, len(c.currentInstructions()))
+		return
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	case UnresolvedScope:
+		c.loadUnresolved(s.Name)
+	}
+}
+
+// captureSymbol 为构造闭包压入自由变量。局部变量按引用捕获，之后的赋值对闭包可见，
+// 这样函数可以引用外层之后才定义的变量，例如相互调用的局部函数
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit 发出一条指令，返回它的起始位置
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// checkOperands 记录第一个超出编码宽度的操作数。Make会截断这样的操作数，得到的指令读写错误的位置
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || c.err != nil {
+		return
+	}
+	for i, operand := range operands {
+		limit := 1<<(8*def.OperandWidths[i]) - 1
+		if operand < 0 || operand > limit {
+			c.err = fmt.Errorf("%s, the limit is %d", operandLimit(op, i), limit)
+			return
+		}
+	}
+}
+
+// operandLimit 描述操作数计数的对象
+func operandLimit(op code.Opcode, i int) string {
+	switch op {
+	case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+		return "too many local variables in one function"
+	case code.OpCall:
+		return "too many arguments in one call"
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return "too many global variables"
+	case code.OpGetFree, code.OpCaptureFree:
+		return "too many free variables in one function"
+	case code.OpGetFreeIfSet:
+		if i == 0 {
+			return "too many free variables in one function"
+		}
+		return "jump offset too large"
+	case code.OpJump, code.OpJumpNotTruthy, code.OpSetupTry, code.OpUnwrap:
+		return "jump offset too large"
+	case code.OpArray:
+		return "too many elements in one array literal"
+	case code.OpHash:
+		return "too many keys and values in one hash literal"
+	case code.OpClosure:
+		if i == 1 {
+			return "too many free variables in one function"
+		}
+	case code.OpArrayPattern, code.OpHashPattern:
+		if i > 0 {
+			return "too many elements in one pattern"
+		}
+	}
+	return "too many constants"
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+
+	// 只在位置变化时记录，一个位置覆盖之后的所有指令
+	m := scope.sourceMap
+	if len(m) == 0 || m[len(m)-1].Line != c.position.line || m[len(m)-1].Position != c.position.text {
+		scope.sourceMap = append(m, code.SourcePosition{
+			Offset:   posNewInstruction,
+			Line:     c.position.line,
+			Position: c.position.text,
+		})
+	}
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction
+	previous := scope.previousInstruction
+
+	scope.instructions = scope.instructions[:last.Position]
+	scope.lastInstruction = previous
+
+	// 删除只覆盖被删除指令的位置记录
+	i := sort.Search(len(scope.sourceMap), func(i int) bool { return scope.sourceMap[i].Offset >= last.Position })
+	scope.sourceMap = scope.sourceMap[:i]
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// changeOperand 回填跳转指令的操作数
+// changeOperand 用新的操作数替换opPos处的指令，用于回填跳转的位置
+func (c *Compiler) changeOperand(opPos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, operands)
+	newInstruction := code.Make(op, operands...)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// Bytecode 编译的结果
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	SourceMap    code.SourceMap
+	GlobalNames  []string // GlobalNames 全局变量的名字，用于报告未定义的变量
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		GlobalNames:  c.symbolTable.Global().Names(),
+	}
+}
@@ -0,0 +1,124 @@
+package compiler
+
+import (
+	"Interp/code"
+	"Interp/object"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Disassemble 将字节码转换为可读的文本，先输出主程序，再按常量池的顺序输出每个函数。
+// 每条指令包含偏移量、操作数以及常量、全局变量等引用的说明。
+// source不为空时，在每一行源代码对应的指令之前标注这一行源代码，否则只标注行号
+func Disassemble(b *Bytecode, source string) string {
+	d := &disassembler{bytecode: b}
+	if source != "" {
+		d.lines = strings.Split(source, "\n")
+	}
+
+	d.function("<main>", b.Instructions, b.SourceMap)
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		d.out.WriteString("\n")
+		header := fmt.Sprintf("%s (constant %d, params=%d, locals=%d, free=%d)",
+			functionName(fn), i, fn.NumParameters, fn.NumLocals, fn.NumFree)
+		d.function(header, fn.Instructions, fn.SourceMap)
+	}
+
+	return d.out.String()
+}
+
+type disassembler struct {
+	bytecode *Bytecode
+	lines    []string
+	out      bytes.Buffer
+}
+
+func (d *disassembler) function(header string, ins code.Instructions, sourceMap code.SourceMap) {
+	fmt.Fprintf(&d.out, "== %s ==\n", header)
+
+	line := 0
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			fmt.Fprintf(&d.out, "%04d ERROR: %s\n", ip, err)
+			ip++
+			continue
+		}
+
+		if pos, ok := sourceMap.Lookup(ip); ok && pos.Line != line {
+			line = pos.Line
+			d.annotateLine(pos)
+		}
+
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+		text := def.Name
+		for _, operand := range operands {
+			text += " " + strconv.Itoa(operand)
+		}
+
+		if comment := d.comment(code.Opcode(ins[ip]), operands); comment != "" {
+			fmt.Fprintf(&d.out, "%04d %-24s ; %s\n", ip, text, comment)
+		} else {
+			fmt.Fprintf(&d.out, "%04d %s\n", ip, text)
+		}
+		ip += 1 + read
+	}
+}
+
+// annotateLine 输出源代码行，宏展开的代码同时给出完整的位置
+func (d *disassembler) annotateLine(pos code.SourcePosition) {
+	text := fmt.Sprintf("line %d", pos.Line)
+	if pos.Line <= len(d.lines) {
+		text = fmt.Sprintf("%d: %s", pos.Line, strings.TrimSpace(d.lines[pos.Line-1]))
+	}
+	if strings.Contains(pos.Position, "expanded from") {
+		text += " [" + pos.Position + "]"
+	}
+	fmt.Fprintf(&d.out, "; %s\n", text)
+}
+
+// comment 说明操作数引用的内容
+func (d *disassembler) comment(op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant, code.OpClosure, code.OpArrayPattern, code.OpHashPattern:
+		return d.constant(operands[0])
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if operands[0] < len(d.bytecode.GlobalNames) {
+			return d.bytecode.GlobalNames[operands[0]]
+		}
+	case code.OpJump, code.OpJumpNotTruthy, code.OpSetupTry, code.OpUnwrap:
+		return fmt.Sprintf("-> %04d", operands[0])
+	case code.OpGetFreeIfSet:
+		return fmt.Sprintf("-> %04d", operands[1])
+	}
+	return ""
+}
+
+func (d *disassembler) constant(index int) string {
+	if index >= len(d.bytecode.Constants) {
+		return "missing constant"
+	}
+	switch constant := d.bytecode.Constants[index].(type) {
+	case *object.String:
+		return strconv.Quote(constant.Value)
+	case *object.CompiledFunction:
+		return functionName(constant)
+	case *object.Builtin:
+		return "builtin " + constant.Name
+	default:
+		return constant.Inspect()
+	}
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "fn <anonymous>"
+	}
+	return "fn " + fn.Name
+}
@@ -0,0 +1,75 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+puts(add(1, "two"));`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := `== <main> ==
+; 1: let add = fn(a, b) { a + b };
+0000 OpClosure 0 0            ; fn add
+0004 OpSetGlobal 0            ; add
+; 2: puts(add(1, "two"));
+0007 OpConstant 1             ; builtin puts
+0010 OpGetGlobal 0            ; add
+0013 OpConstant 2             ; 1
+0016 OpConstant 3             ; "two"
+0019 OpCall 2
+0021 OpCall 1
+0023 OpPop
+
+== fn add (constant 0, params=2, locals=2, free=0) ==
+; 1: let add = fn(a, b) { a + b };
+0000 OpGetLocal 0
+0002 OpGetLocal 1
+0004 OpAdd
+0005 OpReturnValue
+`
+	if got := Disassemble(compiler.Bytecode(), input); got != expected {
+		t.Errorf("wrong disassembly.\nwant=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestDisassembleAnnotations(t *testing.T) {
+	input := `let x = if (true) { 1 } else { 2 };
+let f = fn() { let [a, ...b] = [x]; a };`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	tests := []struct {
+		source   string
+		expected []string
+	}{
+		{input, []string{
+			"; 1: let x = if (true) { 1 } else { 2 };",
+			"OpJumpNotTruthy 10       ; -> 0010",
+			"OpSetGlobal 0            ; x",
+			"== fn f (constant 3, params=0, locals=2, free=0) ==",
+			"OpArrayPattern 2 1 1     ; \"[a, ...b]\"",
+		}},
+		// 没有源代码时只标注行号
+		{"", []string{"; line 1", "; line 2"}},
+	}
+
+	for _, tt := range tests {
+		got := Disassemble(bytecode, tt.source)
+		for _, want := range tt.expected {
+			if !strings.Contains(got, want) {
+				t.Errorf("disassembly does not contain %q:\n%s", want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package compiler
+
+import (
+	"Interp/code"
+	"Interp/evaluator"
+	"Interp/object"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// 预编译文件的格式：
+//
+//	magic    4字节，"MKC\x00"
+//	version  2字节，大端序
+//	主程序的指令和位置表
+//	全局变量的名字
+//	常量池，每个常量以一个字节的类型标记开头
+//
+// 整数使用varint编码，字符串和指令先写入长度。修改指令集或格式时必须增加FormatVersion
+const FormatVersion = 8
+
+var magic = []byte("MKC\x00")
+
+// 常量的类型标记
+const (
+	tagInteger  byte = 'i'
+	tagBigInt   byte = 'I' // 超出int64的整数保存十进制的字符串
+	tagDecimal  byte = 'd' // 小数保存数字的字符串和小数位数
+	tagString   byte = 's'
+	tagBuiltin  byte = 'b' // 内置函数只保存名字，加载时重新查找
+	tagFunction byte = 'f'
+)
+
+// IsCompiled 判断data是否是预编译的字节码
+func IsCompiled(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// MarshalBinary 将字节码编码为预编译文件的格式
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.buf = append(e.buf, magic...)
+	e.buf = binary.BigEndian.AppendUint16(e.buf, FormatVersion)
+
+	e.instructions(b.Instructions)
+	e.sourceMap(b.SourceMap)
+
+	e.uvarint(uint64(len(b.GlobalNames)))
+	for _, name := range b.GlobalNames {
+		e.string(name)
+	}
+
+	e.uvarint(uint64(len(b.Constants)))
+	for i, constant := range b.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			e.buf = append(e.buf, tagInteger)
+			e.buf = binary.AppendVarint(e.buf, constant.Value)
+		case *object.BigInt:
+			e.buf = append(e.buf, tagBigInt)
+			e.string(constant.Value.String())
+		case *object.Decimal:
+			e.buf = append(e.buf, tagDecimal)
+			e.string(constant.Value.String())
+			e.uvarint(uint64(constant.Scale))
+		case *object.String:
+			e.buf = append(e.buf, tagString)
+			e.string(constant.Value)
+		case *object.Builtin:
+			e.buf = append(e.buf, tagBuiltin)
+			e.string(constant.Name)
+		case *object.CompiledFunction:
+			e.buf = append(e.buf, tagFunction)
+			e.string(constant.Name)
+			e.uvarint(uint64(constant.NumLocals))
+			e.uvarint(uint64(constant.NumParameters))
+			e.uvarint(uint64(constant.NumFree))
+			e.instructions(constant.Instructions)
+			e.sourceMap(constant.SourceMap)
+			e.uvarint(uint64(len(constant.LocalNames)))
+			for _, name := range constant.LocalNames {
+				e.string(name)
+			}
+			e.string(constant.Source)
+		default:
+			return nil, fmt.Errorf("constant %d: cannot encode %s", i, constant.Type())
+		}
+	}
+
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码预编译文件，拒绝不兼容的版本和损坏的数据
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	if !IsCompiled(data) {
+		return errors.New("not a compiled script")
+	}
+	data = data[len(magic):]
+	if len(data) < 2 {
+		return errors.New("truncated bytecode")
+	}
+	if version := binary.BigEndian.Uint16(data); version != FormatVersion {
+		return fmt.Errorf("incompatible bytecode version %d, expected version %d", version, FormatVersion)
+	}
+
+	d := &decoder{data: data[2:]}
+	decoded := &Bytecode{}
+	decoded.Instructions = d.instructions()
+	decoded.SourceMap = d.sourceMap()
+
+	numGlobals := d.length()
+	for i := 0; i < numGlobals && d.err == nil; i++ {
+		decoded.GlobalNames = append(decoded.GlobalNames, d.string())
+	}
+
+	numConstants := d.length()
+	for i := 0; i < numConstants && d.err == nil; i++ {
+		decoded.Constants = append(decoded.Constants, d.constant())
+	}
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = errors.New("unexpected data after constants")
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid bytecode: %w", d.err)
+	}
+
+	if err := decoded.validate(); err != nil {
+		return fmt.Errorf("invalid bytecode: %w", err)
+	}
+	// 函数读取的全局变量由指令决定，不保存在文件中。内层函数在常量池中位于外层函数之前
+	for _, constant := range decoded.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fn.Globals = globalReads(fn.Instructions, decoded.Constants)
+		}
+	}
+	*b = *decoded
+	return nil
+}
+
+// validate 检查指令引用的内容都存在，避免损坏的文件导致虚拟机崩溃或者读写不属于它的数据：
+// 每条指令都有定义，跳转的目标是指令的开头，局部变量和自由变量不超过函数声明的个数，
+// 全局变量不超过全局变量的个数，引用的常量存在并且类型正确
+func (b *Bytecode) validate() error {
+	if err := b.validateInstructions(b.Instructions, 0, 0, true); err != nil {
+		return err
+	}
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("constant %d: function has %d parameters but only %d local variables", i, fn.NumParameters, fn.NumLocals)
+		}
+		if err := b.validateInstructions(fn.Instructions, fn.NumLocals, fn.NumFree, false); err != nil {
+			return fmt.Errorf("constant %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateInstructions 检查一段指令。主程序执行到末尾时结束，可以跳转到指令的末尾；函数总是以返回指令结束
+func (b *Bytecode) validateInstructions(ins code.Instructions, numLocals, numFree int, main bool) error {
+	starts := make(map[int]bool)
+	var jumps [][2]int // 跳转指令的位置和目标
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			return err
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if ip+1+width > len(ins) {
+			return fmt.Errorf("truncated instruction %s at %d", def.Name, ip)
+		}
+		starts[ip] = true
+		operands, _ := code.ReadOperands(def, ins[ip+1:])
+
+		switch code.Opcode(ins[ip]) {
+		case code.OpImport:
+			for _, operand := range operands {
+				if err := b.checkConstant(def, ip, operand); err != nil {
+					return err
+				}
+				if _, ok := b.Constants[operand].(*object.String); !ok {
+					return fmt.Errorf("%s at %d refers to a constant that is not a path", def.Name, ip)
+				}
+			}
+		case code.OpConstant:
+			if err := b.checkConstant(def, ip, operands[0]); err != nil {
+				return err
+			}
+		case code.OpArrayPattern, code.OpHashPattern:
+			if err := b.checkConstant(def, ip, operands[0]); err != nil {
+				return err
+			}
+			if _, ok := b.Constants[operands[0]].(*object.String); !ok {
+				return fmt.Errorf("%s at %d refers to a constant that is not a pattern", def.Name, ip)
+			}
+		case code.OpClosure:
+			if err := b.checkConstant(def, ip, operands[0]); err != nil {
+				return err
+			}
+			fn, ok := b.Constants[operands[0]].(*object.CompiledFunction)
+			if !ok || fn.NumFree != operands[1] {
+				return fmt.Errorf("%s at %d does not match its function", def.Name, ip)
+			}
+		case code.OpGetGlobal, code.OpSetGlobal:
+			if operands[0] >= len(b.GlobalNames) {
+				return fmt.Errorf("%s at %d refers to missing global %d", def.Name, ip, operands[0])
+			}
+		case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+			if operands[0] >= numLocals {
+				return fmt.Errorf("%s at %d refers to missing local %d", def.Name, ip, operands[0])
+			}
+		case code.OpGetFree, code.OpCaptureFree:
+			if operands[0] >= numFree {
+				return fmt.Errorf("%s at %d refers to missing free variable %d", def.Name, ip, operands[0])
+			}
+		case code.OpGetFreeIfSet:
+			if operands[0] >= numFree {
+				return fmt.Errorf("%s at %d refers to missing free variable %d", def.Name, ip, operands[0])
+			}
+			jumps = append(jumps, [2]int{ip, operands[1]})
+		case code.OpJump, code.OpJumpNotTruthy, code.OpSetupTry, code.OpUnwrap:
+			jumps = append(jumps, [2]int{ip, operands[0]})
+		}
+		ip += 1 + width
+	}
+
+	for _, jump := range jumps {
+		if !starts[jump[1]] && !(main && jump[1] == len(ins)) {
+			def, _ := code.Lookup(ins[jump[0]])
+			return fmt.Errorf("%s at %d jumps to %d, which is not the start of an instruction", def.Name, jump[0], jump[1])
+		}
+	}
+	return nil
+}
+
+func (b *Bytecode) checkConstant(def *code.Definition, ip int, index int) error {
+	if index >= len(b.Constants) {
+		return fmt.Errorf("%s at %d refers to missing constant %d", def.Name, ip, index)
+	}
+	return nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) instructions(ins code.Instructions) {
+	e.uvarint(uint64(len(ins)))
+	e.buf = append(e.buf, ins...)
+}
+
+func (e *encoder) sourceMap(m code.SourceMap) {
+	e.uvarint(uint64(len(m)))
+	for _, pos := range m {
+		e.uvarint(uint64(pos.Offset))
+		e.uvarint(uint64(pos.Line))
+		e.string(pos.Position)
+	}
+}
+
+// decoder 依次读取数据，第一次出错后的读取都返回零值，最后统一检查err
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("malformed integer")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail("malformed integer")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// length 读取一个长度，长度不能超过剩余的数据，避免损坏的文件导致分配过多的内存
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail("length %d exceeds remaining data", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.length()
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) instructions() code.Instructions {
+	return code.Instructions(d.bytes())
+}
+
+func (d *decoder) sourceMap() code.SourceMap {
+	n := d.length()
+	var m code.SourceMap
+	for i := 0; i < n && d.err == nil; i++ {
+		offset := d.uvarint()
+		line := d.uvarint()
+		m = append(m, code.SourcePosition{Offset: int(offset), Line: int(line), Position: d.string()})
+	}
+	return m
+}
+
+func (d *decoder) constant() object.Object {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) == 0 {
+		d.fail("truncated constant pool")
+		return nil
+	}
+	tag := d.data[0]
+	d.data = d.data[1:]
+
+	switch tag {
+	case tagInteger:
+		return &object.Integer{Value: d.varint()}
+	case tagBigInt:
+		digits := d.string()
+		value, ok := new(big.Int).SetString(digits, 10)
+		if (!ok || value.IsInt64()) && d.err == nil {
+			d.fail("malformed big integer %q", digits)
+		}
+		return &object.BigInt{Value: value}
+	case tagDecimal:
+		digits := d.string()
+		scale := d.uvarint()
+		value, ok := new(big.Int).SetString(digits, 10)
+		if !ok && d.err == nil {
+			d.fail("malformed decimal %q", digits)
+		}
+		return &object.Decimal{Value: value, Scale: int(scale)}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagBuiltin:
+		name := d.string()
+		builtin, ok := evaluator.LookupBuiltin(name)
+		if !ok && d.err == nil {
+			d.fail("unknown builtin function %s", name)
+		}
+		return builtin
+	case tagFunction:
+		fn := &object.CompiledFunction{Name: d.string()}
+		fn.NumLocals = int(d.uvarint())
+		fn.NumParameters = int(d.uvarint())
+		fn.NumFree = int(d.uvarint())
+		fn.Instructions = d.instructions()
+		fn.SourceMap = d.sourceMap()
+		numLocals := d.length()
+		for i := 0; i < numLocals && d.err == nil; i++ {
+			fn.LocalNames = append(fn.LocalNames, d.string())
+		}
+		fn.Source = d.string()
+		return fn
+	default:
+		d.fail("unknown constant tag %q", tag)
+		return nil
+	}
+}
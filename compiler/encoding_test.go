@@ -0,0 +1,157 @@
+package compiler
+
+import (
+	"Interp/code"
+	"Interp/object"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+let adder = fn(x) { fn(y) { add(x, y) } };
+let [first, ...rest] = [adder(1)(2), "two", len("three")];
+try { throw "x" } catch (e) { e["message"] } finally { first };
+let total = -12.50d * 3 // 4;
+let huge = 18446744073709551616;`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	original := compiler.Bytecode()
+
+	data, err := original.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+	if !IsCompiled(data) {
+		t.Fatalf("encoded bytecode does not start with the magic header")
+	}
+
+	decoded := &Bytecode{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %s", err)
+	}
+
+	if decoded.Instructions.String() != original.Instructions.String() {
+		t.Errorf("instructions differ.\nwant=%q\ngot =%q", original.Instructions, decoded.Instructions)
+	}
+	testSourceMapsEqual(t, original.SourceMap, decoded.SourceMap)
+	if strings.Join(decoded.GlobalNames, ",") != strings.Join(original.GlobalNames, ",") {
+		t.Errorf("global names differ. want=%v, got=%v", original.GlobalNames, decoded.GlobalNames)
+	}
+
+	if len(decoded.Constants) != len(original.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(original.Constants), len(decoded.Constants))
+	}
+	for i, want := range original.Constants {
+		got := decoded.Constants[i]
+		switch want := want.(type) {
+		case *object.CompiledFunction:
+			fn, ok := got.(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("constant %d is not a function. got=%T", i, got)
+				continue
+			}
+			if fn.Name != want.Name || fn.NumLocals != want.NumLocals ||
+				fn.NumParameters != want.NumParameters || fn.NumFree != want.NumFree {
+				t.Errorf("constant %d: function header differs. want=%+v, got=%+v", i, want, fn)
+			}
+			if fn.Instructions.String() != want.Instructions.String() {
+				t.Errorf("constant %d: instructions differ", i)
+			}
+			testSourceMapsEqual(t, want.SourceMap, fn.SourceMap)
+		case *object.Builtin:
+			if got != want {
+				t.Errorf("constant %d: builtin %s was not resolved to the same function", i, want.Name)
+			}
+		default:
+			if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+				t.Errorf("constant %d differs. want=%s, got=%s", i, want.Inspect(), got.Inspect())
+			}
+		}
+	}
+}
+
+func TestUnmarshalRejectsBadInput(t *testing.T) {
+	valid, err := (&Bytecode{
+		Instructions: code.Make(code.OpConstant, 0),
+		Constants:    []object.Object{&object.Integer{Value: 1}},
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+
+	newerVersion := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(newerVersion[len(magic):], FormatVersion+1)
+
+	missingConstant, _ := (&Bytecode{Instructions: code.Make(code.OpConstant, 3)}).MarshalBinary()
+
+	unknownBuiltin, _ := (&Bytecode{
+		Constants: []object.Object{&object.Builtin{Name: "no_such_builtin"}},
+	}).MarshalBinary()
+
+	// 手工构造的函数，instructions是函数体
+	function := func(numLocals, numFree int, instructions ...code.Instructions) []byte {
+		fn := &object.CompiledFunction{NumLocals: numLocals, NumFree: numFree, Instructions: concatInstructions(instructions)}
+		data, _ := (&Bytecode{Constants: []object.Object{fn}}).MarshalBinary()
+		return data
+	}
+	program := func(globals []string, instructions ...code.Instructions) []byte {
+		data, _ := (&Bytecode{Instructions: concatInstructions(instructions), GlobalNames: globals}).MarshalBinary()
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"try target out of range", program(nil, code.Make(code.OpSetupTry, 9999), code.Make(code.OpPopTry)),
+			"invalid bytecode: OpSetupTry at 0 jumps to 9999, which is not the start of an instruction"},
+		{"jump into an instruction", program(nil, code.Make(code.OpJump, 5), code.Make(code.OpNull), code.Make(code.OpJump, 0)),
+			"invalid bytecode: OpJump at 0 jumps to 5, which is not the start of an instruction"},
+		{"jump past a function", function(0, 0, code.Make(code.OpJumpNotTruthy, 4), code.Make(code.OpReturn)),
+			"invalid bytecode: constant 0: OpJumpNotTruthy at 0 jumps to 4, which is not the start of an instruction"},
+		{"unwrap target", function(1, 0, code.Make(code.OpGetLocal, 0), code.Make(code.OpUnwrap, 1), code.Make(code.OpReturnValue)),
+			"invalid bytecode: constant 0: OpUnwrap at 2 jumps to 1, which is not the start of an instruction"},
+		{"free fallback target", function(0, 1, code.Make(code.OpGetFreeIfSet, 0, 100), code.Make(code.OpReturn)),
+			"invalid bytecode: constant 0: OpGetFreeIfSet at 0 jumps to 100, which is not the start of an instruction"},
+		{"missing local", function(1, 0, code.Make(code.OpGetLocal, 9), code.Make(code.OpReturnValue)),
+			"invalid bytecode: constant 0: OpGetLocal at 0 refers to missing local 9"},
+		{"local in main program", program(nil, code.Make(code.OpSetLocal, 0)),
+			"invalid bytecode: OpSetLocal at 0 refers to missing local 0"},
+		{"missing free variable", function(0, 0, code.Make(code.OpGetFree, 5), code.Make(code.OpReturnValue)),
+			"invalid bytecode: constant 0: OpGetFree at 0 refers to missing free variable 5"},
+		{"missing global", program([]string{"a"}, code.Make(code.OpGetGlobal, 1), code.Make(code.OpPop)),
+			"invalid bytecode: OpGetGlobal at 0 refers to missing global 1"},
+		{"source file", []byte("let a = 1;"), "not a compiled script"},
+		{"newer version", newerVersion, "incompatible bytecode version 9, expected version 8"},
+		{"truncated", valid[:len(valid)-1], "invalid bytecode: malformed integer"},
+		{"trailing data", append(append([]byte{}, valid...), 0), "invalid bytecode: unexpected data after constants"},
+		{"missing constant", missingConstant, "invalid bytecode: OpConstant at 0 refers to missing constant 3"},
+		{"unknown builtin", unknownBuiltin, "invalid bytecode: unknown builtin function no_such_builtin"},
+	}
+
+	for _, tt := range tests {
+		err := (&Bytecode{}).UnmarshalBinary(tt.data)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. want=%q, got=%v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func testSourceMapsEqual(t *testing.T, want, got code.SourceMap) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Errorf("source maps differ. want=%+v, got=%+v", want, got)
+		return
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("source position %d differs. want=%+v, got=%+v", i, want[i], got[i])
+		}
+	}
+}
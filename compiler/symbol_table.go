@@ -0,0 +1,214 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION" // FunctionScope 函数在自己体内引用自己的名字
+	// UnresolvedScope 外层都没有定义的名字，只作为Fallback出现，由编译器当作内置函数或之后才定义的全局变量
+	UnresolvedScope SymbolScope = "UNRESOLVED"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Fallback 名字在外层函数中之后才声明时，那里的let还没有执行前改为读取的变量，与解释器按名字查找的结果一致
+	Fallback *Symbol
+}
+
+// SymbolTable 记录一个作用域中的名字和它们的存储位置，每个函数有自己的符号表
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string // names 按下标记录每个存储位置的名字，匿名的位置为空
+
+	// declared 函数体中用let声明的名字。内层函数引用这些名字时，即使let还没有编译也解析为这里的局部变量，
+	// 提前分配的位置记录在reserved中，编译到let时才对这个函数自己可见，与解释器的作用域一致
+	declared map[string]bool
+	reserved map[string]Symbol
+
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s, reserved: make(map[string]Symbol)}
+}
+
+// NewSymbolTableFrom 创建从第offset个位置开始分配全局变量的符号表，
+// 多个模块共享同一组全局变量时各自使用不重叠的位置
+func NewSymbolTableFrom(offset int) *SymbolTable {
+	s := NewSymbolTable()
+	s.numDefinitions = offset
+	s.names = make([]string, offset)
+	return s
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Declare 记录函数体中用let声明的名字，见declared
+func (s *SymbolTable) Declare(names []string) {
+	s.declared = make(map[string]bool, len(names))
+	for _, name := range names {
+		s.declared[name] = true
+	}
+}
+
+// Define 定义一个名字。同一作用域中已经定义过的名字沿用原来的位置，与解释器中let覆盖原来的绑定一致
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+	if symbol, ok := s.reserved[name]; ok {
+		// 从这里开始let已经执行，函数自己和之后定义的内层函数直接读取这个变量
+		delete(s.reserved, name)
+		symbol.Fallback = nil
+		s.store[name] = symbol
+		return symbol
+	}
+	return s.DefineNew(name)
+}
+
+// DefineNew 总是为名字分配一个新的位置，用于函数参数
+func (s *SymbolTable) DefineNew(name string) Symbol {
+	symbol := s.allocate(name)
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineHidden 分配一个不能通过名字访问的位置，用于需要解构的参数
+func (s *SymbolTable) DefineHidden() Symbol {
+	return s.allocate("")
+}
+
+// Shadow 在一段代码中用新的位置遮蔽名字，调用返回的函数恢复原来的绑定，用于catch的参数
+func (s *SymbolTable) Shadow(name string) (Symbol, func()) {
+	previous, ok := s.store[name]
+	symbol := s.DefineNew(name)
+	return symbol, func() {
+		if ok {
+			s.store[name] = previous
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) allocate(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+	s.numDefinitions++
+	s.names = append(s.names, name)
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Resolve 查找名字，外层函数的局部变量会被定义为当前函数的自由变量
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	return s.resolve(name, false)
+}
+
+// resolve inner为true时名字来自内层函数，这个函数之后才声明的变量也可见
+func (s *SymbolTable) resolve(name string, inner bool) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if inner && s.declared[name] && (!ok || obj.Scope != LocalScope) {
+		return s.reserve(name, obj, ok), true
+	}
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.resolve(name, true)
+		if !ok {
+			return obj, ok
+		}
+
+		if obj.Scope == GlobalScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		if obj.Fallback != nil {
+			free.Fallback = s.captureFallback(*obj.Fallback)
+			s.store[name] = free
+		}
+		return free, true
+	}
+	return obj, ok
+}
+
+// reserve 为之后才声明的变量提前分配位置。current是这个函数中这个名字现有的绑定，
+// 没有时按外层的作用域解析，作为let执行之前读取的变量
+func (s *SymbolTable) reserve(name string, current Symbol, ok bool) Symbol {
+	if symbol, ok := s.reserved[name]; ok {
+		return symbol
+	}
+	symbol := s.allocate(name)
+	switch {
+	case ok:
+		symbol.Fallback = &current
+	case s.Outer != nil:
+		if outer, found := s.Outer.resolve(name, true); found {
+			symbol.Fallback = s.captureFallback(outer)
+		}
+	}
+	if symbol.Fallback == nil {
+		symbol.Fallback = &Symbol{Name: name, Scope: UnresolvedScope}
+	}
+	s.reserved[name] = symbol
+	return symbol
+}
+
+// captureFallback 把外层的符号转换为这个函数中的符号，外层函数的变量作为匿名的自由变量捕获，
+// 不改变名字本身的绑定
+func (s *SymbolTable) captureFallback(outer Symbol) *Symbol {
+	if outer.Scope == GlobalScope || outer.Scope == UnresolvedScope {
+		return &outer
+	}
+	s.FreeSymbols = append(s.FreeSymbols, outer)
+	symbol := Symbol{Name: outer.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	if outer.Fallback != nil {
+		symbol.Fallback = s.captureFallback(*outer.Fallback)
+	}
+	return &symbol
+}
+
+// Global 返回最外层的符号表
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// Names 按下标返回每个位置的名字
+func (s *SymbolTable) Names() []string {
+	names := make([]string, len(s.names))
+	copy(names, s.names)
+	return names
+}
@@ -0,0 +1,109 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a in the same scope should reuse its slot. got=%+v, want=%+v", again, a)
+	}
+	global.Define("b")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+	firstLocal.Define("d")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{firstLocal, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{firstLocal, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{secondLocal, "b", Symbol{Name: "b", Scope: GlobalScope, Index: 1}},
+		{secondLocal, "d", Symbol{Name: "d", Scope: FreeScope, Index: 0}},
+		{secondLocal, "c", Symbol{Name: "c", Scope: FreeScope, Index: 1}},
+		{secondLocal, "e", Symbol{Name: "e", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		result, ok := tt.table.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, result)
+		}
+	}
+
+	expectedFree := []Symbol{
+		{Name: "d", Scope: LocalScope, Index: 1},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for i, sym := range expectedFree {
+		if secondLocal.FreeSymbols[i] != sym {
+			t.Errorf("wrong free symbol. got=%+v, want=%+v", secondLocal.FreeSymbols[i], sym)
+		}
+	}
+}
+
+func TestShadow(t *testing.T) {
+	global := NewSymbolTable()
+	e := global.Define("e")
+
+	shadow, restore := global.Shadow("e")
+	if shadow.Index == e.Index {
+		t.Fatalf("shadowed name should get a new slot. got=%+v", shadow)
+	}
+	if resolved, _ := global.Resolve("e"); resolved != shadow {
+		t.Errorf("expected e to resolve to the shadow. got=%+v", resolved)
+	}
+
+	restore()
+	if resolved, _ := global.Resolve("e"); resolved != e {
+		t.Errorf("expected e to be restored. got=%+v", resolved)
+	}
+
+	_, restore = global.Shadow("x")
+	restore()
+	if _, ok := global.Resolve("x"); ok {
+		t.Errorf("x should not be resolvable after restore")
+	}
+
+	expectedNames := []string{"e", "e", "x"}
+	for i, name := range global.Names() {
+		if name != expectedNames[i] {
+			t.Errorf("wrong name at %d. want=%q, got=%q", i, expectedNames[i], name)
+		}
+	}
+}
+
+func TestDefineHiddenAndFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(global)
+	local.DefineFunctionName("f")
+	hidden := local.DefineHidden()
+
+	if hidden != (Symbol{Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong hidden symbol. got=%+v", hidden)
+	}
+	if f, _ := local.Resolve("f"); f != (Symbol{Name: "f", Scope: FunctionScope, Index: 0}) {
+		t.Errorf("wrong function name symbol. got=%+v", f)
+	}
+}
+
+func TestSymbolTableFrom(t *testing.T) {
+	global := NewSymbolTableFrom(2)
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 2}) {
+		t.Errorf("wrong symbol. got=%+v", a)
+	}
+	if names := global.Names(); len(names) != 3 || names[2] != "a" {
+		t.Errorf("wrong names. got=%q", names)
+	}
+}
@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"math"
+	"math/big"
+)
+
+func isInteger(obj object.Object) bool {
+	t := obj.Type()
+	return t == object.INTEGER_OBJ || t == object.BIGINT_OBJ
+}
+
+// int64Arithmetic 计算int64的四则运算，结果溢出时返回false和回绕之后的值。调用前已经排除了除以零
+func int64Arithmetic(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := left + right
+		return result, (result > left) == (right > 0)
+	case "-":
+		result := left - right
+		return result, (result < left) == (right > 0)
+	case "*":
+		result := left * right
+		if left == 0 || right == 0 {
+			return result, true
+		}
+		return result, result/right == left && !(right == -1 && left == math.MinInt64)
+	default:
+		result := left / right
+		return result, !(right == -1 && left == math.MinInt64)
+	}
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+// normalizeBigInt 能用int64表示的结果转换回Integer
+func normalizeBigInt(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// evalBigIntInfixExpression 用任意精度计算整数运算，除法与int64一样向零取整
+func evalBigIntInfixExpression(operator string, leftValue, rightValue *big.Int, left, right object.Object) object.Object {
+	switch operator {
+	case "+":
+		return normalizeBigInt(new(big.Int).Add(leftValue, rightValue))
+	case "-":
+		return normalizeBigInt(new(big.Int).Sub(leftValue, rightValue))
+	case "*":
+		return normalizeBigInt(new(big.Int).Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return newError(object.ARITHMETIC_ERROR, "division by zero")
+		}
+		return normalizeBigInt(new(big.Int).Quo(leftValue, rightValue))
+	case "==":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) != 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) > 0)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) < 0)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
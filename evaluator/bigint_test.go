@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"Interp/lexer"
+	"Interp/object"
+	"Interp/parser"
+	"testing"
+)
+
+func testEvalWithRuntime(input string, rt *object.Runtime) object.Object {
+	program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+	NewResolver().Resolve(program)
+	return Eval(program, object.NewEnvironmentWithRuntime(rt))
+}
+
+func TestBigIntPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`, "15511210043330985984000000"},
+		{"(9223372036854775807 + 10) / 3", "3074457345618258605"},
+		{"-(9223372036854775807 + 1) / 7", "-1317624576693539401"},
+		// 超出int64的字面值直接得到BigInt
+		{"9223372036854775808", "9223372036854775808"},
+		{"18446744073709551616 * 2", "36893488147419103232"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s (%T)", tt.input, tt.expected, evaluated.Inspect(), evaluated)
+		}
+	}
+}
+
+func TestBigIntDemotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807 + 1 - 1", 9223372036854775807},
+		{"(4294967296 * 4294967296) / 4294967296", 4294967296},
+		{"let big = 9223372036854775807 * 2; big - big", 0},
+		{"-(-(9223372036854775807 + 1)) - 9223372036854775807", 1},
+		{"-9223372036854775808", -9223372036854775808},
+		{"18446744073709551616 - 18446744073709551615", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"9223372036854775807 < 9223372036854775807 + 1", true},
+		{"-9223372036854775807 - 2 < 0", true},
+		{"9223372036854775807 + 1 == 9223372036854775807 + 1", true},
+		{"9223372036854775807 + 1 != 9223372036854775807", true},
+		{"9223372036854775807 + 1 == 9223372036854775807", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	input := `let h = {9223372036854775807 + 1: "pos", -9223372036854775807 - 2: "neg"};
+[h[9223372036854775807 + 1], h[-9223372036854775807 - 2], h[9223372036854775807]]`
+	evaluated := testEval(input)
+	if evaluated.Inspect() != `[pos, neg, null]` {
+		t.Errorf("wrong result. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestIntegerModes(t *testing.T) {
+	tests := []struct {
+		input    string
+		mode     object.IntegerMode
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", object.IntegerWrap, int64(-9223372036854775808)},
+		{"4294967296 * 4294967296", object.IntegerWrap, int64(0)},
+		{"-(-9223372036854775807 - 1)", object.IntegerWrap, int64(-9223372036854775808)},
+		{"(-9223372036854775807 - 1) / -1", object.IntegerWrap, int64(-9223372036854775808)},
+		{"9223372036854775807 + 1", object.IntegerChecked, "integer overflow: 9223372036854775807 + 1"},
+		{"4294967296 * -4294967296", object.IntegerChecked, "integer overflow: 4294967296 * -4294967296"},
+		{"-(-9223372036854775807 - 1)", object.IntegerChecked, "integer overflow: -(-9223372036854775808)"},
+		{"1 / 0", object.IntegerChecked, "division by zero"},
+		{"9223372036854775807 - 1", object.IntegerChecked, int64(9223372036854775806)},
+		{"(9223372036854775807 + 1) / 0", object.IntegerPromote, "division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(tt.input, &object.Runtime{IntegerMode: tt.mode})
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Kind != object.ARITHMETIC_ERROR || errObj.Message != expected {
+				t.Errorf("%s: wrong error. expected=%q, got=%s %q", tt.input, expected, errObj.Kind, errObj.Message)
+			}
+		}
+	}
+}
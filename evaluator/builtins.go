@@ -0,0 +1,340 @@
+package evaluator
+
+import (
+	"Interp/ast"
+	"Interp/object"
+	"Interp/token"
+	"fmt"
+	"sort"
+)
+
+// builtins 内置函数表，在环境中找不到标识符时查找
+var builtins = map[string]*object.Builtin{
+	"len": {
+		Name: "len",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.String:
+				return &object.Integer{Value: int64(len(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return newError(object.TYPE_ERROR, "argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"first": {
+		Name: "first",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			array, err := arrayArgument("first", args)
+			if err != nil {
+				return err
+			}
+			if len(array.Elements) > 0 {
+				return array.Elements[0]
+			}
+			return NULL
+		},
+	},
+	"last": {
+		Name: "last",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			array, err := arrayArgument("last", args)
+			if err != nil {
+				return err
+			}
+			length := len(array.Elements)
+			if length > 0 {
+				return array.Elements[length-1]
+			}
+			return NULL
+		},
+	},
+	"rest": {
+		Name: "rest",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			array, err := arrayArgument("rest", args)
+			if err != nil {
+				return err
+			}
+			length := len(array.Elements)
+			if length > 0 {
+				newElements := make([]object.Object, length-1)
+				copy(newElements, array.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+			return NULL
+		},
+	},
+	"push": {
+		Name: "push",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+			}
+			array, ok := args[0].(*object.Array)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+			length := len(array.Elements)
+			newElements := make([]object.Object, length+1)
+			copy(newElements, array.Elements)
+			newElements[length] = args[1]
+			return &object.Array{Elements: newElements}
+		},
+	},
+	"decimal": {
+		Name: "decimal",
+		Fn:   decimalBuiltin,
+	},
+	"exec": {
+		Name: "exec",
+		Fn:   execBuiltin,
+	},
+	// sort(arr) 返回按照object.Compare从小到大排好序的新数组，相等的元素保持原来的顺序
+	"sort": {
+		Name: "sort",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			array, err := arrayArgument("sort", args)
+			if err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(array.Elements))
+			copy(elements, array.Elements)
+			byAddress := false
+			sort.SliceStable(elements, func(i, j int) bool {
+				if !rt.Deterministic {
+					return object.Compare(elements[i], elements[j]) < 0
+				}
+				c, ok := object.CompareByContent(elements[i], elements[j])
+				byAddress = byAddress || !ok
+				return c < 0
+			})
+			// 内置函数和宏之间的顺序取决于它们在内存中的位置，每次运行可能不同
+			if byAddress {
+				return newError(object.NONDETERMINISM_ERROR, "`sort` cannot order built-in functions or macros in deterministic mode, their order depends on memory addresses")
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"puts": {
+		Name: "puts",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+			return NULL
+		},
+	},
+	// error(message, kind) 构造一个错误值，通常配合throw使用，kind默认为Error
+	"error": {
+		Name: "error",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			message, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `error` must be STRING, got %s", args[0].Type())
+			}
+			kind := object.GENERIC_ERROR
+			if len(args) == 2 {
+				k, ok := args[1].(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "kind passed to `error` must be STRING, got %s", args[1].Type())
+				}
+				kind = k.Value
+			}
+			return &object.ErrorValue{Message: message.Value, Kind: kind}
+		},
+	},
+	// gensym(prefix) 返回一个携带新标识符的Quote，可以在quote中通过unquote使用，prefix默认为g
+	"gensym": {
+		Name: "gensym",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			prefix := "g"
+			if len(args) == 1 {
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument to `gensym` must be STRING, got %s", args[0].Type())
+				}
+				prefix = str.Value
+			}
+			name := gensym(prefix)
+			ident := &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+			return &object.Quote{Node: ident}
+		},
+	},
+	"Ok": {
+		Name: "Ok",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return &object.Result{IsOk: true, Value: args[0]}
+		},
+	},
+	"Err": {
+		Name: "Err",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return &object.Result{IsOk: false, Value: args[0]}
+		},
+	},
+	"Some": {
+		Name: "Some",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return &object.Option{IsSome: true, Value: args[0]}
+		},
+	},
+	"None": {
+		Name: "None",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.Option{IsSome: false}
+		},
+	},
+	// unwrap_or(r, default) Ok和Some返回内部的值，Err和None返回default
+	"unwrap_or": {
+		Name: "unwrap_or",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Result:
+				if arg.IsOk {
+					return arg.Value
+				}
+				return args[1]
+			case *object.Option:
+				if arg.IsSome {
+					return arg.Value
+				}
+				return args[1]
+			default:
+				return newError(object.TYPE_ERROR, "argument to `unwrap_or` must be RESULT or OPTION, got %s", args[0].Type())
+			}
+		},
+	},
+	"is_ok": {
+		Name: "is_ok",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			result, ok := args[0].(*object.Result)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `is_ok` must be RESULT, got %s", args[0].Type())
+			}
+			return nativeBoolToBooleanObject(result.IsOk)
+		},
+	},
+	"is_some": {
+		Name: "is_some",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			option, ok := args[0].(*object.Option)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `is_some` must be OPTION, got %s", args[0].Type())
+			}
+			return nativeBoolToBooleanObject(option.IsSome)
+		},
+	},
+}
+
+// 需要调用用户函数的内置函数会间接引用builtins，为了避免初始化循环，在init中注册
+func init() {
+	// map(r, fn) 对Ok和Some内部的值调用fn并重新包装，Err和None原样返回
+	builtins["map"] = &object.Builtin{
+		Name: "map",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Result:
+				if !arg.IsOk {
+					return arg
+				}
+				mapped := applyFunction(args[1], []object.Object{arg.Value}, rt)
+				if isError(mapped) {
+					return mapped
+				}
+				return &object.Result{IsOk: true, Value: mapped}
+			case *object.Option:
+				if !arg.IsSome {
+					return arg
+				}
+				mapped := applyFunction(args[1], []object.Object{arg.Value}, rt)
+				if isError(mapped) {
+					return mapped
+				}
+				return &object.Option{IsSome: true, Value: mapped}
+			default:
+				return newError(object.TYPE_ERROR, "argument to `map` must be RESULT or OPTION, got %s", args[0].Type())
+			}
+		},
+	}
+	// and_then(r, fn) 对Ok和Some内部的值调用fn，fn必须返回同一种类型，Err和None原样返回
+	builtins["and_then"] = &object.Builtin{
+		Name: "and_then",
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+			}
+			var inner object.Object
+			switch arg := args[0].(type) {
+			case *object.Result:
+				if !arg.IsOk {
+					return arg
+				}
+				inner = arg.Value
+			case *object.Option:
+				if !arg.IsSome {
+					return arg
+				}
+				inner = arg.Value
+			default:
+				return newError(object.TYPE_ERROR, "argument to `and_then` must be RESULT or OPTION, got %s", args[0].Type())
+			}
+			next := applyFunction(args[1], []object.Object{inner}, rt)
+			if isError(next) {
+				return next
+			}
+			if next.Type() != args[0].Type() {
+				return newError(object.TYPE_ERROR, "function passed to `and_then` must return %s, got %s", args[0].Type(), next.Type())
+			}
+			return next
+		},
+	}
+}
+
+// arrayArgument 检查内置函数只接收了一个数组参数
+func arrayArgument(name string, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError(object.TYPE_ERROR, "argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	return array, nil
+}
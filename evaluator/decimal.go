@@ -0,0 +1,246 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"math/big"
+)
+
+// isNumber Integer、BigInt、Decimal和Rational之间可以互相运算和比较
+func isNumber(obj object.Object) bool {
+	switch obj.Type() {
+	case object.INTEGER_OBJ, object.BIGINT_OBJ, object.DECIMAL_OBJ, object.RATIONAL_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
+// toDecimal 整数转换为没有小数位的小数
+func toDecimal(obj object.Object) *object.Decimal {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &object.Decimal{Value: big.NewInt(obj.Value)}
+	case *object.BigInt:
+		return &object.Decimal{Value: obj.Value}
+	case *object.Decimal:
+		return obj
+	default:
+		return nil
+	}
+}
+
+// normalizeRational 分母为1的分数转换回整数
+func normalizeRational(value *big.Rat) object.Object {
+	if value.IsInt() {
+		return normalizeBigInt(new(big.Int).Set(value.Num()))
+	}
+	return &object.Rational{Value: value}
+}
+
+// evalNumericInfixExpression 处理有小数或分数参与的运算以及精确除法//。
+// 使用//或者有分数参与时结果是精确的分数，否则整数转换为小数参与运算。比较总是按照精确的值进行
+func evalNumericInfixExpression(operator string, left, right object.Object, rt *object.Runtime) object.Object {
+	switch operator {
+	case "==", "!=", "<", ">":
+		return comparison(operator, object.Compare(left, right))
+	case "//":
+		return evalRationalInfixExpression(operator, left, right)
+	}
+	if left.Type() == object.RATIONAL_OBJ || right.Type() == object.RATIONAL_OBJ {
+		return evalRationalInfixExpression(operator, left, right)
+	}
+	return evalDecimalInfixExpression(operator, left, right, rt)
+}
+
+// comparison 根据Compare的结果计算比较运算的值
+func comparison(operator string, cmp int) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(cmp == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(cmp != 0)
+	case "<":
+		return nativeBoolToBooleanObject(cmp < 0)
+	default:
+		return nativeBoolToBooleanObject(cmp > 0)
+	}
+}
+
+func evalRationalInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue, rightValue := object.ToRat(left), object.ToRat(right)
+	switch operator {
+	case "+":
+		return normalizeRational(new(big.Rat).Add(leftValue, rightValue))
+	case "-":
+		return normalizeRational(new(big.Rat).Sub(leftValue, rightValue))
+	case "*":
+		return normalizeRational(new(big.Rat).Mul(leftValue, rightValue))
+	case "/", "//":
+		if rightValue.Sign() == 0 {
+			return newError(object.ARITHMETIC_ERROR, "division by zero")
+		}
+		return normalizeRational(new(big.Rat).Quo(leftValue, rightValue))
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalDecimalInfixExpression 加减乘的结果是精确的，只有超出精度时才舍入；除法按照精度舍入
+func evalDecimalInfixExpression(operator string, left, right object.Object, rt *object.Runtime) object.Object {
+	leftValue, rightValue := toDecimal(left), toDecimal(right)
+	switch operator {
+	case "+", "-":
+		scale := leftValue.Scale
+		if rightValue.Scale > scale {
+			scale = rightValue.Scale
+		}
+		result := new(big.Int).Mul(leftValue.Value, pow10(scale-leftValue.Scale))
+		aligned := new(big.Int).Mul(rightValue.Value, pow10(scale-rightValue.Scale))
+		if operator == "+" {
+			result.Add(result, aligned)
+		} else {
+			result.Sub(result, aligned)
+		}
+		return roundDecimal(result, scale, rt)
+	case "*":
+		return roundDecimal(new(big.Int).Mul(leftValue.Value, rightValue.Value), leftValue.Scale+rightValue.Scale, rt)
+	case "/":
+		if rightValue.Value.Sign() == 0 {
+			return newError(object.ARITHMETIC_ERROR, "division by zero")
+		}
+		// left/right = (l * 10^rs) / (r * 10^ls)
+		num := new(big.Int).Mul(leftValue.Value, pow10(rightValue.Scale))
+		den := new(big.Int).Mul(rightValue.Value, pow10(leftValue.Scale))
+		if den.Sign() < 0 {
+			num.Neg(num)
+			den.Neg(den)
+		}
+		return quotientToDecimal(num, den, leftValue.Scale-rightValue.Scale, rt)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func numDigits(value *big.Int) int {
+	return len(new(big.Int).Abs(value).String())
+}
+
+// roundDecimal 有效数字超出精度时舍去多余的小数位
+func roundDecimal(value *big.Int, scale int, rt *object.Runtime) *object.Decimal {
+	extra := numDigits(value) - rt.Precision()
+	if extra > scale {
+		extra = scale
+	}
+	if extra > 0 {
+		value = roundQuotient(value, pow10(extra), rt.DecimalRounding)
+		scale -= extra
+	}
+	return &object.Decimal{Value: value, Scale: scale}
+}
+
+// quotientToDecimal 将num/den表示为精度以内的小数，den必须为正。
+// 除得尽时去掉末尾的0，但至少保留idealScale位小数，例如10.00d / 2 得到5.00
+func quotientToDecimal(num, den *big.Int, idealScale int, rt *object.Runtime) *object.Decimal {
+	abs := new(big.Int).Abs(num)
+	var scale int
+	if integer := new(big.Int).Quo(abs, den); integer.Sign() > 0 {
+		scale = rt.Precision() - numDigits(integer)
+		if scale < 0 {
+			scale = 0
+		}
+	} else if abs.Sign() > 0 {
+		// 小数点之后第一个不为0的数字之前有zeros个0，估计值不会偏大
+		zeros := numDigits(den) - numDigits(abs) - 1
+		if zeros < 0 {
+			zeros = 0
+		}
+		for new(big.Int).Mul(abs, pow10(zeros+1)).Cmp(den) < 0 {
+			zeros++
+		}
+		scale = rt.Precision() + zeros
+	}
+
+	value := roundQuotient(new(big.Int).Mul(num, pow10(scale)), den, rt.DecimalRounding)
+	if idealScale < 0 {
+		idealScale = 0
+	}
+	ten := big.NewInt(10)
+	for scale > idealScale && new(big.Int).Rem(value, ten).Sign() == 0 {
+		value.Quo(value, ten)
+		scale--
+	}
+	return &object.Decimal{Value: value, Scale: scale}
+}
+
+// roundQuotient 按照舍入方式计算num/den，den必须为正
+func roundQuotient(num, den *big.Int, mode object.DecimalRounding) *big.Int {
+	negative := num.Sign() < 0
+	quotient, remainder := new(big.Int).QuoRem(new(big.Int).Abs(num), den, new(big.Int))
+	if remainder.Sign() != 0 {
+		// 余数的两倍与除数比较，判断是否超过一半
+		half := new(big.Int).Lsh(remainder, 1).Cmp(den)
+		up := false
+		switch mode {
+		case object.RoundHalfEven:
+			up = half > 0 || half == 0 && quotient.Bit(0) == 1
+		case object.RoundHalfUp:
+			up = half >= 0
+		case object.RoundUp:
+			up = true
+		case object.RoundFloor:
+			up = negative
+		case object.RoundCeiling:
+			up = !negative
+		}
+		if up {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	if negative {
+		quotient.Neg(quotient)
+	}
+	return quotient
+}
+
+// decimalBuiltin decimal(x, places) 将整数、分数或者形如"12.50"的字符串转换为小数。
+// 给出places时按照舍入方式保留places位小数，例如decimal(1 // 3, 2)得到0.33
+func decimalBuiltin(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	value := args[0]
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt, *object.Decimal, *object.Rational:
+	case *object.String:
+		decimal, ok := object.ParseDecimal(arg.Value)
+		if !ok {
+			return newError(object.ARGUMENT_ERROR, "could not parse %q as decimal", arg.Value)
+		}
+		value = decimal
+	default:
+		return newError(object.TYPE_ERROR, "argument to `decimal` must be INTEGER, DECIMAL, RATIONAL or STRING, got %s", args[0].Type())
+	}
+
+	if len(args) == 1 {
+		if rational, ok := value.(*object.Rational); ok {
+			return quotientToDecimal(rational.Value.Num(), rational.Value.Denom(), 0, rt)
+		}
+		return toDecimal(value)
+	}
+
+	places, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "places passed to `decimal` must be INTEGER, got %s", args[1].Type())
+	}
+	if places.Value < 0 {
+		return newError(object.ARGUMENT_ERROR, "places passed to `decimal` must not be negative, got %d", places.Value)
+	}
+	exact := object.ToRat(value)
+	scale := int(places.Value)
+	num := new(big.Int).Mul(exact.Num(), pow10(scale))
+	return &object.Decimal{Value: roundQuotient(num, exact.Denom(), rt.DecimalRounding), Scale: scale}
+}
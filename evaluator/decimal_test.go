@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"testing"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.25d + 2", "3.25"},
+		{"0.1d + 0.2d", "0.3"},
+		{"1.50d - 0.5d", "1.00"},
+		{"1.10d * 3", "3.30"},
+		{"-12.5d * 2", "-25.0"},
+		{"10.00d / 2", "5.00"},
+		{"1d / 4", "0.25"},
+		{"1d / 3", "0.3333333333333333333333333333"},
+		{"2d / 3", "0.6666666666666666666666666667"},
+		{"1d / 300", "0.003333333333333333333333333333"},
+		{"100d / 3", "33.33333333333333333333333333"},
+		{"-(1.5d)", "-1.5"},
+		{"9223372036854775807 + 0.5d", "9223372036854775807.5"},
+		{`let prices = [19.99d, 5.01d, 0.10d]; prices[0] + prices[1] + prices[2]`, "25.10"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Decimal); !ok || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected decimal %s, got %s (%T)", tt.input, tt.expected, evaluated.Inspect(), evaluated)
+		}
+	}
+}
+
+func TestDecimalPrecisionAndRounding(t *testing.T) {
+	tests := []struct {
+		input     string
+		precision int
+		rounding  object.DecimalRounding
+		expected  string
+	}{
+		{"2d / 3", 4, object.RoundHalfEven, "0.6667"},
+		{"2d / 3", 4, object.RoundDown, "0.6666"},
+		{"-2d / 3", 4, object.RoundFloor, "-0.6667"},
+		{"-2d / 3", 4, object.RoundCeiling, "-0.6666"},
+		{"1d / 3", 4, object.RoundUp, "0.3334"},
+		{"12345d / 7", 4, object.RoundHalfEven, "1764"},
+		{"0.125d * 1", 2, object.RoundHalfEven, "0.12"},
+		{"0.125d * 1", 2, object.RoundHalfUp, "0.13"},
+		{"0.135d * 1", 2, object.RoundHalfEven, "0.14"},
+		{"1.23456d + 1", 3, object.RoundHalfEven, "2.23"},
+		{"decimal(1 // 8, 2)", 0, object.RoundHalfEven, "0.12"},
+		{"decimal(1 // 8, 2)", 0, object.RoundHalfUp, "0.13"},
+		{`decimal("-2.675", 2)`, 0, object.RoundDown, "-2.67"},
+		{`decimal("-2.675", 2)`, 0, object.RoundFloor, "-2.68"},
+	}
+
+	for _, tt := range tests {
+		rt := &object.Runtime{DecimalPrecision: tt.precision, DecimalRounding: tt.rounding}
+		evaluated := testEvalWithRuntime(tt.input, rt)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s (precision %d, rounding %d): expected %s, got %s",
+				tt.input, tt.precision, tt.rounding, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRationalArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		typ      object.ObjectType
+	}{
+		{"1 // 3", "1/3", object.RATIONAL_OBJ},
+		{"6 // 3", "2", object.INTEGER_OBJ},
+		{"-4 // 6", "-2/3", object.RATIONAL_OBJ},
+		{"1 // 3 + 1 // 6", "1/2", object.RATIONAL_OBJ},
+		{"1 // 3 * 3", "1", object.INTEGER_OBJ},
+		{"(1 // 3) / 2", "1/6", object.RATIONAL_OBJ},
+		{"1 - 1 // 4", "3/4", object.RATIONAL_OBJ},
+		{"1.5d // 2", "3/4", object.RATIONAL_OBJ},
+		{"0.5d + 1 // 3", "5/6", object.RATIONAL_OBJ},
+		{"-(1 // 3)", "-1/3", object.RATIONAL_OBJ},
+		{"(9223372036854775807 + 1) // 2", "4611686018427387904", object.INTEGER_OBJ},
+		{"decimal(1 // 3)", "0.3333333333333333333333333333", object.DECIMAL_OBJ},
+		{"decimal(1 // 3, 2)", "0.33", object.DECIMAL_OBJ},
+		{`decimal("12.50")`, "12.50", object.DECIMAL_OBJ},
+		{"decimal(7)", "7", object.DECIMAL_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.typ || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.input, tt.typ, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestNumericComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5d == 3 // 2", true},
+		{"1.50d == 1.5d", true},
+		{"2 == 2.00d", true},
+		{"2 != 2.01d", true},
+		{"1 // 3 < 0.34d", true},
+		{"1 // 3 > 0.33d", true},
+		{"0.1d + 0.2d == 0.3d", true},
+		{"9223372036854775807 + 1 > 9223372036854775807.5d", true},
+		{"-1 // 2 < -0.4d", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestNumericErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 // 0", "ArithmeticError: division by zero"},
+		{"1.5d / 0", "ArithmeticError: division by zero"},
+		{"(1 // 2) / 0.0d", "ArithmeticError: division by zero"},
+		{`1.5d + "a"`, "TypeError: type mismatch: DECIMAL + STRING"},
+		{`"a" // "b"`, "TypeError: unknown operator: STRING // STRING"},
+		{`decimal("1.2.3")`, `ArgumentError: could not parse "1.2.3" as decimal`},
+		{`decimal(true)`, "TypeError: argument to `decimal` must be INTEGER, DECIMAL, RATIONAL or STRING, got BOOLEAN"},
+		{`decimal(1, -1)`, "ArgumentError: places passed to `decimal` must not be negative, got -1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if got := errObj.Kind + ": " + errObj.Message; got != tt.expected {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"Interp/ast"
 	"Interp/object"
 	"fmt"
+	"math"
+	"math/big"
 )
 
 var (
@@ -12,7 +14,24 @@ var (
 	NULL  = &object.Null{}
 )
 
+// Eval 对节点求值。错误第一次出现时记录产生它的节点的位置
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	rt := env.Runtime()
+	if !rt.EnterEval() {
+		return newError(object.GENERIC_ERROR, "stack overflow")
+	}
+	result := eval(node, env)
+	rt.LeaveEval()
+	if err, ok := result.(*object.Error); ok && err.Position == "" {
+		// 没有位置信息的节点（例如由unquote转换而来的字面量）交给外层节点记录
+		if tok := ast.TokenOf(node); tok != nil && tok.Line > 0 {
+			err.Position = tok.Position()
+		}
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
@@ -21,72 +40,266 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, env.Runtime())
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env.Runtime())
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Pattern != nil {
+			// 解构失败时返回错误
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
+		setIdentifier(node.Name, val, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Body: body, Env: env, Name: node.Name}
+	case *ast.MacroLiteral:
+		return &object.Macro{Parameters: node.Parameters, Body: node.Body, Env: env}
 	case *ast.CallExpression:
+		// quote的参数不求值
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments to `quote`. got=%d, want=1", len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
+		}
 		function := Eval(node.Function, env)
-		if isError(function) {
+		if isAbrupt(function) {
 			return function
 		}
 		// 将参数表达式转换为Object
 		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		// 尾调用交给applyFunction中的循环执行，不增加Go的调用栈
+		if fn, ok := function.(*object.Function); ok && node.Tail {
+			return &tailCall{fn: fn, args: args, node: node}
+		}
+		result := applyFunction(function, args, env.Runtime())
+		// 错误离开函数时记录这一层调用，得到从内到外的调用栈
+		if err, ok := result.(*object.Error); ok {
+			err.AddFrame(stackFrame(function, node), 1)
+		}
+		return result
+	case *ast.ExportStatement:
+		// 导出的值在模块执行完之后由加载模块的一方读取
+		return Eval(node.Statement, env)
+	case *ast.ImportExpression:
+		return evalImport(node.Path, node.Resolved, env.Runtime())
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return throwValue(val)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.PostfixExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		return evalPostfixExpression(node.Operator, left)
 	//将表达式分解为Object
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value, Scale: node.Scale}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
 
 	}
 
 	return nil
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
-	if !ok {
-		return newError("not a function: %s", fn.Type())
+func applyFunction(fn object.Object, args []object.Object, rt *object.Runtime) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		return applyUserFunction(function, args)
+	case *object.Builtin:
+		return function.Fn(rt, args...)
+	case *object.Closure:
+		// 虚拟机交给内置函数的闭包，回到虚拟机中执行
+		if rt.CallClosure != nil {
+			return rt.CallClosure(function, args)
+		}
 	}
-	extendEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendEnv)
-	return unwarpReturnValue(evaluated)
+	return newError(object.TYPE_ERROR, "not a function: %s", fn.Type())
+}
+
+// tailCall 处于尾位置的调用，由applyUserFunction执行
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	node *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call " + tc.node.String() }
+
+// elidedFrames 连续相同的尾调用只记录一次和次数，自递归的循环只占用常数空间
+type elidedFrames struct {
+	fn    *object.Function
+	node  *ast.CallExpression
+	count int
+}
+
+// applyUserFunction 以蹦床的方式执行函数：函数体返回尾调用时，在同一个循环中执行被调用的函数，整个循环只算一层调用。
+// 出错时按照没有优化的顺序补回被省略的调用栈，与虚拟机的结果相同
+func applyUserFunction(fn *object.Function, args []object.Object) object.Object {
+	rt := fn.Env.Runtime()
+	if !rt.EnterCall() {
+		return newError(object.GENERIC_ERROR, "stack overflow")
+	}
+	defer rt.LeaveCall()
+
+	var elided []elidedFrames
+	var caller *ast.CallExpression // 当前函数是被哪个尾调用调用的，第一次调用时为nil
+	var result object.Object
+	for {
+		env, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			// 参数的错误发生在调用处，与普通调用由Eval记录的位置相同
+			if caller != nil && caller.Token.Line > 0 {
+				err.Position = caller.Token.Position()
+			}
+			result = err
+			break
+		}
+		result = unwarpReturnValue(Eval(fn.Body, env))
+		call, ok := result.(*tailCall)
+		if !ok {
+			break
+		}
+
+		fn, args, caller = call.fn, call.args, call.node
+		if n := len(elided); n > 0 && elided[n-1].node == caller && elided[n-1].fn.Name == fn.Name {
+			elided[n-1].count++
+		} else {
+			elided = append(elided, elidedFrames{fn: fn, node: caller, count: 1})
+		}
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		for i := len(elided) - 1; i >= 0; i-- {
+			err.AddFrame(stackFrame(elided[i].fn, elided[i].node), elided[i].count)
+		}
+	}
+	return result
+}
+
+// stackFrame 描述一次调用，形如 add (3:10)，位置是调用处的括号
+func stackFrame(fn object.Object, node *ast.CallExpression) string {
+	name := "<anonymous>"
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			name = fn.Name
+		}
+	case *object.Builtin:
+		name = fn.Name
+	}
+	return fmt.Sprintf("%s (%s)", name, node.Token.Position())
+}
+
+// throwValue 将throw的值转换为传播中的错误。重新抛出捕获的错误时保留原来的种类和调用栈
+func throwValue(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.ErrorValue:
+		stack := make([]string, len(val.Stack))
+		copy(stack, val.Stack)
+		return &object.Error{Message: val.Message, Kind: val.Kind, Stack: stack, Value: val.Value, Position: val.Position}
+	case *object.String:
+		return &object.Error{Message: val.Value, Kind: object.GENERIC_ERROR, Value: val}
+	default:
+		return &object.Error{Message: val.Inspect(), Kind: object.GENERIC_ERROR, Value: val}
+	}
+}
+
+// catchError 将错误转换为ErrorValue，绑定到catch的参数上
+func catchError(err *object.Error) *object.ErrorValue {
+	return &object.ErrorValue{
+		Message:  err.Message,
+		Kind:     err.Kind,
+		Stack:    err.Stack,
+		Value:    err.Value,
+		Position: err.Position,
+	}
+}
+
+// evalTryExpression 执行try块，出错时将错误转换为ErrorValue绑定到catch的参数上。
+// finally块总是会执行，其中的return或错误会覆盖之前的结果
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+	if err, ok := result.(*object.Error); ok && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		setIdentifier(node.CatchParam, catchError(err), catchEnv)
+		result = Eval(node.Catch, catchEnv)
+	}
+	if node.Finally != nil {
+		finally := Eval(node.Finally, env)
+		if finally != nil {
+			rt := finally.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return finally
+			}
+		}
+	}
+	return result
 }
 
 // unwarpReturnValue 解包返回值，避免return语句上浮阻止其他节点计算
@@ -97,19 +310,25 @@ func unwarpReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv 创建函数调用的环境，并将实参绑定到形参的模式上
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	if len(args) != len(fn.Parameters) {
+		return nil, newError(object.ARGUMENT_ERROR, "wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+	}
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if err := bindPattern(param, args[paramIdx], env); err != nil {
+			return nil, err
+		}
 	}
-	return env
+	return env, nil
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, e := range exps {
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -118,13 +337,41 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Resolved {
+		val, ok := env.GetAt(node.Depth, node.Slot)
+		for i := 0; !ok && i < len(node.Fallbacks); i++ {
+			val, ok = env.GetAt(node.Fallbacks[i].Depth, node.Fallbacks[i].Slot)
+		}
+		if builtin, isBuiltin := builtins[node.Value]; !ok && node.FallbackByName && isBuiltin {
+			return builtin
+		}
+		if !ok {
+			// 变量已经声明，但是声明它的let还没有执行
+			return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
+		}
+		return val
+	}
+
 	val, ok := env.Get(node.Value)
 	if !ok {
-		return newError("identifier not found: " + node.Value)
+		// 环境中找不到时再查找内置函数，允许用户定义的同名绑定覆盖内置函数
+		if builtin, ok := builtins[node.Value]; ok {
+			return builtin
+		}
+		return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 	}
 	return val
 }
 
+// setIdentifier 在当前环境中绑定变量，解析过的变量按下标保存
+func setIdentifier(ident *ast.Identifier, val object.Object, env *object.Environment) {
+	if ident.Resolved {
+		env.SetAt(ident.Slot, val)
+		return
+	}
+	env.Set(ident.Value, val)
+}
+
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
@@ -156,12 +403,32 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
+// evalImport 原生模块按照名字查找。其余的模块在程序执行之前已经按照依赖的顺序执行过，这里只按照解析出的路径查找它导出的值
+func evalImport(path, resolved string, rt *object.Runtime) object.Object {
+	if module, ok := nativeModules[path]; ok {
+		return module
+	}
+	if module, ok := rt.Modules[resolved]; ok && resolved != "" {
+		return module
+	}
+	if rt.LoadModule != nil && resolved != "" {
+		if module := rt.LoadModule(resolved); module != nil {
+			return module
+		}
+	}
+	return newError(object.IMPORT_ERROR, "module %q is not loaded", path)
+}
+
 func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(node.Condition, env)
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
-	if isTruthy(condition) {
+	truthy, err := isTruthy(condition, env.Runtime())
+	if err != nil {
+		return err
+	}
+	if truthy {
 		return Eval(node.Consequence, env)
 	} else if node.Alternative != nil {
 		return Eval(node.Alternative, env)
@@ -170,51 +437,94 @@ func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Ob
 	}
 }
 
-func isTruthy(condition object.Object) bool {
+// isTruthy 按照rt的规则判断条件的真假，严格模式下条件不是布尔值时返回TypeError
+func isTruthy(condition object.Object, rt *object.Runtime) (bool, *object.Error) {
 	switch condition {
-	case NULL:
-		return false
-	case FALSE:
-		return false
 	case TRUE:
-		return true
+		return true, nil
+	case FALSE:
+		return false, nil
+	}
+
+	switch rt.Truthiness {
+	case object.TruthinessStrict:
+		return false, newError(object.TYPE_ERROR, "condition must be BOOLEAN, got %s", condition.Type())
+	case object.TruthinessFalsyEmpty:
+		return !isZeroOrEmpty(condition), nil
 	default:
+		return condition != NULL, nil
+	}
+}
+
+// isZeroOrEmpty 判断值是否是null、数字0或者空的字符串、数组和哈希表
+func isZeroOrEmpty(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
 		return true
+	case *object.Integer:
+		return obj.Value == 0
+	case *object.Decimal:
+		return obj.Value.Sign() == 0
+	case *object.String:
+		return obj.Value == ""
+	case *object.Array:
+		return len(obj.Elements) == 0
+	case *object.Hash:
+		return len(obj.Pairs) == 0
+	default:
+		// BigInt和Rational不会是0
+		return false
 	}
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func evalInfixExpression(operator string, left object.Object, right object.Object, rt *object.Runtime) object.Object {
 	switch {
 	// 当进行两端都是整数时，调用 evalIntegerInfixExpression
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
-	// 当涉及比较时，两端是布尔值对象
-	// NOTE: 这里使用指针来判断是否成立是因为只有一个TRUE或者FALSE对象，比较指针即可。但是整数值不能这样比较
+	case isInteger(left) && isInteger(right) && operator != "//":
+		return evalIntegerInfixExpression(operator, left, right, rt)
+	case isNumber(left) && isNumber(right):
+		return evalNumericInfixExpression(operator, left, right, rt)
+	// 其余的比较按照object.Equal和object.Compare进行，数组、哈希表等按内容比较
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+	case operator == "<" || operator == ">":
+		return evalOrderingExpression(operator, left, right, rt)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+// evalIntegerInfixExpression 整数运算，两端可以是Integer或BigInt。
+// int64溢出时按照rt.IntegerMode回绕、报错或者改用BigInt计算
+func evalIntegerInfixExpression(operator string, left object.Object, right object.Object, rt *object.Runtime) object.Object {
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntInfixExpression(operator, toBigInt(left), toBigInt(right), left, right)
+	}
+	leftValue := leftInt.Value
+	rightValue := rightInt.Value
 
 	switch operator {
 	// 整数运算
-	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
-	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
-	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
-	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+	case "+", "-", "*", "/":
+		if operator == "/" && rightValue == 0 {
+			return newError(object.ARITHMETIC_ERROR, "division by zero")
+		}
+		result, ok := int64Arithmetic(operator, leftValue, rightValue)
+		if ok || rt.IntegerMode == object.IntegerWrap {
+			return &object.Integer{Value: result}
+		}
+		if rt.IntegerMode == object.IntegerChecked {
+			return newError(object.ARITHMETIC_ERROR, "integer overflow: %d %s %d", leftValue, operator, rightValue)
+		}
+		return evalBigIntInfixExpression(operator, toBigInt(left), toBigInt(right), left, right)
 
 	// 布尔运算
 	case "==":
@@ -227,41 +537,237 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+// evalOrderingExpression 同一种类的值按照object.Compare比较大小，函数之间没有有意义的大小。
+// 确定性模式下，包含内置函数的数组等值的大小取决于它们在内存中的位置时报错
+func evalOrderingExpression(operator string, left object.Object, right object.Object, rt *object.Runtime) object.Object {
+	if left.Type() != right.Type() {
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+	switch left.(type) {
+	case *object.Function, *object.Builtin, *object.Closure, *object.Macro, *object.CompiledFunction:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+	if rt.Deterministic {
+		c, ok := object.CompareByContent(left, right)
+		if !ok {
+			return newError(object.NONDETERMINISM_ERROR, "cannot order %s %s %s in deterministic mode, the result depends on memory addresses of built-in functions or macros", left.Type(), operator, right.Type())
+		}
+		return comparison(operator, c)
+	}
+	return comparison(operator, object.Compare(left, right))
+}
+
+func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+		}
+		value := Eval(pair.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+	return hash
+}
+
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(elements)) {
+			return NULL
+		}
+		return elements[idx]
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.BIGINT_OBJ:
+		// 超出int64范围的下标一定越界
+		idx := index.(*object.BigInt).Value
+		if !idx.IsInt64() {
+			return NULL
+		}
+		return evalIndexExpression(left, &object.Integer{Value: idx.Int64()})
+	case left.Type() == object.ARRAY_OBJ:
+		return newError(object.TYPE_ERROR, "array index must be an integer, got %s", index.Type())
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorValueIndexExpression(left.(*object.ErrorValue), index.(*object.String).Value)
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
+		}
+		value, ok := left.(*object.Hash).Get(key)
+		if !ok {
+			return NULL
+		}
+		return value
+	default:
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
+	}
+}
+
+// evalErrorValueIndexExpression 查看捕获的错误，支持message、kind、stack、value和position五个字段
+func evalErrorValueIndexExpression(ev *object.ErrorValue, field string) object.Object {
+	switch field {
+	case "message":
+		return &object.String{Value: ev.Message}
+	case "kind":
+		return &object.String{Value: ev.Kind}
+	case "stack":
+		elements := make([]object.Object, len(ev.Stack))
+		for i, frame := range ev.Stack {
+			elements[i] = &object.String{Value: frame}
+		}
+		return &object.Array{Elements: elements}
+	case "value":
+		if ev.Value == nil {
+			return NULL
+		}
+		return ev.Value
+	case "position":
+		return &object.String{Value: ev.Position}
+	default:
+		return NULL
+	}
+}
+
+// bindPattern 将val按照pattern的形状绑定到env中，形状不匹配时返回错误
+func bindPattern(pattern ast.Pattern, val object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		setIdentifier(pattern, val, env)
+		return nil
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok {
+			return newError(object.PATTERN_ERROR, "cannot destructure %s as array", val.Type())
+		}
+		if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return newError(object.PATTERN_ERROR, "array pattern %s expects %d elements, got %d",
+				pattern.String(), len(pattern.Elements), len(array.Elements))
+		}
+		if len(array.Elements) < len(pattern.Elements) {
+			return newError(object.PATTERN_ERROR, "array pattern %s expects at least %d elements, got %d",
+				pattern.String(), len(pattern.Elements), len(array.Elements))
+		}
+		for i, element := range pattern.Elements {
+			if err := bindPattern(element, array.Elements[i], env); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			// 复制剩余元素，避免与原数组共享底层存储
+			rest := make([]object.Object, len(array.Elements)-len(pattern.Elements))
+			copy(rest, array.Elements[len(pattern.Elements):])
+			setIdentifier(pattern.Rest, &object.Array{Elements: rest}, env)
+		}
+		return nil
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return newError(object.PATTERN_ERROR, "cannot destructure %s as hash", val.Type())
+		}
+		for _, pair := range pattern.Pairs {
+			value, ok := hash.Get(&object.String{Value: pair.Key.Value})
+			if !ok {
+				return newError(object.PATTERN_ERROR, "key not found in hash pattern %s: %s", pattern.String(), pair.Key.Value)
+			}
+			if err := bindPattern(pair.Value, value, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ast.UnquotePattern:
+		return newError(object.PATTERN_ERROR, "unquote used in binding position outside of quote: %s", pattern.String())
+	default:
+		return newError(object.PATTERN_ERROR, "unknown binding pattern: %T", pattern)
+	}
+}
+
+// evalPostfixExpression 目前只有?运算符：Ok和Some解包为内部的值，Err和None从所在的函数提前返回
+func evalPostfixExpression(operator string, left object.Object) object.Object {
+	if operator != "?" {
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", left.Type(), operator)
+	}
+	switch left := left.(type) {
+	case *object.Result:
+		if left.IsOk {
+			return left.Value
+		}
+		// 包装为返回值，由applyFunction中的unwarpReturnValue解包
+		return &object.ReturnValue{Value: left}
+	case *object.Option:
+		if left.IsSome {
+			return left.Value
+		}
+		return &object.ReturnValue{Value: left}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", left.Type(), operator)
+	}
+}
+
+func evalPrefixExpression(operator string, right object.Object, rt *object.Runtime) object.Object {
 	switch operator {
 	case "!":
-		return evalBangOperatorExpression(right)
+		return evalBangOperatorExpression(right, rt)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return evalMinusPrefixOperatorExpression(right, rt)
 	default:
-		return newError("unknown operator: %s %s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s", operator, right.Type())
 	}
 
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+func evalMinusPrefixOperatorExpression(right object.Object, rt *object.Runtime) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		// 只有最小的int64取反会溢出
+		if right.Value == math.MinInt64 && rt.IntegerMode != object.IntegerWrap {
+			if rt.IntegerMode == object.IntegerChecked {
+				return newError(object.ARITHMETIC_ERROR, "integer overflow: -(%d)", right.Value)
+			}
+			return normalizeBigInt(new(big.Int).Neg(toBigInt(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return normalizeBigInt(new(big.Int).Neg(right.Value))
+	case *object.Decimal:
+		return &object.Decimal{Value: new(big.Int).Neg(right.Value), Scale: right.Scale}
+	case *object.Rational:
+		return &object.Rational{Value: new(big.Rat).Neg(right.Value)}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
-func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
+func evalBangOperatorExpression(right object.Object, rt *object.Runtime) object.Object {
+	if rt.Truthiness == object.TruthinessStrict && right.Type() != object.BOOLEAN_OBJ {
+		return newError(object.TYPE_ERROR, "unknown operator: !%s", right.Type())
 	}
+	truthy, _ := isTruthy(right, rt)
+	return nativeBoolToBooleanObject(!truthy)
 }
 
 func evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
@@ -281,8 +787,8 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
 }
 
 func isError(obj object.Object) bool {
@@ -291,3 +797,12 @@ func isError(obj object.Object) bool {
 	}
 	return false
 }
+
+// isAbrupt 判断obj是否会中断当前表达式的求值，包括错误和?运算符产生的提前返回
+func isAbrupt(obj object.Object) bool {
+	if obj != nil {
+		rt := obj.Type()
+		return rt == object.ERROR_OBJ || rt == object.RETURN_VALUE_OBJ
+	}
+	return false
+}
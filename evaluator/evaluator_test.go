@@ -4,6 +4,7 @@ import (
 	"Interp/lexer"
 	"Interp/object"
 	"Interp/parser"
+	"strings"
 	"testing"
 )
 
@@ -36,6 +37,8 @@ func testEval(input string) object.Object {
 	p := parser.NewParser(l)
 
 	program := p.ParseProgram()
+	// 未声明的变量保持按名字查找，运行时报NameError，所以这里忽略resolver的错误
+	NewResolver().Resolve(program)
 	env := object.NewEnvironment()
 	return Eval(program, env)
 }
@@ -195,6 +198,7 @@ func TestErrorHandling(t *testing.T) {
 		expectedErrMsg string
 	}{
 		{"5+true;", "type mismatch: INTEGER + BOOLEAN"},
+		{`[1, 2]["0"]`, "array index must be an integer, got STRING"},
 		{"5+true; 5;", "type mismatch: INTEGER + BOOLEAN"},
 		{"-true;", "unknown operator: -BOOLEAN"},
 		{"true+false;", "unknown operator: BOOLEAN + BOOLEAN"},
@@ -266,7 +270,7 @@ func TestFunctionObject(t *testing.T) {
 		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
 	}
 
-	expectedBody := "(x + 2)"
+	expectedBody := "{ (x + 2) }"
 
 	if fn.Body.String() != expectedBody {
 		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
@@ -308,3 +312,496 @@ func TestClosure(t *testing.T) {
 	testIntegerObject(t, testEval(input), 4)
 
 }
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(`"Hello" + " " + "World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{"[1, 2][99999999999999999999]", nil},
+		{"[1, 2][-99999999999999999999]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b;", 12},
+		{"let [a, ...rest] = [1, 2, 3]; rest[0] + rest[1];", 5},
+		{"let [a, ...rest] = [1]; rest[0] == rest[0]; a;", 1},
+		{`let {name, age: years} = {"name": 3, "age": 40}; name + years;`, 43},
+		{`let {pos: [x, y]} = {"pos": [4, 5], "extra": 0}; x * y;`, 20},
+		{`let [{v}, [w]] = [{"v": 1}, [2]]; v + w;`, 3},
+		{"let add = fn([a, b]) { a + b }; add([3, 4]);", 7},
+		{`let f = fn({x, y}, z) { x * y + z }; f({"x": 2, "y": 3}, 1);`, 7},
+		{"let head = fn([h, ...t]) { h }; head([9, 8, 7]);", 9},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"let [a, b] = 5;", "cannot destructure INTEGER as array"},
+		{"let [a, b] = [1, 2, 3];", "array pattern [a, b] expects 2 elements, got 3"},
+		{"let [a, b, ...c] = [1];", "array pattern [a, b, ...c] expects at least 2 elements, got 1"},
+		{`let {name} = [1];`, "cannot destructure ARRAY as hash"},
+		{`let {name} = {"age": 1};`, "key not found in hash pattern {name}: name"},
+		{"let f = fn([a]) { a }; f([1, 2]);", "array pattern [a] expects 1 elements, got 2"},
+		{"let f = fn(a, b) { a }; f(1);", "wrong number of arguments: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedErrMsg {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedErrMsg, errObj.Message)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])[1]`, 3},
+		{`rest([])`, nil},
+		{`push([], 1)[0]`, 1},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw 5; 1 } catch (e) { e["value"] }`, 5},
+		{`try { throw "bad"; } catch (e) { e["message"] }`, "bad"},
+		{`try { throw "bad"; } catch (e) { e["kind"] }`, "Error"},
+		{`try { foo } catch (e) { e["kind"] }`, "NameError"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, "ArithmeticError"},
+		{`try { len(1) } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { let {a} = {}; } catch (e) { e["kind"] }`, "PatternError"},
+		{`try { throw error("no key", "KeyError"); } catch (e) { e["kind"] + ": " + e["message"] }`, "KeyError: no key"},
+		{`try { throw error("x"); } catch (e) { e["value"] }`, nil},
+		{`let a = try { throw 1; } catch (e) { 10 } finally { 20 }; a;`, 10},
+		{`let f = fn() { try { return 1; } finally { 2 } }; f();`, 1},
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f();`, 2},
+		{`let f = fn() { try { throw 1; } finally { return 3; } }; f();`, 3},
+		{`try { try { throw 1; } finally { 0 } } catch (e) { e["value"] + 1 }`, 2},
+		{`try { try { throw 1; } catch (e) { throw e; } } catch (e) { e["value"] + 10 }`, 11},
+		{`try { try { throw 1; } catch (e) { throw 2; } } catch (e) { e["value"] }`, 2},
+		{`try { throw 1; } catch (e) { 1 }; e;`, "identifier not found: e"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string for %q. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	evaluated := testEval(`throw "boom"; 1;`)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "boom" || errObj.Kind != object.GENERIC_ERROR {
+		t.Errorf("wrong error. got=%q (%s)", errObj.Message, errObj.Kind)
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	input := `let inner = fn() { throw "deep"; };
+let outer = fn() { inner() };
+try {
+	outer();
+} catch (e) {
+	e["stack"]
+}`
+
+	evaluated := testEval(input)
+	stack, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{"inner (2:25)", "outer (4:7)"}
+	if len(stack.Elements) != len(expected) {
+		t.Fatalf("wrong stack length. want=%d, got=%d (%s)", len(expected), len(stack.Elements), stack.Inspect())
+	}
+	for i, frame := range expected {
+		if stack.Elements[i].Inspect() != frame {
+			t.Errorf("stack[%d] wrong. want=%q, got=%q", i, frame, stack.Elements[i].Inspect())
+		}
+	}
+}
+
+func TestResultAndOption(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`Ok(1)`, "Ok(1)"},
+		{`Err("bad")`, "Err(bad)"},
+		{`Some(2)`, "Some(2)"},
+		{`None()`, "None"},
+		{`map(Ok(1), fn(x) { x + 1 })`, "Ok(2)"},
+		{`map(Err(1), fn(x) { x + 1 })`, "Err(1)"},
+		{`map(Some(1), fn(x) { x * 3 })`, "Some(3)"},
+		{`map(None(), fn(x) { x * 3 })`, "None"},
+		{`and_then(Ok(1), fn(x) { Err(x) })`, "Err(1)"},
+		{`and_then(Err(0), fn(x) { Ok(x) })`, "Err(0)"},
+		{`and_then(Some(4), fn(x) { Some(x * x) })`, "Some(16)"},
+		{`unwrap_or(Ok(1), 2)`, "1"},
+		{`unwrap_or(Err(1), 2)`, "2"},
+		{`unwrap_or(None(), 3)`, "3"},
+		{`is_ok(Ok(1))`, "true"},
+		{`is_ok(Err(1))`, "false"},
+		{`is_some(None())`, "false"},
+		{`and_then(Ok(1), fn(x) { x })`, "ERROR: function passed to `and_then` must return RESULT, got INTEGER (at 1:9)"},
+		{`is_ok(1)`, "ERROR: argument to `is_ok` must be RESULT, got INTEGER (at 1:6)"},
+		{`map(Ok(1), fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN (at 1:22)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no object returned for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestQuestionOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(r) { r? + 1 }; f(Ok(1));`, "2"},
+		{`let f = fn(r) { r? + 1 }; f(Err("bad"));`, "Err(bad)"},
+		{`let f = fn(o) { let v = o?; Some(v * 2) }; f(Some(2));`, "Some(4)"},
+		{`let f = fn(o) { let v = o?; Some(v * 2) }; f(None());`, "None"},
+		{`let parse = fn(x) { if (x > 0) { Ok(x) } else { Err("negative") } };
+let sum = fn(a, b) { Ok(parse(a)? + parse(b)?) };
+sum(1, 2);`, "Ok(3)"},
+		{`let parse = fn(x) { if (x > 0) { Ok(x) } else { Err("negative") } };
+let sum = fn(a, b) { Ok(parse(a)? + parse(b)?) };
+sum(1, -2);`, "Err(negative)"},
+		{`let f = fn(r) { [r?, 1] }; f(Err(0));`, "Err(0)"},
+		{`let f = fn(r) { try { r? } finally { 1 } }; f(Err(0));`, "Err(0)"},
+		{`Err(1)?; 2;`, "Err(1)"},
+		{`1?`, "ERROR: unknown operator: INTEGER? (at 1:2)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no object returned for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(1000000, 0)`, "500000500000"},
+		{`let even = fn(n) { if (n == 0) { return true; }; return odd(n - 1); };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+[even(1000000), odd(1000001)]`, "[true, true]"},
+		{`let count = fn(n) { if (n == 0) { Ok("done") } else { let next = n - 1; count(next) } }; and_then(Ok(1000000), count)`, "Ok(done)"},
+		// 被省略的调用仍然出现在调用栈中
+		{`let fail = fn(n) { if (n == 0) { 1 + true } else { fail(n - 1) } };
+let start = fn() { fail(2) };
+try { start() } catch (e) { e["stack"] }`, `[fail (1:56) ×2, fail (2:24), start (3:12)]`},
+		// 连续相同的调用合并为一项，调用栈的长度不随递归的深度增长
+		{`let fail = fn(n) { if (n == 0) { 1 + true } else { fail(n - 1) } }; try { fail(1000000) } catch (e) { e["stack"] }`,
+			`[fail (1:56) ×1000000, fail (1:79)]`},
+		{`let f = fn(a) { a }; let g = fn() { f(1, 2) }; try { g() } catch (e) { [e["message"], e["position"], e["stack"]] }`,
+			`[wrong number of arguments: want=1, got=2, 1:38, [f (1:38), g (1:55)]]`},
+		// try中的调用不是尾调用，错误仍然可以被捕获
+		{`let f = fn(n) { try { g(n) } catch (e) { "caught" } }; let g = fn(n) { throw n }; f(1)`, "caught"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no object returned for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, 2] != [2, 1]`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`[1, 2.0d] == [1.00d, 4 // 2]`, true},
+		{`Ok([1]) == Ok([1])`, true},
+		{`Ok(1) == Err(1)`, false},
+		{`None() == None()`, true},
+		{`Some("a") != Some("b")`, true},
+		{`let f = fn(x) { x }; f == f`, true},
+		{`fn(x) { x } == fn(x) { x }`, true},
+		{`fn(x) { x } == fn(y) { y }`, false},
+		{`let mk = fn(n) { fn(x) { x + n } }; mk(1) == mk(1)`, true},
+		{`let mk = fn(n) { fn(x) { x + n } }; mk(1) == mk(2)`, false},
+		{`let mk = fn(n) { fn(x) { let n = 5; x + n } }; mk(1) == mk(2)`, true},
+		{`let mk = fn() { let f = fn() { f }; f }; mk() == mk()`, true},
+		{`len == len`, true},
+		{`len == first`, false},
+		{`1 == "1"`, false},
+		{`[] == {}`, false},
+		{`null_value() == null_value()`, true},
+		{`try { throw error("x", "E") } catch (a) { try { throw error("x", "E") } catch (b) { a == b } }`, true},
+	}
+
+	for _, tt := range tests {
+		input := "let null_value = fn() { if (false) { 1 } }; " + tt.input
+		testBooleanObject(t, testEval(input), tt.expected)
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"apple" < "banana"`, true},
+		{`"b" > "abc"`, true},
+		{`false < true`, true},
+		{`[1, 2] < [1, 3]`, true},
+		{`[1, 2] < [1, 2, 0]`, true},
+		{`[2] > [1, 9]`, true},
+		{`{"a": 1} < {"a": 2}`, true},
+		{`Err(5) < Ok(1)`, true},
+		{`None() < Some(0)`, true},
+		{`1 < "a"`, "type mismatch: INTEGER < STRING"},
+		{`[1] > {}`, "type mismatch: ARRAY > HASH"},
+		{`len < len`, "unknown operator: BUILTIN < BUILTIN"},
+		{`fn() {} > fn() {}`, "unknown operator: FUNCTION > FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%s: expected error %q, got %s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort([1 // 2, 0.25d, 1, -3])`, "[-3, 0.25, 1/2, 1]"},
+		{`sort(["b", "a", "B"])`, "[B, a, b]"},
+		{`sort([[2], [1, 5], [1]])`, "[[1], [1, 5], [2]]"},
+		{`sort(["a", 1, true, [0]])`, "[true, 1, a, [0]]"},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]"},
+		{`sort(1)`, "ERROR: argument to `sort` must be ARRAY, got INTEGER (at 1:5)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTruthiness(t *testing.T) {
+	inputs := []string{
+		`if (0) { "yes" } else { "no" }`,
+		`if ("") { "yes" } else { "no" }`,
+		`if ([]) { "yes" } else { "no" }`,
+		`if ({"a": 1}) { "yes" } else { "no" }`,
+		`if (0.00d) { "yes" } else { "no" }`,
+		`if (null_value) { "yes" } else { "no" }`,
+		`if (true) { "yes" } else { "no" }`,
+		`!0`,
+		`!"a"`,
+		`!false`,
+	}
+	tests := []struct {
+		mode     object.Truthiness
+		expected []string
+	}{
+		{object.TruthinessDefault, []string{"yes", "yes", "yes", "yes", "yes", "no", "yes", "false", "false", "true"}},
+		{object.TruthinessFalsyEmpty, []string{"no", "no", "no", "yes", "no", "no", "yes", "true", "false", "true"}},
+		{object.TruthinessStrict, []string{
+			"condition must be BOOLEAN, got INTEGER (at 1:36)",
+			"condition must be BOOLEAN, got STRING (at 1:36)",
+			"condition must be BOOLEAN, got ARRAY (at 1:36)",
+			"condition must be BOOLEAN, got HASH (at 1:36)",
+			"condition must be BOOLEAN, got DECIMAL (at 1:36)",
+			"condition must be BOOLEAN, got NULL (at 1:36)",
+			"yes",
+			"unknown operator: !INTEGER (at 1:36)",
+			"unknown operator: !STRING (at 1:36)",
+			"true",
+		}},
+	}
+
+	for _, tt := range tests {
+		for i, input := range inputs {
+			input = "let null_value = if (false) { 1 }; " + input
+			evaluated := testEvalWithRuntime(input, &object.Runtime{Truthiness: tt.mode})
+			actual := evaluated.Inspect()
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Kind != object.TYPE_ERROR {
+					t.Errorf("mode %d, %s: wrong error kind %s", tt.mode, input, errObj.Kind)
+				}
+				actual = strings.TrimPrefix(actual, "ERROR: ")
+			}
+			if actual != tt.expected[i] {
+				t.Errorf("mode %d, %s: expected %s, got %s", tt.mode, input, tt.expected[i], actual)
+			}
+		}
+	}
+}
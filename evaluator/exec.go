@@ -0,0 +1,212 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// execWaitDelay 超时杀死程序之后，最多再等待它的子进程关闭输出这么久
+const execWaitDelay = time.Second
+
+// execBuiltin exec(command, options) 执行外部程序，等待它结束后返回{"stdout", "stderr", "exit_code"}。
+// options可以有args（参数数组）、cwd（工作目录）、env（追加的环境变量）和timeout（毫秒）。
+// 除非宿主允许继承全部环境变量，程序只能看到宿主的PATH和env中的变量。只允许执行部分程序时env不能设置restrictedEnv中的变量。
+// 程序以非0状态退出不是错误，找不到程序或者超时报IOError
+func execBuiltin(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	command, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "command passed to `exec` must be STRING, got %s", args[0].Type())
+	}
+	if rt.Exec == nil {
+		return newError(object.PERMISSION_ERROR, "permission denied: `exec` needs permission to run programs, which is not enabled")
+	}
+	if rt.Deterministic {
+		return newError(object.NONDETERMINISM_ERROR, "`exec` cannot run programs in deterministic mode, their results depend on the host")
+	}
+
+	options := &execOptions{}
+	if len(args) == 2 {
+		if err := options.parse(args[1]); err != nil {
+			return err
+		}
+	}
+
+	path, err := lookExecutable(command.Value, options.cwd)
+	if err != nil {
+		return newError(object.IO_ERROR, "`exec` cannot find executable %s: %s", command.Value, unwrapExecError(err))
+	}
+	if !execAllowed(rt.Exec, path) {
+		return newError(object.PERMISSION_ERROR, "permission denied: `exec` is not allowed to run %s", command.Value)
+	}
+	if len(rt.Exec.Allowed) > 0 {
+		for _, variable := range options.env {
+			if name, _, _ := strings.Cut(variable, "="); restrictedEnv(name) {
+				return newError(object.PERMISSION_ERROR, "permission denied: `exec` cannot set %s when only some programs are allowed", name)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, path, options.args...)
+	cmd.Args[0] = command.Value
+	cmd.Dir = options.cwd
+	cmd.Env = append(execEnvironment(rt.Exec), options.env...)
+	cmd.WaitDelay = execWaitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return newError(object.IO_ERROR, "`exec` timed out after %s running %s", options.timeout, command.Value)
+	}
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		return newError(object.IO_ERROR, "`exec` failed to run %s: %s", command.Value, unwrapExecError(runErr))
+	}
+
+	result := object.NewHash()
+	result.Set(&object.String{Value: "stdout"}, &object.String{Value: stdout.String()})
+	result.Set(&object.String{Value: "stderr"}, &object.String{Value: stderr.String()})
+	result.Set(&object.String{Value: "exit_code"}, &object.Integer{Value: int64(cmd.ProcessState.ExitCode())})
+	return result
+}
+
+// execEnvironment 程序从宿主继承的环境变量。结果不能为nil，nil的cmd.Env表示继承全部环境变量
+func execEnvironment(access *object.ExecAccess) []string {
+	if access.InheritEnv {
+		return os.Environ()
+	}
+	if path, ok := os.LookupEnv("PATH"); ok {
+		return []string{"PATH=" + path}
+	}
+	return []string{}
+}
+
+// restrictedEnv 只允许执行部分程序时脚本不能设置的环境变量。PATH决定执行哪个程序，
+// LD_和DYLD_开头的变量让动态链接器加载任意的代码，都可以借允许的程序执行其他程序
+func restrictedEnv(name string) bool {
+	name = strings.ToUpper(name)
+	return name == "PATH" || strings.HasPrefix(name, "LD_") || strings.HasPrefix(name, "DYLD_")
+}
+
+type execOptions struct {
+	args    []string
+	cwd     string
+	env     []string
+	timeout time.Duration
+}
+
+func (o *execOptions) parse(arg object.Object) *object.Error {
+	hash, ok := arg.(*object.Hash)
+	if !ok {
+		return newError(object.TYPE_ERROR, "options passed to `exec` must be HASH, got %s", arg.Type())
+	}
+	for _, hashKey := range hash.Keys {
+		pair := hash.Pairs[hashKey]
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return newError(object.TYPE_ERROR, "option names passed to `exec` must be STRING, got %s", pair.Key.Type())
+		}
+		switch key.Value {
+		case "args":
+			array, ok := pair.Value.(*object.Array)
+			if !ok {
+				return newError(object.TYPE_ERROR, "option `args` passed to `exec` must be ARRAY, got %s", pair.Value.Type())
+			}
+			for _, element := range array.Elements {
+				str, ok := element.(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "arguments passed to `exec` must be STRING, got %s", element.Type())
+				}
+				o.args = append(o.args, str.Value)
+			}
+		case "cwd":
+			str, ok := pair.Value.(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "option `cwd` passed to `exec` must be STRING, got %s", pair.Value.Type())
+			}
+			o.cwd = str.Value
+		case "env":
+			env, ok := pair.Value.(*object.Hash)
+			if !ok {
+				return newError(object.TYPE_ERROR, "option `env` passed to `exec` must be HASH, got %s", pair.Value.Type())
+			}
+			for _, envKey := range env.Keys {
+				name, isString := env.Pairs[envKey].Key.(*object.String)
+				value, valueIsString := env.Pairs[envKey].Value.(*object.String)
+				if !isString || !valueIsString {
+					return newError(object.TYPE_ERROR, "environment variables passed to `exec` must be STRING")
+				}
+				if name.Value == "" || strings.ContainsAny(name.Value, "=\x00") {
+					return newError(object.ARGUMENT_ERROR, "invalid environment variable name %q passed to `exec`", name.Value)
+				}
+				o.env = append(o.env, name.Value+"="+value.Value)
+			}
+		case "timeout":
+			ms, ok := pair.Value.(*object.Integer)
+			if !ok {
+				return newError(object.TYPE_ERROR, "option `timeout` passed to `exec` must be INTEGER, got %s", pair.Value.Type())
+			}
+			if ms.Value <= 0 || ms.Value > int64(time.Duration(1<<63-1)/time.Millisecond) {
+				return newError(object.ARGUMENT_ERROR, "option `timeout` passed to `exec` must be a positive number of milliseconds, got %d", ms.Value)
+			}
+			o.timeout = time.Duration(ms.Value) * time.Millisecond
+		default:
+			return newError(object.ARGUMENT_ERROR, "unknown option %q passed to `exec`", key.Value)
+		}
+	}
+	return nil
+}
+
+// lookExecutable 按照PATH查找程序，带路径的相对路径相对于cwd，返回要执行的程序的绝对路径
+func lookExecutable(command, cwd string) (string, error) {
+	if strings.ContainsRune(command, filepath.Separator) && !filepath.IsAbs(command) && cwd != "" {
+		command = filepath.Join(cwd, command)
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// execAllowed 程序是否在允许执行的列表中，列表为空时允许执行任何程序
+func execAllowed(access *object.ExecAccess, path string) bool {
+	if len(access.Allowed) == 0 {
+		return true
+	}
+	for _, allowed := range access.Allowed {
+		if allowedPath, err := lookExecutable(allowed, ""); err == nil && allowedPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapExecError 去掉错误信息中重复的程序名
+func unwrapExecError(err error) error {
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		return execErr.Err
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	dir := t.TempDir()
+	rt := &object.Runtime{Exec: &object.ExecAccess{}}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`exec("sh", {"args": ["-c", "printf %s \"$0\"", "héllo 世界"]})`, `{stdout: héllo 世界, stderr: , exit_code: 0}`},
+		{`exec("sh", {"args": ["-c", "echo out; echo err >&2; exit 3"]})`, "{stdout: out\n, stderr: err\n, exit_code: 3}"},
+		{`exec("sh", {"args": ["-c", "printf %s \"$GREETING\""], "env": {"GREETING": "hi"}})["stdout"]`, `hi`},
+		{`exec("sh", {"args": ["-c", "pwd"], "cwd": "{dir}"})["stdout"]`, dir + "\n"},
+		{`exec("true")["exit_code"]`, `0`},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "{dir}", dir)
+		evaluated := testEvalWithRuntime(input, rt)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		input   string
+		access  *object.ExecAccess
+		kind    string
+		message string
+	}{
+		{`exec("sh")`, nil, object.PERMISSION_ERROR, "permission denied: `exec` needs permission to run programs, which is not enabled"},
+		{`exec("sh")`, &object.ExecAccess{Allowed: []string{"true"}}, object.PERMISSION_ERROR, "permission denied: `exec` is not allowed to run sh"},
+		{`exec("sh", {"args": ["-c", "sleep 5"], "timeout": 50})`, &object.ExecAccess{}, object.IO_ERROR, "`exec` timed out after 50ms running sh"},
+		{`exec("no_such_program_here")`, &object.ExecAccess{}, object.IO_ERROR, "`exec` cannot find executable no_such_program_here: executable file not found in $PATH"},
+		{`exec(1)`, &object.ExecAccess{}, object.TYPE_ERROR, "command passed to `exec` must be STRING, got INTEGER"},
+		{`exec("sh", {"args": [1]})`, &object.ExecAccess{}, object.TYPE_ERROR, "arguments passed to `exec` must be STRING, got INTEGER"},
+		{`exec("sh", {"env": {"A=B": "c"}})`, &object.ExecAccess{}, object.ARGUMENT_ERROR, "invalid environment variable name \"A=B\" passed to `exec`"},
+		{`exec("true", {"env": {"LD_PRELOAD": "/tmp/x.so"}})`, &object.ExecAccess{Allowed: []string{"true"}}, object.PERMISSION_ERROR, "permission denied: `exec` cannot set LD_PRELOAD when only some programs are allowed"},
+		{`exec("true", {"env": {"DYLD_INSERT_LIBRARIES": "x"}})`, &object.ExecAccess{Allowed: []string{"true"}}, object.PERMISSION_ERROR, "permission denied: `exec` cannot set DYLD_INSERT_LIBRARIES when only some programs are allowed"},
+		{`exec("true", {"env": {"PATH": "/tmp"}})`, &object.ExecAccess{Allowed: []string{"true"}}, object.PERMISSION_ERROR, "permission denied: `exec` cannot set PATH when only some programs are allowed"},
+		{`exec("sh", {"timeout": 0})`, &object.ExecAccess{}, object.ARGUMENT_ERROR, "option `timeout` passed to `exec` must be a positive number of milliseconds, got 0"},
+		{`exec("sh", {"stdin": ""})`, &object.ExecAccess{}, object.ARGUMENT_ERROR, "unknown option \"stdin\" passed to `exec`"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEvalWithRuntime(tt.input, &object.Runtime{Exec: tt.access}).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestExecEnvironment(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	t.Setenv("EXEC_TEST_SECRET", "hunter")
+
+	tests := []struct {
+		access   *object.ExecAccess
+		expected string
+	}{
+		// 默认只继承PATH，再加上env中指定的变量
+		{&object.ExecAccess{}, "[, true, ok]"},
+		{&object.ExecAccess{InheritEnv: true}, "[hunter, true, ok]"},
+	}
+
+	input := `let run = fn(script) { exec("sh", {"args": ["-c", script], "env": {"EXTRA": "ok"}})["stdout"] };
+	[run("printf %s \"$EXEC_TEST_SECRET\""), run("true && printf %s \"$PATH\"") != "", run("printf %s \"$EXTRA\"")]`
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(input, &object.Runtime{Exec: tt.access})
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("InheritEnv=%t: expected %q, got %q", tt.access.InheritEnv, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExecAllowlist(t *testing.T) {
+	shPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// 允许列表中的程序名和路径都按照PATH查找之后比较
+	for _, allowed := range []string{"sh", shPath} {
+		rt := &object.Runtime{Exec: &object.ExecAccess{Allowed: []string{"true", allowed}}}
+		for _, command := range []string{"sh", shPath} {
+			input := `exec("` + command + `", {"args": ["-c", "exit 7"]})["exit_code"]`
+			if evaluated := testEvalWithRuntime(input, rt); evaluated.Inspect() != "7" {
+				t.Errorf("allowed %s, command %s: expected 7, got %s", allowed, command, evaluated.Inspect())
+			}
+		}
+	}
+}
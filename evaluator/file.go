@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// file模块。所有操作都要经过宿主在rt.Files中授予的权限，相对路径相对于进程的工作目录
+func init() {
+	registerModule("file", map[string]object.BuiltinFunction{
+		"read_file":  fileReadFile,
+		"write_file": fileWriteFile,
+		"list_dir":   fileListDir,
+		"exists":     fileExists,
+		"remove":     fileRemove,
+	})
+}
+
+// checkFileAccess 检查是否允许name对path的操作，返回解析了符号链接之后的绝对路径，之后的操作都使用这个路径
+func checkFileAccess(rt *object.Runtime, name, path string, write bool) (string, *object.Error) {
+	if rt.Files == nil {
+		return "", newError(object.PERMISSION_ERROR, "permission denied: `%s` needs file access, which is not enabled", name)
+	}
+	if write && rt.Files.ReadOnly {
+		return "", newError(object.PERMISSION_ERROR, "permission denied: `%s` cannot modify %s, file access is read-only", name, path)
+	}
+	real, err := realPath(path)
+	if err != nil {
+		return "", fileError(name, path, err)
+	}
+	for _, root := range rt.Files.Roots {
+		realRoot, err := realPath(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(realRoot, real)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if write && rel == "." {
+			return "", newError(object.PERMISSION_ERROR, "permission denied: `%s` cannot modify the allowed directory %s itself", name, path)
+		}
+		return real, nil
+	}
+	return "", newError(object.PERMISSION_ERROR, "permission denied: %s is outside the directories `%s` may access", path, name)
+}
+
+// realPath 返回解析了符号链接的绝对路径。路径末尾不存在的部分原样拼接在已经存在的上级目录后面，
+// 存在但是无法解析的符号链接报错，避免写入时跟随它写到别处
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return real, nil
+	}
+	if _, statErr := os.Lstat(abs); !errors.Is(statErr, fs.ErrNotExist) || filepath.Dir(abs) == abs {
+		return "", err
+	}
+	parent, err := realPath(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(abs)), nil
+}
+
+// fileError 把操作系统的错误转换为IOError，错误信息中使用脚本给出的路径
+func fileError(name, path string, err error) *object.Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError(object.IO_ERROR, "`%s` failed for %s: %s", name, path, err)
+}
+
+// pathArgument 检查第一个参数是路径并检查权限
+func pathArgument(rt *object.Runtime, name string, args []object.Object, n int, write bool) (string, string, *object.Error) {
+	if len(args) != n {
+		return "", "", newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return "", "", newError(object.TYPE_ERROR, "path passed to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	real, err := checkFileAccess(rt, name, path.Value, write)
+	return path.Value, real, err
+}
+
+func fileReadFile(rt *object.Runtime, args ...object.Object) object.Object {
+	path, real, err := pathArgument(rt, "read_file", args, 1, false)
+	if err != nil {
+		return err
+	}
+	data, readErr := os.ReadFile(real)
+	if readErr != nil {
+		return fileError("read_file", path, readErr)
+	}
+	return &object.String{Value: string(data)}
+}
+
+// fileWriteFile write_file(path, content) 创建或者覆盖文件，不会创建不存在的目录
+func fileWriteFile(rt *object.Runtime, args ...object.Object) object.Object {
+	path, real, err := pathArgument(rt, "write_file", args, 2, true)
+	if err != nil {
+		return err
+	}
+	content, ok := args[1].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "content passed to `write_file` must be STRING, got %s", args[1].Type())
+	}
+	if writeErr := os.WriteFile(real, []byte(content.Value), 0o644); writeErr != nil {
+		return fileError("write_file", path, writeErr)
+	}
+	return NULL
+}
+
+// fileListDir 目录中的文件名，按照名字排序
+func fileListDir(rt *object.Runtime, args ...object.Object) object.Object {
+	path, real, err := pathArgument(rt, "list_dir", args, 1, false)
+	if err != nil {
+		return err
+	}
+	entries, readErr := os.ReadDir(real)
+	if readErr != nil {
+		return fileError("list_dir", path, readErr)
+	}
+	names := make([]object.Object, len(entries))
+	for i, entry := range entries {
+		names[i] = &object.String{Value: entry.Name()}
+	}
+	return &object.Array{Elements: names}
+}
+
+func fileExists(rt *object.Runtime, args ...object.Object) object.Object {
+	_, real, err := pathArgument(rt, "exists", args, 1, false)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(real)
+	return nativeBoolToBooleanObject(statErr == nil)
+}
+
+// fileRemove 删除文件或者空目录
+func fileRemove(rt *object.Runtime, args ...object.Object) object.Object {
+	path, real, err := pathArgument(rt, "remove", args, 1, true)
+	if err != nil {
+		return err
+	}
+	if removeErr := os.Remove(real); removeErr != nil {
+		return fileError("remove", path, removeErr)
+	}
+	return NULL
+}
@@ -0,0 +1,129 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const filePrelude = `let {read_file, write_file, list_dir, exists, remove} = import "file"; `
+
+// fileTestDirs 创建允许访问的目录root和它之外的目录outside，脚本中的{root}和{outside}替换为它们的路径
+func fileTestDirs(t *testing.T) (string, string, func(string) string) {
+	t.Helper()
+	base := t.TempDir()
+	root, outside := filepath.Join(base, "root"), filepath.Join(base, "outside")
+	for _, dir := range []string{root, filepath.Join(root, "sub"), outside} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "hello.txt"), []byte("héllo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	expand := strings.NewReplacer("{root}", root, "{outside}", outside).Replace
+	return root, outside, expand
+}
+
+func TestFileModule(t *testing.T) {
+	root, _, expand := fileTestDirs(t)
+	rt := &object.Runtime{Files: &object.FileAccess{Roots: []string{root}}}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`read_file("{root}/hello.txt")`, "héllo"},
+		{`read_file("{root}/sub/../hello.txt")`, "héllo"},
+		{`write_file("{root}/sub/new.txt", "数据"); read_file("{root}/sub/new.txt")`, "数据"},
+		{`list_dir("{root}")`, "[escape, hello.txt, sub]"},
+		{`[exists("{root}/hello.txt"), exists("{root}/missing.txt"), exists("{root}/sub")]`, "[true, false, true]"},
+		{`write_file("{root}/gone.txt", ""); remove("{root}/gone.txt"); exists("{root}/gone.txt")`, "false"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(filePrelude+expand(tt.input), rt)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFilePermissions(t *testing.T) {
+	root, outside, expand := fileTestDirs(t)
+
+	tests := []struct {
+		input   string
+		files   *object.FileAccess
+		kind    string
+		message string
+	}{
+		{`read_file("{root}/hello.txt")`, nil, object.PERMISSION_ERROR,
+			"permission denied: `read_file` needs file access, which is not enabled"},
+		{`exists("{root}/hello.txt")`, nil, object.PERMISSION_ERROR,
+			"permission denied: `exists` needs file access, which is not enabled"},
+		{`read_file("{outside}/secret.txt")`, &object.FileAccess{Roots: []string{root}}, object.PERMISSION_ERROR,
+			"permission denied: {outside}/secret.txt is outside the directories `read_file` may access"},
+		{`read_file("{root}/../outside/secret.txt")`, &object.FileAccess{Roots: []string{root}}, object.PERMISSION_ERROR,
+			"permission denied: {root}/../outside/secret.txt is outside the directories `read_file` may access"},
+		{`read_file("{root}/escape/secret.txt")`, &object.FileAccess{Roots: []string{root}}, object.PERMISSION_ERROR,
+			"permission denied: {root}/escape/secret.txt is outside the directories `read_file` may access"},
+		{`write_file("{root}/escape/new.txt", "x")`, &object.FileAccess{Roots: []string{root}}, object.PERMISSION_ERROR,
+			"permission denied: {root}/escape/new.txt is outside the directories `write_file` may access"},
+		{`write_file("{root}/new.txt", "x")`, &object.FileAccess{Roots: []string{root}, ReadOnly: true}, object.PERMISSION_ERROR,
+			"permission denied: `write_file` cannot modify {root}/new.txt, file access is read-only"},
+		{`remove("{root}/hello.txt")`, &object.FileAccess{Roots: []string{root}, ReadOnly: true}, object.PERMISSION_ERROR,
+			"permission denied: `remove` cannot modify {root}/hello.txt, file access is read-only"},
+		{`remove("{root}")`, &object.FileAccess{Roots: []string{root}}, object.PERMISSION_ERROR,
+			"permission denied: `remove` cannot modify the allowed directory {root} itself"},
+		{`read_file("{root}/missing.txt")`, &object.FileAccess{Roots: []string{root}}, object.IO_ERROR,
+			"`read_file` failed for {root}/missing.txt: no such file or directory"},
+		{`write_file("{root}/missing/new.txt", "x")`, &object.FileAccess{Roots: []string{root}}, object.IO_ERROR,
+			"`write_file` failed for {root}/missing/new.txt: no such file or directory"},
+		{`remove("{root}/sub/..")`, &object.FileAccess{Roots: []string{root}}, object.PERMISSION_ERROR,
+			"permission denied: `remove` cannot modify the allowed directory {root}/sub/.. itself"},
+		{`read_file(1)`, &object.FileAccess{Roots: []string{root}}, object.TYPE_ERROR,
+			"path passed to `read_file` must be STRING, got INTEGER"},
+		{`write_file("{root}/new.txt", 1)`, &object.FileAccess{Roots: []string{root}}, object.TYPE_ERROR,
+			"content passed to `write_file` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		rt := &object.Runtime{Files: tt.files}
+		errObj, ok := testEvalWithRuntime(filePrelude+expand(tt.input), rt).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != expand(tt.message) {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, expand(tt.message), errObj.Kind, errObj.Message)
+		}
+	}
+
+	// 被拒绝的操作不能产生任何效果
+	if _, err := os.Stat(filepath.Join(root, "new.txt")); err == nil {
+		t.Errorf("read-only write_file created a file")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Errorf("write_file followed a symlink out of the allowed directory")
+	}
+}
+
+func TestFileAccessMultipleRoots(t *testing.T) {
+	root, outside, expand := fileTestDirs(t)
+	rt := &object.Runtime{Files: &object.FileAccess{Roots: []string{root, outside}, ReadOnly: true}}
+
+	input := expand(`read_file("{outside}/secret.txt") + read_file("{root}/escape/secret.txt")`)
+	evaluated := testEvalWithRuntime(filePrelude+input, rt)
+	if evaluated.Inspect() != "secretsecret" {
+		t.Errorf("expected secretsecret, got %s", evaluated.Inspect())
+	}
+}
@@ -0,0 +1,247 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+)
+
+const (
+	// maxJSONDepth 解析时数组和对象最多嵌套的层数
+	maxJSONDepth = 1000
+	// maxJSONExponent 解析时数字的指数的最大绝对值，更大的指数得到的小数太长
+	maxJSONExponent = 4096
+)
+
+// json模块。对象对应哈希表，没有小数点和指数的数字对应整数，其余的数字对应小数
+func init() {
+	registerModule("json", map[string]object.BuiltinFunction{
+		"parse":     jsonParse,
+		"stringify": jsonStringify,
+	})
+}
+
+// jsonParse parse(text) 解析JSON文本，对象中键的顺序保持不变，重复的键取最后一个值
+func jsonParse(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("parse", args, 1)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(values[0]))
+	decoder.UseNumber()
+
+	result, err := decodeJSON(decoder, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return newError(object.ARGUMENT_ERROR, "invalid JSON: unexpected data after value at offset %d", decoder.InputOffset())
+	}
+	return result
+}
+
+func decodeJSON(decoder *json.Decoder, depth int) (object.Object, *object.Error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, jsonSyntaxError(decoder, err)
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if depth >= maxJSONDepth {
+			return nil, newError(object.ARGUMENT_ERROR, "invalid JSON: nesting deeper than %d levels", maxJSONDepth)
+		}
+		if tok == '[' {
+			elements := []object.Object{}
+			for decoder.More() {
+				element, err := decodeJSON(decoder, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, jsonSyntaxError(decoder, err)
+			}
+			return &object.Array{Elements: elements}, nil
+		}
+
+		hash := object.NewHash()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, jsonSyntaxError(decoder, err)
+			}
+			value, errObj := decodeJSON(decoder, depth+1)
+			if errObj != nil {
+				return nil, errObj
+			}
+			hash.Set(&object.String{Value: key.(string)}, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, jsonSyntaxError(decoder, err)
+		}
+		return hash, nil
+	case json.Number:
+		return jsonNumber(string(tok))
+	case string:
+		return &object.String{Value: tok}, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	default:
+		return NULL, nil
+	}
+}
+
+// jsonSyntaxError 把解码器的错误转换为ArgumentError，文本不完整时不显示位置
+func jsonSyntaxError(decoder *json.Decoder, err error) *object.Error {
+	var syntax *json.SyntaxError
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntax) && syntax.Error() == "unexpected end of JSON input" {
+		return newError(object.ARGUMENT_ERROR, "invalid JSON: unexpected end of input")
+	}
+	if syntax != nil {
+		return newError(object.ARGUMENT_ERROR, "invalid JSON: %s at offset %d", syntax.Error(), syntax.Offset)
+	}
+	return newError(object.ARGUMENT_ERROR, "invalid JSON: %s at offset %d", err, decoder.InputOffset())
+}
+
+// jsonNumber 整数可以超出int64，带小数点或指数的数字精确地转换为小数，例如1.5e3得到1500
+func jsonNumber(text string) (object.Object, *object.Error) {
+	mantissa, exponent := text, int64(0)
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		mantissa = text[:i]
+		exp, ok := new(big.Int).SetString(strings.TrimPrefix(text[i+1:], "+"), 10)
+		if !ok || !exp.IsInt64() || exp.Int64() > maxJSONExponent || exp.Int64() < -maxJSONExponent {
+			return nil, newError(object.ARGUMENT_ERROR, "JSON number %s is out of range", text)
+		}
+		exponent = exp.Int64()
+	} else if !strings.Contains(text, ".") {
+		value, _ := new(big.Int).SetString(text, 10)
+		return normalizeBigInt(value), nil
+	}
+
+	decimal, _ := object.ParseDecimal(mantissa)
+	scale := int64(decimal.Scale) - exponent
+	if scale < 0 {
+		decimal.Value.Mul(decimal.Value, pow10(int(-scale)))
+		scale = 0
+	}
+	decimal.Scale = int(scale)
+	return decimal, nil
+}
+
+// jsonStringify stringify(value, indent) 把值编码为JSON文本。
+// indent是每层缩进的空格数或者缩进用的字符串，省略时输出不带空白的紧凑格式
+func jsonStringify(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	e := &jsonEncoder{rt: rt}
+	if len(args) == 2 {
+		switch indent := args[1].(type) {
+		case *object.Integer:
+			if indent.Value < 0 || indent.Value > 10 {
+				return newError(object.ARGUMENT_ERROR, "indent passed to `stringify` must be between 0 and 10, got %d", indent.Value)
+			}
+			e.indent = strings.Repeat(" ", int(indent.Value))
+		case *object.String:
+			e.indent = indent.Value
+		default:
+			return newError(object.TYPE_ERROR, "indent passed to `stringify` must be INTEGER or STRING, got %s", args[1].Type())
+		}
+		e.pretty = true
+	}
+	if err := e.encode(args[0], 0); err != nil {
+		return err
+	}
+	return &object.String{Value: e.out.String()}
+}
+
+type jsonEncoder struct {
+	rt     *object.Runtime
+	out    strings.Builder
+	pretty bool
+	indent string
+}
+
+func (e *jsonEncoder) encode(value object.Object, depth int) *object.Error {
+	switch value := value.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean, *object.Integer, *object.BigInt, *object.Decimal:
+		e.out.WriteString(value.Inspect())
+	case *object.Rational:
+		// 分数没有对应的JSON数字，按照小数的精度转换为小数
+		e.out.WriteString(quotientToDecimal(value.Value.Num(), value.Value.Denom(), 0, e.rt).Inspect())
+	case *object.String:
+		e.writeString(value.Value)
+	case *object.Array:
+		if len(value.Elements) == 0 {
+			e.out.WriteString("[]")
+			return nil
+		}
+		e.out.WriteByte('[')
+		for i, element := range value.Elements {
+			e.separator(i, depth+1)
+			if err := e.encode(element, depth+1); err != nil {
+				return err
+			}
+		}
+		e.newline(depth)
+		e.out.WriteByte(']')
+	case *object.Hash:
+		if len(value.Keys) == 0 {
+			e.out.WriteString("{}")
+			return nil
+		}
+		e.out.WriteByte('{')
+		for i, hashKey := range value.Keys {
+			pair := value.Pairs[hashKey]
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "cannot encode hash key of type %s as JSON, keys must be STRING", pair.Key.Type())
+			}
+			e.separator(i, depth+1)
+			e.writeString(key.Value)
+			e.out.WriteByte(':')
+			if e.pretty {
+				e.out.WriteByte(' ')
+			}
+			if err := e.encode(pair.Value, depth+1); err != nil {
+				return err
+			}
+		}
+		e.newline(depth)
+		e.out.WriteByte('}')
+	default:
+		return newError(object.TYPE_ERROR, "cannot encode value of type %s as JSON", value.Type())
+	}
+	return nil
+}
+
+// separator 在数组和对象的第i个元素之前输出逗号，以及美化格式下的换行和缩进
+func (e *jsonEncoder) separator(i, depth int) {
+	if i > 0 {
+		e.out.WriteByte(',')
+	}
+	e.newline(depth)
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.pretty {
+		e.out.WriteByte('\n')
+		e.out.WriteString(strings.Repeat(e.indent, depth))
+	}
+}
+
+// writeString 输出带引号和转义的字符串，非ASCII字符原样输出
+func (e *jsonEncoder) writeString(s string) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	e.out.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
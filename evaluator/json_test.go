@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"strings"
+	"testing"
+)
+
+const jsonPrelude = `let {parse, stringify} = import "json"; `
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		typ      object.ObjectType
+	}{
+		{`parse("42")`, "42", object.INTEGER_OBJ},
+		{`parse("-7")`, "-7", object.INTEGER_OBJ},
+		{`parse("123456789012345678901234567890")`, "123456789012345678901234567890", object.BIGINT_OBJ},
+		{`parse("2.50")`, "2.50", object.DECIMAL_OBJ},
+		{`parse("1.5e3")`, "1500", object.DECIMAL_OBJ},
+		{`parse("-25E-3")`, "-0.025", object.DECIMAL_OBJ},
+		{`parse("\"h\\u00e9llo \\u4e16\\n\"")`, "héllo 世\n", object.STRING_OBJ},
+		{`parse("true")`, "true", object.BOOLEAN_OBJ},
+		{`parse(" null ")`, "null", object.NULL_OBJ},
+		{`parse("[1, [], {}]")`, "[1, [], {}]", object.ARRAY_OBJ},
+		{`parse("{\"b\": 1, \"a\": [true, null]}")`, "{b: 1, a: [true, null]}", object.HASH_OBJ},
+		{`parse("{\"a\": 1, \"a\": 2}")`, "{a: 2}", object.HASH_OBJ},
+		{`parse("{\"k\": {\"n\": 0.1}}")["k"]["n"] + 0.2d`, "0.3", object.DECIMAL_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(jsonPrelude + tt.input)
+		if evaluated.Type() != tt.typ || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.input, tt.typ, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSONStringify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`stringify({"b": [1, 2.50d, -3], "a": {"x": parse("null"), "y": false}})`, `{"b":[1,2.50,-3],"a":{"x":null,"y":false}}`},
+		{`stringify("tab\t \"quote\" <é>")`, `"tab\t \"quote\" <é>"`},
+		{`stringify(pow(2, 70))`, `1180591620717411303424`},
+		{`stringify(1 // 4)`, `0.25`},
+		{`stringify([[], {}])`, `[[],{}]`},
+		{`stringify(parse("{\"a\":[1,2.0,{\"b\":null}]}"))`, `{"a":[1,2.0,{"b":null}]}`},
+		{`stringify({"a": [1, {"b": []}], "c": "d"}, 2)`, "{\n  \"a\": [\n    1,\n    {\n      \"b\": []\n    }\n  ],\n  \"c\": \"d\"\n}"},
+		{`stringify([1, 2], "\t")`, "[\n\t1,\n\t2\n]"},
+		{`stringify([1], 0)`, "[\n1\n]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(jsonPrelude + `let {pow} = import "math"; ` + tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: expected a STRING, got %s %s", tt.input, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{`parse("[1, 2")`, object.ARGUMENT_ERROR, "invalid JSON: unexpected end of input"},
+		{`parse("")`, object.ARGUMENT_ERROR, "invalid JSON: unexpected end of input"},
+		{`parse("{\"a\" 1}")`, object.ARGUMENT_ERROR, "invalid JSON: invalid character '1' after object key at offset 6"},
+		{`parse("[1] 2")`, object.ARGUMENT_ERROR, "invalid JSON: unexpected data after value at offset 5"},
+		{`parse("1e99999")`, object.ARGUMENT_ERROR, "JSON number 1e99999 is out of range"},
+		{`parse(1)`, object.TYPE_ERROR, "argument to `parse` must be STRING, got INTEGER"},
+		{`stringify(fn(x) { x })`, object.TYPE_ERROR, "cannot encode value of type FUNCTION as JSON"},
+		{`stringify({"f": [len]})`, object.TYPE_ERROR, "cannot encode value of type BUILTIN as JSON"},
+		{`stringify(Ok(1))`, object.TYPE_ERROR, "cannot encode value of type RESULT as JSON"},
+		{`stringify({1: 2})`, object.TYPE_ERROR, "cannot encode hash key of type INTEGER as JSON, keys must be STRING"},
+		{`stringify(1, 11)`, object.ARGUMENT_ERROR, "indent passed to `stringify` must be between 0 and 10, got 11"},
+		{`stringify(1, true)`, object.TYPE_ERROR, "indent passed to `stringify` must be INTEGER or STRING, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(jsonPrelude + tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestJSONNestingLimit(t *testing.T) {
+	input := `parse("` + strings.Repeat("[", maxJSONDepth+1) + `")`
+	evaluated := testEval(jsonPrelude + input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "invalid JSON: nesting deeper than 1000 levels" {
+		t.Errorf("expected a nesting error, got %s", evaluated.Inspect())
+	}
+}
@@ -0,0 +1,340 @@
+package evaluator
+
+import (
+	"Interp/ast"
+	"Interp/object"
+	"Interp/token"
+	"fmt"
+	"sync"
+)
+
+// maxExpansionDepth 宏展开的结果中可以继续调用宏，限制嵌套的层数以免无限展开
+const maxExpansionDepth = 100
+
+// DefineMacros 找出程序顶层形如 let name = macro(...) {...}; 的语句，
+// 将宏保存到env中，并从程序中删除这些语句。应当在ParseProgram之后、ExpandMacros之前调用
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	var definitions []int
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			// 宏定义中的名字可能出现在展开的结果中
+			reserveNames(statement)
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	// 从后往前删除，保证前面的下标仍然有效
+	for i := len(definitions) - 1; i >= 0; i = i - 1 {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok || letStatement.Name == nil {
+		return false
+	}
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement, _ := stmt.(*ast.LetStatement)
+	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros 将程序中对宏的调用替换为宏返回的语法树。宏的参数不求值，而是以Quote的形式传入，
+// 宏体必须返回一个Quote。展开失败时返回错误，此时程序可能已经被部分展开
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	reserveNames(program)
+	expanded, err := expandMacros(program, env, 0)
+	if err != nil {
+		return expanded, err
+	}
+	// 同一个参数可以被unquote多次，拷贝之后每个节点只出现一次，resolver才能在节点上记录各自的绑定
+	return ast.Copy(expanded), nil
+}
+
+func expandMacros(node ast.Node, env *object.Environment, depth int) (ast.Node, error) {
+	if depth > maxExpansionDepth {
+		return node, fmt.Errorf("macro expansion exceeded %d levels", maxExpansionDepth)
+	}
+
+	var expandErr error
+	expanded := ast.Modify(node, func(node ast.Node) ast.Node {
+		if expandErr != nil {
+			return node
+		}
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		macro, ok := isMacroCall(callExpression, env)
+		if !ok {
+			return node
+		}
+
+		if len(callExpression.Arguments) != len(macro.Parameters) {
+			expandErr = fmt.Errorf("wrong number of arguments to macro %s: want=%d, got=%d",
+				callExpression.Function.String(), len(macro.Parameters), len(callExpression.Arguments))
+			return node
+		}
+		args := quoteArgs(callExpression)
+		evalEnv := extendMacroEnv(macro, args)
+		argNodes := collectNodes(callExpression.Arguments)
+
+		evaluated := unwarpReturnValue(Eval(macro.Body, evalEnv))
+		if err, ok := evaluated.(*object.Error); ok {
+			expandErr = fmt.Errorf("error expanding macro %s: %s", callExpression.Function.String(), err.Message)
+			return node
+		}
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			expandErr = fmt.Errorf("macro %s must return a quote, got %s", callExpression.Function.String(), typeOf(evaluated))
+			return node
+		}
+
+		expansion := &token.Expansion{Macro: callExpression.Function.String(), Call: callExpression.Token}
+		applyHygiene(quote.Node, argNodes, expansion)
+
+		// 宏返回的语法树中可能还有宏调用，继续展开
+		result, err := expandMacros(quote.Node, env, depth+1)
+		if err != nil {
+			expandErr = err
+			return node
+		}
+		return result
+	})
+	return expanded, expandErr
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		return nil, false
+	}
+
+	return macro, true
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	var args []*object.Quote
+
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+
+	return extended
+}
+
+// typeOf 返回对象的类型，宏体没有返回值时为nil
+func typeOf(obj object.Object) string {
+	if obj == nil {
+		return "nothing"
+	}
+	return string(obj.Type())
+}
+
+// gensymState counter保证gensym生成的名字在整个进程中唯一，reserved是展开过的程序和定义过的宏中出现的名字。
+// prefix__12这样的名字用户也可以写，生成的名字必须跳过它们
+var gensymState struct {
+	sync.Mutex
+	counter  uint64
+	reserved map[string]bool
+}
+
+// reserveNames 记录node中出现的标识符，gensym不会生成这些名字
+func reserveNames(node ast.Node) {
+	gensymState.Lock()
+	defer gensymState.Unlock()
+	if gensymState.reserved == nil {
+		gensymState.reserved = make(map[string]bool)
+	}
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			gensymState.reserved[ident.Value] = true
+		}
+		return true
+	})
+}
+
+// gensym 生成一个不会与用户代码冲突的标识符名字，形如 prefix__12
+func gensym(prefix string) string {
+	gensymState.Lock()
+	defer gensymState.Unlock()
+	for {
+		gensymState.counter++
+		name := fmt.Sprintf("%s__%d", prefix, gensymState.counter)
+		if !gensymState.reserved[name] {
+			return name
+		}
+	}
+}
+
+// collectNodes 收集宏调用参数中的所有节点。展开结果中这些节点来自调用处，其余节点来自宏定义
+func collectNodes(args []ast.Expression) map[ast.Node]bool {
+	nodes := make(map[ast.Node]bool)
+	for _, arg := range args {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			if node != nil {
+				nodes[node] = true
+			}
+			return true
+		})
+	}
+	return nodes
+}
+
+// applyHygiene 让宏展开保持卫生：宏定义中引入的绑定（let、函数参数、catch参数和解构模式）以及在它们作用域之内的引用
+// 都会被重命名为gensym生成的名字，这样既不会捕获调用处的同名变量，也不会被调用处的变量遮蔽。
+// 作用域之外同名的引用指向调用处的变量，保持不变。
+// 同时为来自宏定义的节点记录宏调用的位置，使错误信息可以同时指出定义处和调用处
+func applyHygiene(root ast.Node, argNodes map[ast.Node]bool, expansion *token.Expansion) {
+	h := &hygiene{argNodes: argNodes, expansion: expansion}
+	h.walk(root, &hygieneScope{renames: make(map[string]string)})
+	for len(h.pending) > 0 {
+		next := h.pending[0]
+		h.pending = h.pending[1:]
+		next()
+	}
+}
+
+// hygieneScope 模板中的一层作用域，记录这一层引入的绑定被重命名成的名字。
+// 与resolver一致，函数体和catch块各有一层，if等其他块不创建作用域
+type hygieneScope struct {
+	renames map[string]string
+	outer   *hygieneScope
+}
+
+func (s *hygieneScope) lookup(name string) (string, bool) {
+	for ; s != nil; s = s.outer {
+		if renamed, ok := s.renames[name]; ok {
+			return renamed, true
+		}
+	}
+	return "", false
+}
+
+type hygiene struct {
+	argNodes  map[ast.Node]bool
+	expansion *token.Expansion
+	pending   []func() // pending 函数体在外层作用域的绑定都确定之后才处理，可以引用在它之后定义的变量
+}
+
+// walk 按照求值的顺序遍历模板中来自宏定义的节点，跳过从调用处传入的参数
+func (h *hygiene) walk(node ast.Node, s *hygieneScope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		if node == nil || h.argNodes[node] {
+			return false
+		}
+		if tok := ast.TokenOf(node); tok != nil && tok.Expansion == nil {
+			tok.Expansion = h.expansion
+		}
+
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if renamed, ok := s.lookup(node.Value); ok {
+				node.Value = renamed
+				node.Token.Literal = renamed
+			}
+		case *ast.LetStatement:
+			// 先处理值再绑定，let x = x + 1中右边的x不是这个绑定
+			h.walk(node.Value, s)
+			if node.Pattern != nil {
+				h.bind(node.Pattern, s)
+				h.walk(node.Pattern, s)
+			} else {
+				h.bind(node.Name, s)
+				h.walk(node.Name, s)
+			}
+			return false
+		case *ast.FunctionLiteral:
+			inner := &hygieneScope{renames: make(map[string]string), outer: s}
+			h.pending = append(h.pending, func() {
+				for _, param := range node.Parameters {
+					h.bind(param, inner)
+				}
+				for _, param := range node.Parameters {
+					h.walk(param, inner)
+				}
+				h.walk(node.Body, inner)
+			})
+			return false
+		case *ast.TryExpression:
+			h.walk(node.Block, s)
+			if node.Catch != nil {
+				inner := &hygieneScope{renames: make(map[string]string), outer: s}
+				h.bind(node.CatchParam, inner)
+				h.walk(node.CatchParam, inner)
+				h.walk(node.Catch, inner)
+			}
+			if node.Finally != nil {
+				h.walk(node.Finally, s)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// bind 在作用域s中为模式绑定的、来自宏定义的标识符生成新名字
+func (h *hygiene) bind(pattern ast.Pattern, s *hygieneScope) {
+	bindPatternIdentifiers(pattern, func(ident *ast.Identifier) {
+		if ident == nil || h.argNodes[ident] {
+			return
+		}
+		if _, ok := s.renames[ident.Value]; !ok {
+			s.renames[ident.Value] = gensym(ident.Value)
+		}
+	})
+}
+
+// bindPatternIdentifiers 对模式中每个被绑定的标识符调用bind
+func bindPatternIdentifiers(pattern ast.Pattern, bind func(*ast.Identifier)) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		bind(pattern)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			bindPatternIdentifiers(element, bind)
+		}
+		bind(pattern.Rest)
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			bindPatternIdentifiers(pair.Value, bind)
+		}
+	}
+}
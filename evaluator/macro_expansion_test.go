@@ -0,0 +1,370 @@
+package evaluator
+
+import (
+	"Interp/ast"
+	"Interp/lexer"
+	"Interp/object"
+	"Interp/parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	_, ok := env.Get("number")
+	if ok {
+		t.Fatalf("number should not be defined")
+	}
+	_, ok = env.Get("function")
+	if ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "{ (x + y) }"
+
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2); };
+			let quadruple = macro(x) { quote(double(double(unquote(x)))); };
+
+			quadruple(1);
+			`,
+			`((1 * 2) * 2)`,
+		},
+		{
+			`
+			let huge = macro() { let {pow} = import "math"; quote(unquote(pow(2, 64)) + 1); };
+
+			huge();
+			`,
+			`(18446744073709551616 + 1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("unexpected expansion error: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro(x) { 1 }; m(2);`,
+			"macro m must return a quote, got INTEGER",
+		},
+		{
+			`let m = macro(x) { quote(x) }; m(1, 2);`,
+			"wrong number of arguments to macro m: want=1, got=2",
+		},
+		{
+			`let m = macro(x) { missing }; m(1);`,
+			"error expanding macro m: identifier not found: missing",
+		},
+		{
+			`let loop = macro() { quote(loop()) }; loop();`,
+			"macro expansion exceeded 100 levels",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected expansion error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestUnlessMacroEvaluation(t *testing.T) {
+	input := `
+	let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) {
+			unquote(consequence);
+		} else {
+			unquote(alternative);
+		});
+	};
+	unless(10 > 5, 1, 2);
+	`
+
+	program := testParseProgram(input)
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, err := ExpandMacros(program, macroEnv)
+	if err != nil {
+		t.Fatalf("unexpected expansion error: %s", err)
+	}
+
+	testIntegerObject(t, Eval(expanded, object.NewEnvironment()), 2)
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	return p.ParseProgram()
+}
+
+func TestMacroHygiene(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			// 宏中的参数t不会捕获调用处的t
+			`let my_or = macro(a, b) {
+				quote(fn(t) { if (t) { t } else { unquote(b) } }(unquote(a)));
+			};
+			let t = 5;
+			my_or(false, t);`,
+			5,
+		},
+		{
+			// 宏中的let不会覆盖调用处的同名变量
+			`let twice = macro(x) {
+				quote(fn() { let v = unquote(x); v + v }());
+			};
+			let v = 3;
+			twice(v * 10) + v;`,
+			63,
+		},
+		{
+			// 解构模式和catch参数同样会被重命名
+			`let safe = macro(x, fallback) {
+				quote(try { let [e] = [unquote(x)]; e } catch (e) { unquote(fallback) });
+			};
+			let e = 7;
+			safe(missing, e);`,
+			7,
+		},
+		{
+			// 通过gensym生成的绑定
+			`let swap_sub = macro(a, b) {
+				let tmp = gensym("tmp");
+				quote(fn(unquote(tmp)) { unquote(b) - unquote(tmp) }(unquote(a)));
+			};
+			let tmp = 1;
+			swap_sub(tmp, 10);`,
+			9,
+		},
+		{
+			// 只重命名绑定作用域之内的引用，之外同名的t仍然指向调用处的变量
+			`let t = 100;
+			let m = macro(a) { quote(t + fn(t) { t * 2 }(unquote(a))) };
+			m(1);`,
+			102,
+		},
+		{
+			// let之前的引用不在这个绑定的作用域之内
+			`let t = 100;
+			let m = macro(a) { quote(fn() { let r = t; let t = unquote(a); r + t }()) };
+			m(1);`,
+			101,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testExpandAndEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestMacroHygieneRenamesOnlyTemplateBindings(t *testing.T) {
+	input := `let bind = macro(value, body) {
+		quote(fn(x) { unquote(body) }(unquote(value)));
+	};
+	bind(1, x);`
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("unexpected expansion error: %s", err)
+	}
+
+	call := expanded.(*ast.Program).Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	function := call.Function.(*ast.FunctionLiteral)
+	param := function.Parameters[0].(*ast.Identifier)
+	if !strings.HasPrefix(param.Value, "x__") {
+		t.Errorf("template parameter was not renamed. got=%q", param.Value)
+	}
+	// 函数体来自调用处的参数，其中的x指向调用处的绑定，不能被重命名
+	body := function.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.Identifier)
+	if body.Value != "x" {
+		t.Errorf("identifier from call site was renamed. got=%q", body.Value)
+	}
+}
+
+func TestMacroHygieneAvoidsProgramNames(t *testing.T) {
+	// 调用处的变量恰好是下一个会生成的名字，重命名时必须跳过它
+	gensymState.Lock()
+	next := fmt.Sprintf("x__%d", gensymState.counter+1)
+	gensymState.Unlock()
+
+	input := fmt.Sprintf(`let %[1]s = 100;
+let g = macro(e) { quote(fn(x) { x + unquote(e) }) };
+g(%[1]s)(1);`, next)
+	testIntegerObject(t, testExpandAndEval(t, input), 101)
+}
+
+func TestGensym(t *testing.T) {
+	evaluated := testEval(`[gensym(), gensym("tmp")]`)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	first := array.Elements[0].(*object.Quote).Node.(*ast.Identifier).Value
+	second := array.Elements[1].(*object.Quote).Node.(*ast.Identifier).Value
+	if !strings.HasPrefix(first, "g__") || !strings.HasPrefix(second, "tmp__") {
+		t.Errorf("unexpected gensym names: %q, %q", first, second)
+	}
+	if first == second {
+		t.Errorf("gensym returned the same name twice: %q", first)
+	}
+}
+
+func TestMacroErrorPositions(t *testing.T) {
+	input := `let bad = macro(x) { quote(unquote(x) + true) };
+let nested = macro(y) { quote(bad(unquote(y))) };
+bad(1);
+nested(2);`
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("unexpected expansion error: %s", err)
+	}
+
+	statements := expanded.(*ast.Program).Statements
+	tests := []struct {
+		statement ast.Statement
+		expected  string
+	}{
+		{statements[0], "1:39 (expanded from bad at 3:4)"},
+		{statements[1], "1:39 (expanded from bad at 2:34 (expanded from nested at 4:7))"},
+	}
+
+	for _, tt := range tests {
+		evaluated := Eval(tt.statement, object.NewEnvironment())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		}
+		if errObj.Position != tt.expected {
+			t.Errorf("wrong error position. want=%q, got=%q", tt.expected, errObj.Position)
+		}
+	}
+}
+
+func testExpandAndEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	program := testParseProgram(input)
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, err := ExpandMacros(program, macroEnv)
+	if err != nil {
+		t.Fatalf("unexpected expansion error: %s", err)
+	}
+	// 与testEval相同，未声明的变量在运行时报错
+	NewResolver().Resolve(expanded)
+	return Eval(expanded, object.NewEnvironment())
+}
@@ -0,0 +1,330 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"math"
+	"math/big"
+)
+
+// maxPowBits pow的结果最多的二进制位数，更大的结果计算起来太慢，报ArithmeticError
+const maxPowBits = 1 << 24
+
+func init() {
+	registerModule("math", map[string]object.BuiltinFunction{
+		"abs":   mathAbs,
+		"min":   mathExtreme("min", -1),
+		"max":   mathExtreme("max", 1),
+		"pow":   mathPow,
+		"sqrt":  mathSqrt,
+		"floor": mathRound("floor", object.RoundFloor),
+		"ceil":  mathRound("ceil", object.RoundCeiling),
+		"gcd":   mathGcd,
+		"lcm":   mathLcm,
+		"clamp": mathClamp,
+		"ilog":  mathIlog,
+	})
+}
+
+// numberArgument 检查参数是数字，name是函数名
+func numberArgument(name string, arg object.Object) *object.Error {
+	if !isNumber(arg) {
+		return newError(object.TYPE_ERROR, "argument to `%s` must be a number, got %s", name, arg.Type())
+	}
+	return nil
+}
+
+// integerArgument 检查参数是整数并返回它的值
+func integerArgument(name string, arg object.Object) (*big.Int, *object.Error) {
+	if !isInteger(arg) {
+		return nil, newError(object.TYPE_ERROR, "argument to `%s` must be INTEGER, got %s", name, arg.Type())
+	}
+	return toBigInt(arg), nil
+}
+
+// integerResult 按照整数模式处理超出int64的结果。参数中已经有BigInt时结果总是任意精度的整数
+func integerResult(name string, value *big.Int, rt *object.Runtime, args []object.Object) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	for _, arg := range args {
+		if arg.Type() == object.BIGINT_OBJ {
+			return &object.BigInt{Value: value}
+		}
+	}
+	switch rt.IntegerMode {
+	case object.IntegerWrap:
+		return &object.Integer{Value: int64(new(big.Int).And(value, new(big.Int).SetUint64(math.MaxUint64)).Uint64())}
+	case object.IntegerChecked:
+		return newError(object.ARITHMETIC_ERROR, "integer overflow in `%s`", name)
+	default:
+		return &object.BigInt{Value: value}
+	}
+}
+
+func mathAbs(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return integerResult("abs", new(big.Int).Abs(toBigInt(arg)), rt, args)
+	case *object.Decimal:
+		return &object.Decimal{Value: new(big.Int).Abs(arg.Value), Scale: arg.Scale}
+	case *object.Rational:
+		return &object.Rational{Value: new(big.Rat).Abs(arg.Value)}
+	default:
+		return numberArgument("abs", arg)
+	}
+}
+
+// mathExtreme min和max，参数可以是几个数字或者一个数组。sign为-1时取最小值，为1时取最大值
+func mathExtreme(name string, sign int) object.BuiltinFunction {
+	return func(rt *object.Runtime, args ...object.Object) object.Object {
+		if len(args) == 1 {
+			if array, ok := args[0].(*object.Array); ok {
+				if len(array.Elements) == 0 {
+					return newError(object.ARGUMENT_ERROR, "`%s` of an empty array", name)
+				}
+				args = array.Elements
+			}
+		}
+		if len(args) == 0 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want at least 1")
+		}
+
+		result := args[0]
+		for _, arg := range args {
+			if err := numberArgument(name, arg); err != nil {
+				return err
+			}
+			if object.Compare(arg, result)*sign > 0 {
+				result = arg
+			}
+		}
+		return result
+	}
+}
+
+// mathPow pow(base, exp) 指数必须是整数。整数的负数次幂是精确的分数，小数的幂按照精度舍入
+func mathPow(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if err := numberArgument("pow", args[0]); err != nil {
+		return err
+	}
+	exp, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "exponent passed to `pow` must be INTEGER, got %s", args[1].Type())
+	}
+	n := exp.Value
+	if n < 0 {
+		if n == math.MinInt64 {
+			return newError(object.ARITHMETIC_ERROR, "exponent passed to `pow` is too large: %d", n)
+		}
+		n = -n
+	}
+
+	base := object.ToRat(args[0])
+	if base.Sign() == 0 && exp.Value < 0 {
+		return newError(object.ARITHMETIC_ERROR, "division by zero")
+	}
+	bits := int64(base.Num().BitLen() + base.Denom().BitLen())
+	if bits > 2 && n > maxPowBits/bits {
+		return newError(object.ARITHMETIC_ERROR, "result of `pow` is too large")
+	}
+
+	e := big.NewInt(n)
+	num := new(big.Int).Exp(base.Num(), e, nil)
+	den := new(big.Int).Exp(base.Denom(), e, nil)
+	if exp.Value < 0 {
+		num, den = den, num
+		if den.Sign() < 0 {
+			num.Neg(num)
+			den.Neg(den)
+		}
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Decimal:
+		if exp.Value >= 0 {
+			// 小数的各位数字没有约分，例如1.0d是10和1位小数，按照它们检查结果的大小
+			valueBits, scale := int64(arg.Value.BitLen()), int64(arg.Scale)
+			if (valueBits > 1 && n > maxPowBits/valueBits) || (scale > 0 && n > maxPowBits/scale) {
+				return newError(object.ARITHMETIC_ERROR, "result of `pow` is too large")
+			}
+			return roundDecimal(new(big.Int).Exp(arg.Value, e, nil), arg.Scale*int(n), rt)
+		}
+		return quotientToDecimal(num, den, 0, rt)
+	case *object.Rational:
+		return normalizeRational(new(big.Rat).SetFrac(num, den))
+	default:
+		if exp.Value < 0 {
+			return normalizeRational(new(big.Rat).SetFrac(num, den))
+		}
+		return integerResult("pow", num, rt, args[:1])
+	}
+}
+
+// mathSqrt 完全平方数的平方根是整数，其余的结果是按照精度舍入的小数
+func mathSqrt(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if err := numberArgument("sqrt", args[0]); err != nil {
+		return err
+	}
+	value := object.ToRat(args[0])
+	if value.Sign() < 0 {
+		return newError(object.ARITHMETIC_ERROR, "square root of negative number %s", args[0].Inspect())
+	}
+	if isInteger(args[0]) {
+		root := new(big.Int).Sqrt(value.Num())
+		if new(big.Int).Mul(root, root).Cmp(value.Num()) == 0 {
+			return normalizeBigInt(root)
+		}
+	}
+
+	// 在scale位小数上截断的平方根至少有精度加两位有效数字，再由roundDecimal舍入
+	num, den := value.Num(), value.Denom()
+	scale := rt.Precision() + 2
+	if zeros := (numDigits(den) - numDigits(num)) / 2; zeros > 0 {
+		scale += zeros + 1
+	}
+	square := new(big.Int).Mul(num, pow10(2*scale))
+	root := new(big.Int).Sqrt(new(big.Int).Quo(square, den))
+	exact := new(big.Int).Mul(new(big.Int).Mul(root, root), den).Cmp(square) == 0
+	if !exact {
+		// 截断的部分不为0，补上一位使舍入时能区分正好一半和超过一半
+		root.Mul(root, big.NewInt(10)).Add(root, big.NewInt(1))
+		scale++
+	}
+	result := roundDecimal(root, scale, rt)
+	if exact {
+		// 结果是精确的时去掉末尾的0，保留被开方数一半的小数位，例如sqrt(2.25d)得到1.5
+		ideal := 0
+		if decimal, ok := args[0].(*object.Decimal); ok {
+			ideal = (decimal.Scale + 1) / 2
+		}
+		ten := big.NewInt(10)
+		for result.Scale > ideal && new(big.Int).Rem(result.Value, ten).Sign() == 0 {
+			result.Value.Quo(result.Value, ten)
+			result.Scale--
+		}
+	}
+	return result
+}
+
+// mathRound floor和ceil把小数和分数舍入为整数
+func mathRound(name string, mode object.DecimalRounding) object.BuiltinFunction {
+	return func(rt *object.Runtime, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+		}
+		if err := numberArgument(name, args[0]); err != nil {
+			return err
+		}
+		if isInteger(args[0]) {
+			return args[0]
+		}
+		value := object.ToRat(args[0])
+		return integerResult(name, roundQuotient(value.Num(), value.Denom(), mode), rt, nil)
+	}
+}
+
+// integerArguments 检查至少有一个参数并且都是整数
+func integerArguments(name string, args []object.Object) ([]*big.Int, *object.Error) {
+	if len(args) == 0 {
+		return nil, newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want at least 1")
+	}
+	values := make([]*big.Int, len(args))
+	for i, arg := range args {
+		value, err := integerArgument(name, arg)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// mathGcd 最大公约数总是非负的，gcd(0, 0)为0
+func mathGcd(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := integerArguments("gcd", args)
+	if err != nil {
+		return err
+	}
+	result := new(big.Int)
+	for _, value := range values {
+		result.GCD(nil, nil, result, new(big.Int).Abs(value))
+	}
+	return integerResult("gcd", result, rt, args)
+}
+
+// mathLcm 最小公倍数总是非负的，有参数为0时结果为0
+func mathLcm(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := integerArguments("lcm", args)
+	if err != nil {
+		return err
+	}
+	result := big.NewInt(1)
+	for _, value := range values {
+		if value.Sign() == 0 {
+			return &object.Integer{Value: 0}
+		}
+		value = new(big.Int).Abs(value)
+		gcd := new(big.Int).GCD(nil, nil, result, value)
+		result.Mul(result, new(big.Int).Quo(value, gcd))
+	}
+	return integerResult("lcm", result, rt, args)
+}
+
+// mathClamp clamp(x, low, high) 把x限制在[low, high]之间
+func mathClamp(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=3", len(args))
+	}
+	for _, arg := range args {
+		if err := numberArgument("clamp", arg); err != nil {
+			return err
+		}
+	}
+	x, low, high := args[0], args[1], args[2]
+	if object.Compare(low, high) > 0 {
+		return newError(object.ARGUMENT_ERROR, "lower bound %s passed to `clamp` is greater than upper bound %s", low.Inspect(), high.Inspect())
+	}
+	if object.Compare(x, low) < 0 {
+		return low
+	}
+	if object.Compare(x, high) > 0 {
+		return high
+	}
+	return x
+}
+
+// mathIlog ilog(n, base) 整数对数，即满足base^k <= n的最大的k
+func mathIlog(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	n, err := integerArgument("ilog", args[0])
+	if err != nil {
+		return err
+	}
+	base, err := integerArgument("ilog", args[1])
+	if err != nil {
+		return err
+	}
+	if n.Sign() <= 0 {
+		return newError(object.ARITHMETIC_ERROR, "`ilog` is only defined for positive numbers, got %s", n)
+	}
+	if base.Cmp(big.NewInt(2)) < 0 {
+		return newError(object.ARGUMENT_ERROR, "base passed to `ilog` must be at least 2, got %s", base)
+	}
+
+	k := int64(0)
+	for rest := new(big.Int).Set(n); rest.Cmp(base) >= 0; k++ {
+		rest.Quo(rest, base)
+	}
+	return &object.Integer{Value: k}
+}
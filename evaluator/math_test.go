@@ -0,0 +1,140 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"testing"
+)
+
+const mathPrelude = `let {abs, min, max, pow, sqrt, floor, ceil, gcd, lcm, clamp, ilog} = import "math"; `
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		typ      object.ObjectType
+	}{
+		{"abs(-3)", "3", object.INTEGER_OBJ},
+		{"abs(-9223372036854775807 - 1)", "9223372036854775808", object.BIGINT_OBJ},
+		{"abs(-1.50d)", "1.50", object.DECIMAL_OBJ},
+		{"abs(-1 // 3)", "1/3", object.RATIONAL_OBJ},
+		{"min(3, 1.5d, 2)", "1.5", object.DECIMAL_OBJ},
+		{"max([1, 7, 3])", "7", object.INTEGER_OBJ},
+		{"max(1 // 2, 0.4d)", "1/2", object.RATIONAL_OBJ},
+		{"pow(2, 10)", "1024", object.INTEGER_OBJ},
+		{"pow(2, 64)", "18446744073709551616", object.BIGINT_OBJ},
+		{"pow(-2, 3)", "-8", object.INTEGER_OBJ},
+		{"pow(2, -2)", "1/4", object.RATIONAL_OBJ},
+		{"pow(-1, -1)", "-1", object.INTEGER_OBJ},
+		{"pow(1.5d, 2)", "2.25", object.DECIMAL_OBJ},
+		{"pow(1.0d, 3)", "1.000", object.DECIMAL_OBJ},
+		{"pow(2d, -1)", "0.5", object.DECIMAL_OBJ},
+		{"pow(2 // 3, 3)", "8/27", object.RATIONAL_OBJ},
+		{"sqrt(16)", "4", object.INTEGER_OBJ},
+		{"sqrt(2)", "1.414213562373095048801688724", object.DECIMAL_OBJ},
+		{"sqrt(2.25d)", "1.5", object.DECIMAL_OBJ},
+		{"sqrt(4.00d)", "2.0", object.DECIMAL_OBJ},
+		{"sqrt(0.0001d)", "0.01", object.DECIMAL_OBJ},
+		{"sqrt(1 // 4)", "0.5", object.DECIMAL_OBJ},
+		{"floor(2.5d)", "2", object.INTEGER_OBJ},
+		{"ceil(2.5d)", "3", object.INTEGER_OBJ},
+		{"floor(-2.5d)", "-3", object.INTEGER_OBJ},
+		{"ceil(-1 // 3)", "0", object.INTEGER_OBJ},
+		{"floor(7)", "7", object.INTEGER_OBJ},
+		{"gcd(12, 18)", "6", object.INTEGER_OBJ},
+		{"gcd(-4, 6, 10)", "2", object.INTEGER_OBJ},
+		{"gcd(0, 0)", "0", object.INTEGER_OBJ},
+		{"lcm(4, 6)", "12", object.INTEGER_OBJ},
+		{"lcm(0, 5)", "0", object.INTEGER_OBJ},
+		{"lcm(9223372036854775807, 2)", "18446744073709551614", object.BIGINT_OBJ},
+		{"clamp(5, 1, 3)", "3", object.INTEGER_OBJ},
+		{"clamp(-1, 0.5d, 10)", "0.5", object.DECIMAL_OBJ},
+		{"clamp(2, 1, 3)", "2", object.INTEGER_OBJ},
+		{"ilog(1000, 10)", "3", object.INTEGER_OBJ},
+		{"ilog(999, 10)", "2", object.INTEGER_OBJ},
+		{"ilog(1, 2)", "0", object.INTEGER_OBJ},
+		{"ilog(pow(2, 100), 2)", "100", object.INTEGER_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(mathPrelude + tt.input)
+		if evaluated.Type() != tt.typ || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.input, tt.typ, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathSqrtPrecision(t *testing.T) {
+	tests := []struct {
+		input    string
+		rounding object.DecimalRounding
+		expected string
+	}{
+		{"sqrt(2)", object.RoundHalfEven, "1.414"},
+		{"sqrt(2)", object.RoundUp, "1.415"},
+		{"sqrt(10)", object.RoundHalfEven, "3.162"},
+		{"sqrt(1 // 300)", object.RoundDown, "0.05773"},
+		{"pow(1.1d, 10)", object.RoundHalfEven, "2.594"},
+	}
+
+	for _, tt := range tests {
+		rt := &object.Runtime{DecimalPrecision: 4, DecimalRounding: tt.rounding}
+		evaluated := testEvalWithRuntime(mathPrelude+tt.input, rt)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathIntegerModes(t *testing.T) {
+	tests := []struct {
+		mode     object.IntegerMode
+		expected string
+	}{
+		{object.IntegerPromote, "18446744073709551616"},
+		{object.IntegerWrap, "0"},
+		{object.IntegerChecked, "ERROR: integer overflow in `pow` (at 1:88)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(mathPrelude+"pow(2, 64)", &object.Runtime{IntegerMode: tt.mode})
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("mode %d: expected %s, got %s", tt.mode, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{`abs("x")`, object.TYPE_ERROR, "argument to `abs` must be a number, got STRING"},
+		{"abs(1, 2)", object.ARGUMENT_ERROR, "wrong number of arguments. got=2, want=1"},
+		{"min()", object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want at least 1"},
+		{"max([])", object.ARGUMENT_ERROR, "`max` of an empty array"},
+		{"min(1, true)", object.TYPE_ERROR, "argument to `min` must be a number, got BOOLEAN"},
+		{"pow(2, 1.5d)", object.TYPE_ERROR, "exponent passed to `pow` must be INTEGER, got DECIMAL"},
+		{"pow(0, -1)", object.ARITHMETIC_ERROR, "division by zero"},
+		{"pow(2, 100000000)", object.ARITHMETIC_ERROR, "result of `pow` is too large"},
+		{"pow(1.0d, 100000000)", object.ARITHMETIC_ERROR, "result of `pow` is too large"},
+		{"pow(0.0d, 9223372036854775807)", object.ARITHMETIC_ERROR, "result of `pow` is too large"},
+		{"sqrt(-4)", object.ARITHMETIC_ERROR, "square root of negative number -4"},
+		{`floor("1.5")`, object.TYPE_ERROR, "argument to `floor` must be a number, got STRING"},
+		{"gcd(1, 2.5d)", object.TYPE_ERROR, "argument to `gcd` must be INTEGER, got DECIMAL"},
+		{"clamp(1, 5, 2)", object.ARGUMENT_ERROR, "lower bound 5 passed to `clamp` is greater than upper bound 2"},
+		{"ilog(0, 10)", object.ARITHMETIC_ERROR, "`ilog` is only defined for positive numbers, got 0"},
+		{"ilog(8, 1)", object.ARGUMENT_ERROR, "base passed to `ilog` must be at least 2, got 1"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(mathPrelude + tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"sort"
+)
+
+// nativeModules 用Go实现的模块。import "math"这样的路径直接得到它们导出的函数，不查找文件
+var nativeModules = map[string]*object.Hash{}
+
+// registerModule 把一组内置函数注册为原生模块，函数在调用栈中显示为module.name
+func registerModule(module string, functions map[string]object.BuiltinFunction) {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	exports := object.NewHash()
+	for _, name := range names {
+		exports.Set(&object.String{Value: name}, &object.Builtin{Name: module + "." + name, Fn: functions[name]})
+	}
+	nativeModules[module] = exports
+}
+
+// NativeModule 查找原生模块，加载模块时据此跳过不需要查找文件的import
+func NativeModule(name string) (*object.Hash, bool) {
+	module, ok := nativeModules[name]
+	return module, ok
+}
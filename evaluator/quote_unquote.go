@@ -0,0 +1,137 @@
+package evaluator
+
+import (
+	"Interp/ast"
+	"Interp/object"
+	"Interp/token"
+	"fmt"
+)
+
+// quote 返回携带语法树的Quote对象，树中的unquote调用会先求值，再把结果转换回语法树
+func quote(node ast.Node, env *object.Environment) object.Object {
+	// 先拷贝再改写，避免修改函数体等被多次求值的语法树
+	node, err := evalUnquoteCalls(ast.Copy(node), env)
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+		if pattern, ok := node.(*ast.UnquotePattern); ok {
+			return evalUnquotePattern(pattern, env, &err)
+		}
+		if !isUnquoteCall(node) {
+			return node
+		}
+		call, _ := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = newError(object.ARGUMENT_ERROR, "wrong number of arguments to `unquote`. got=%d, want=1", len(call.Arguments))
+			return node
+		}
+		unquoted := Eval(call.Arguments[0], env)
+		if isAbrupt(unquoted) {
+			err = asError(unquoted)
+			return node
+		}
+		converted, ok := convertObjectToASTNode(unquoted)
+		if !ok {
+			err = newError(object.TYPE_ERROR, "cannot unquote %s into the syntax tree", unquoted.Type())
+			return node
+		}
+		return converted
+	})
+	return node, err
+}
+
+// evalUnquotePattern 对绑定位置上的unquote求值，结果必须是携带标识符或解构模式的Quote
+func evalUnquotePattern(pattern *ast.UnquotePattern, env *object.Environment, err **object.Error) ast.Node {
+	unquoted := Eval(pattern.Argument, env)
+	if isAbrupt(unquoted) {
+		*err = asError(unquoted)
+		return pattern
+	}
+	if quote, ok := unquoted.(*object.Quote); ok {
+		if binding, ok := quote.Node.(ast.Pattern); ok {
+			return binding
+		}
+	}
+	*err = newError(object.TYPE_ERROR, "unquote in binding position must produce an identifier or pattern, got %s", unquoted.Inspect())
+	return pattern
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+// asError 将中断求值的对象转换为错误，?运算符产生的提前返回在quote中没有意义
+func asError(obj object.Object) *object.Error {
+	if err, ok := obj.(*object.Error); ok {
+		return err
+	}
+	return newError(object.TYPE_ERROR, "cannot return from inside `unquote`")
+}
+
+// convertObjectToASTNode 将unquote求值的结果转换回语法树节点，无法表示为字面量的对象返回false
+func convertObjectToASTNode(obj object.Object) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+	case *object.BigInt:
+		t := token.Token{Type: token.INT, Literal: obj.Value.String()}
+		return &ast.BigIntegerLiteral{Token: t, Value: obj.Value}, true
+	case *object.Decimal:
+		t := token.Token{Type: token.DECIMAL, Literal: obj.Inspect() + "d"}
+		return &ast.DecimalLiteral{Token: t, Value: obj.Value, Scale: obj.Scale}, true
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+		for _, element := range obj.Elements {
+			node, ok := convertObjectToASTNode(element)
+			if !ok {
+				return nil, false
+			}
+			array.Elements = append(array.Elements, node.(ast.Expression))
+		}
+		return array, true
+	case *object.Hash:
+		hash := &ast.HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Pairs: []*ast.HashPair{}}
+		for _, key := range obj.Keys {
+			pair := obj.Pairs[key]
+			k, ok := convertObjectToASTNode(pair.Key)
+			if !ok {
+				return nil, false
+			}
+			v, ok := convertObjectToASTNode(pair.Value)
+			if !ok {
+				return nil, false
+			}
+			hash.Pairs = append(hash.Pairs, &ast.HashPair{Key: k.(ast.Expression), Value: v.(ast.Expression)})
+		}
+		return hash, true
+	case *object.Quote:
+		return obj.Node, true
+	default:
+		return nil, false
+	}
+}
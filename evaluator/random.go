@@ -0,0 +1,131 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"math/big"
+	"math/rand"
+)
+
+// random模块。随机数来自rt.Random，同样的种子在两种执行方式下得到同样的结果
+func init() {
+	registerModule("random", map[string]object.BuiltinFunction{
+		"seed":    randomSeed,
+		"int":     randomInt,
+		"choice":  randomChoice,
+		"shuffle": randomShuffle,
+		"sample":  randomSample,
+	})
+}
+
+// randomSource 返回rt的随机数源。没有设置种子时随机地初始化，确定性模式下报错
+func randomSource(rt *object.Runtime, name string) (*rand.Rand, *object.Error) {
+	if rt.Random == nil {
+		if rt.Deterministic {
+			return nil, newError(object.NONDETERMINISM_ERROR, "`random.%s` needs a seeded random source in deterministic mode, call random.seed first", name)
+		}
+		rt.Random = rand.New(rand.NewSource(rand.Int63()))
+	}
+	return rt.Random, nil
+}
+
+// randomSeed seed(n) 用n重新初始化这次运行的随机数源，之后的随机数序列由n决定
+func randomSeed(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	seed, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `seed` must be INTEGER, got %s", args[0].Type())
+	}
+	rt.Random = rand.New(rand.NewSource(seed.Value))
+	return NULL
+}
+
+// randomInt int(low, high) [low, high]中均匀分布的整数，包括两端
+func randomInt(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	low, err := integerArgument("int", args[0])
+	if err != nil {
+		return err
+	}
+	high, err := integerArgument("int", args[1])
+	if err != nil {
+		return err
+	}
+	if low.Cmp(high) > 0 {
+		return newError(object.ARGUMENT_ERROR, "lower bound %s passed to `int` is greater than upper bound %s", low, high)
+	}
+	r, err := randomSource(rt, "int")
+	if err != nil {
+		return err
+	}
+	n := new(big.Int).Sub(high, low)
+	n.Add(n, big.NewInt(1))
+	return normalizeBigInt(n.Add(low, n.Rand(r, n)))
+}
+
+// randomChoice choice(arr) 随机选出数组中的一个元素
+func randomChoice(rt *object.Runtime, args ...object.Object) object.Object {
+	array, err := arrayArgument("choice", args)
+	if err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return newError(object.ARGUMENT_ERROR, "`choice` from an empty array")
+	}
+	r, err := randomSource(rt, "choice")
+	if err != nil {
+		return err
+	}
+	return array.Elements[r.Intn(len(array.Elements))]
+}
+
+// randomShuffle 返回打乱顺序的新数组，原来的数组不变
+func randomShuffle(rt *object.Runtime, args ...object.Object) object.Object {
+	array, err := arrayArgument("shuffle", args)
+	if err != nil {
+		return err
+	}
+	r, err := randomSource(rt, "shuffle")
+	if err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(array.Elements))
+	copy(elements, array.Elements)
+	r.Shuffle(len(elements), func(i, j int) {
+		elements[i], elements[j] = elements[j], elements[i]
+	})
+	return &object.Array{Elements: elements}
+}
+
+// randomSample sample(arr, k) 不放回地随机选出k个元素，按照被选出的顺序排列
+func randomSample(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	array, err := arrayArgument("sample", args[:1])
+	if err != nil {
+		return err
+	}
+	k, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "sample size passed to `sample` must be INTEGER, got %s", args[1].Type())
+	}
+	if k.Value < 0 || k.Value > int64(len(array.Elements)) {
+		return newError(object.ARGUMENT_ERROR, "sample size %d passed to `sample` is out of range for array of length %d", k.Value, len(array.Elements))
+	}
+	r, err := randomSource(rt, "sample")
+	if err != nil {
+		return err
+	}
+	// 部分Fisher-Yates洗牌，只打乱前k个位置
+	elements := make([]object.Object, len(array.Elements))
+	copy(elements, array.Elements)
+	for i := 0; i < int(k.Value); i++ {
+		j := i + r.Intn(len(elements)-i)
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	return &object.Array{Elements: elements[:k.Value]}
+}
@@ -0,0 +1,155 @@
+package evaluator
+
+import (
+	"Interp/lexer"
+	"Interp/object"
+	"Interp/parser"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+const randomPrelude = `let {seed, int, choice, shuffle, sample} = import "random"; `
+
+func TestRandomSeeded(t *testing.T) {
+	inputs := []string{
+		`[int(1, 6), int(1, 6), int(1, 6), int(-5, 5)]`,
+		`int(0, import "math"["pow"](10, 30))`,
+		`[choice(["a", "b", "c"]), choice([1, 2, 3])]`,
+		`shuffle([1, 2, 3, 4, 5, 6, 7, 8])`,
+		`sample(["α", "β", "γ", "δ", "ε"], 3)`,
+	}
+
+	for _, input := range inputs {
+		first := testEvalWithRuntime(randomPrelude+input, &object.Runtime{Random: rand.New(rand.NewSource(42))})
+		second := testEvalWithRuntime(randomPrelude+input, &object.Runtime{Random: rand.New(rand.NewSource(42))})
+		seeded := testEval(randomPrelude + "seed(42); " + input)
+		if first.Inspect() != second.Inspect() || first.Inspect() != seeded.Inspect() {
+			t.Errorf("%s: same seed gave different results: %s, %s, %s", input, first.Inspect(), second.Inspect(), seeded.Inspect())
+		}
+		if _, ok := first.(*object.Error); ok {
+			t.Errorf("%s: unexpected error %s", input, first.Inspect())
+		}
+	}
+}
+
+// 每个全局环境有自己的随机数源，一次运行中的seed不影响之后的运行
+func TestRandomSeedIsPerRun(t *testing.T) {
+	eval := func(input string) (*object.Environment, object.Object) {
+		env := object.NewEnvironment()
+		return env, Eval(parser.NewParser(lexer.NewLexer(randomPrelude+input)).ParseProgram(), env)
+	}
+
+	seeded, _ := eval("seed(7)")
+	if seeded.Runtime().Random == nil {
+		t.Fatal("seed did not set the random source of its run")
+	}
+	if later, _ := eval("1"); later.Runtime().Random != nil {
+		t.Error("seed in one run set the random source of a later run")
+	}
+
+	// 依次执行的两次运行从各自的种子开始，结果相同
+	input := "seed(7); [int(1, 1000000), int(1, 1000000)]"
+	_, first := eval(input)
+	_, second := eval(input)
+	if first.Inspect() != second.Inspect() {
+		t.Errorf("same seed gave different results in sequential runs: %s, %s", first.Inspect(), second.Inspect())
+	}
+}
+
+func TestRandomResults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 每个结果都满足的性质，对多个种子检查
+		{`let n = int(3, 5); [n > 2, n < 6]`, "[true, true]"},
+		{`int(7, 7)`, "7"},
+		{`let n = int(-9223372036854775807 - 1, 9223372036854775807); [n > -9223372036854775807 - 2, n < 9223372036854775807 + 1]`, "[true, true]"},
+		{`sort(shuffle([3, 1, 2, 5, 4]))`, "[1, 2, 3, 4, 5]"},
+		{`let a = [1, 2, 3]; shuffle(a); a`, "[1, 2, 3]"},
+		{`let s = sample([1, 2, 3, 4, 5], 5); sort(s)`, "[1, 2, 3, 4, 5]"},
+		{`len(sample([1, 2, 3, 4, 5], 2))`, "2"},
+		{`sample([1, 2], 0)`, "[]"},
+		{`shuffle([])`, "[]"},
+		{`let c = choice(["x", "y"]); if (c == "x") { true } else { c == "y" }`, "true"},
+	}
+
+	for _, tt := range tests {
+		for seed := int64(0); seed < 20; seed++ {
+			rt := &object.Runtime{Random: rand.New(rand.NewSource(seed))}
+			evaluated := testEvalWithRuntime(randomPrelude+tt.input, rt)
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("%s with seed %d: expected %s, got %s", tt.input, seed, tt.expected, evaluated.Inspect())
+				break
+			}
+		}
+	}
+}
+
+func TestRandomErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{`int(5, 1)`, object.ARGUMENT_ERROR, "lower bound 5 passed to `int` is greater than upper bound 1"},
+		{`int(1, 2.5d)`, object.TYPE_ERROR, "argument to `int` must be INTEGER, got DECIMAL"},
+		{`choice([])`, object.ARGUMENT_ERROR, "`choice` from an empty array"},
+		{`shuffle("abc")`, object.TYPE_ERROR, "argument to `shuffle` must be ARRAY, got STRING"},
+		{`sample([1, 2], 3)`, object.ARGUMENT_ERROR, "sample size 3 passed to `sample` is out of range for array of length 2"},
+		{`sample([1, 2], -1)`, object.ARGUMENT_ERROR, "sample size -1 passed to `sample` is out of range for array of length 2"},
+		{`seed("x")`, object.TYPE_ERROR, "argument to `seed` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(randomPrelude + tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestDeterministicMode(t *testing.T) {
+	clock := object.FixedClock{Time: time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		input    string
+		rt       *object.Runtime
+		expected string
+	}{
+		{`import "time"["now"]()`, &object.Runtime{Deterministic: true},
+			"ERROR: `time.now` cannot read the real clock in deterministic mode, the host must provide a fixed clock (at 1:21)"},
+		{`import "time"["now"]()`, &object.Runtime{Deterministic: true, Clock: clock}, "2024-06-01T12:00:00Z"},
+		{randomPrelude + `shuffle([1, 2, 3])`, &object.Runtime{Deterministic: true},
+			"ERROR: `random.shuffle` needs a seeded random source in deterministic mode, call random.seed first (at 1:68)"},
+		{randomPrelude + `try { choice([1]) } catch (e) { e["kind"] }`, &object.Runtime{Deterministic: true}, object.NONDETERMINISM_ERROR},
+		{randomPrelude + `seed(1); choice([1])`, &object.Runtime{Deterministic: true}, "1"},
+		{randomPrelude + `int(1, 1)`, &object.Runtime{Deterministic: true, Random: rand.New(rand.NewSource(1))}, "1"},
+		// 参数错误先于确定性检查报告
+		{randomPrelude + `choice([])`, &object.Runtime{Deterministic: true}, "ERROR: `choice` from an empty array (at 1:67)"},
+		// 执行外部程序、宿主的时区和按内存位置排序的内置函数同样不确定
+		{`exec("true")`, &object.Runtime{Deterministic: true, Exec: &object.ExecAccess{}},
+			"ERROR: `exec` cannot run programs in deterministic mode, their results depend on the host (at 1:5)"},
+		{`let {in_zone, from_millis} = import "time"; in_zone(from_millis(0), "Local")`, &object.Runtime{Deterministic: true},
+			"ERROR: `in_zone` cannot use the host's local time zone in deterministic mode (at 1:52)"},
+		{`let {in_zone, from_millis} = import "time"; in_zone(from_millis(0), "Asia/Tokyo")`, &object.Runtime{Deterministic: true}, "1970-01-01T09:00:00+09:00"},
+		{`try { sort([len, first]) } catch (e) { e["kind"] }`, &object.Runtime{Deterministic: true}, object.NONDETERMINISM_ERROR},
+		{`try { [len] < [first] } catch (e) { e["kind"] }`, &object.Runtime{Deterministic: true}, object.NONDETERMINISM_ERROR},
+		// 函数按源代码和捕获的变量排序，与内存中的位置无关
+		{`sort([fn() { 2 }, fn() { 1 }])`, &object.Runtime{Deterministic: true}, "[fn() { 1 }, fn() { 2 }]"},
+		{`let mk = fn(n) { fn() { n } }; sort([mk(2), mk(1)])[0]()`, &object.Runtime{Deterministic: true}, "1"},
+		{`let f = fn() { 1 }; [len(sort([f, 2, f, "a"])), [f, 1] < [f, 2]]`, &object.Runtime{Deterministic: true}, "[4, true]"},
+		{`len(sort([fn() { 1 }, fn() { 2 }]))`, &object.Runtime{}, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(tt.input, tt.rt)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
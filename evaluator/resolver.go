@@ -0,0 +1,284 @@
+package evaluator
+
+import (
+	"Interp/ast"
+	"fmt"
+)
+
+// Resolver 在求值之前把标识符解析为(Depth, Slot)，求值时直接按下标读写环境，不再逐层按名字查找。
+// 作用域与求值时的环境一一对应：全局、每次函数调用以及catch块各有一层，if等其他块不创建作用域。
+// 同一个Resolver可以依次解析多个程序，之前声明的全局变量仍然可见，用于REPL
+type Resolver struct {
+	global    *scope
+	scope     *scope
+	pending   []pendingFunction
+	limits    map[*scope]int // limits 正在解析的函数体定义时各层作用域中已经声明的变量个数，见pendingFunction
+	functions int            // 正在解析的函数体的层数，?只能在函数中使用
+	errors    []string
+	added     []string // 这次解析新声明的全局变量
+	globals   []*ast.Identifier
+}
+
+type scope struct {
+	names map[string]int
+	outer *scope
+}
+
+// pendingFunction 等待解析的函数体。函数体在外层的作用域声明完所有变量之后才解析，
+// 这样函数可以引用在它之后定义的变量，例如递归和相互调用的函数。
+// limits记录函数定义处各层作用域中已经声明的变量个数，用来判断引用的变量是不是在函数定义之后才声明的
+type pendingFunction struct {
+	fn     *ast.FunctionLiteral
+	scope  *scope
+	limits map[*scope]int
+}
+
+func NewResolver() *Resolver {
+	global := &scope{names: make(map[string]int)}
+	return &Resolver{global: global, scope: global}
+}
+
+// Resolve 解析node中的标识符，返回使用了未声明的变量等错误。
+// 未声明的变量保持按名字查找，程序仍然可以执行，运行到那里时报NameError。
+// 出错时撤销这次声明的全局变量，REPL中被拒绝的输入不会留下没有赋值的变量
+func (r *Resolver) Resolve(node ast.Node) []string {
+	r.errors = nil
+	r.added = nil
+	r.globals = nil
+	r.scope = r.global
+	r.resolve(node)
+	r.resolvePending()
+
+	if len(r.errors) != 0 {
+		for _, name := range r.added {
+			delete(r.global.names, name)
+		}
+	}
+	return r.errors
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if export, ok := s.(*ast.ExportStatement); ok {
+				r.resolve(export.Statement)
+				continue
+			}
+			r.resolve(s)
+		}
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, s := range node.Statements {
+			r.resolve(s)
+		}
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.LetStatement:
+		// 先解析值再声明，let x = x + 1 中右边的x是外层的变量
+		r.resolve(node.Value)
+		if node.Pattern != nil {
+			r.declarePattern(node.Pattern)
+		} else {
+			r.declare(node.Name)
+		}
+	case *ast.ExportStatement:
+		r.errors = append(r.errors, fmt.Sprintf("%s: export is only allowed at the top level of a module", node.Token.Position()))
+		r.resolve(node.Statement)
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+	case *ast.ThrowStatement:
+		r.resolve(node.Value)
+	case *ast.Identifier:
+		r.resolveIdentifier(node)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.PostfixExpression:
+		// 最外层的?遇到Err或None时会悄悄结束整个程序，要求写在函数中，由调用者处理返回的值
+		if node.Operator == "?" && r.functions == 0 {
+			r.errors = append(r.errors, fmt.Sprintf("%s: ? is only allowed inside a function", node.Token.Position()))
+		}
+		r.resolve(node.Left)
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		r.resolve(node.Alternative)
+	case *ast.FunctionLiteral:
+		r.pending = append(r.pending, pendingFunction{fn: node, scope: r.scope, limits: r.visible()})
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			for _, arg := range node.Arguments {
+				r.resolveQuoted(arg)
+			}
+			return
+		}
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			r.resolve(element)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.resolve(pair.Key)
+			r.resolve(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	case *ast.TryExpression:
+		r.resolve(node.Block)
+		if node.Catch != nil {
+			r.scope = &scope{names: make(map[string]int), outer: r.scope}
+			r.declare(node.CatchParam)
+			r.resolve(node.Catch)
+			r.scope = r.scope.outer
+		}
+		r.resolve(node.Finally)
+	}
+	// 宏字面量在求值时按名字查找，其余节点没有标识符
+}
+
+// GlobalIdentifiers 返回上一次解析中声明或引用全局变量的标识符，bundle据此重命名模块的全局变量
+func (r *Resolver) GlobalIdentifiers() []*ast.Identifier {
+	return r.globals
+}
+
+// resolveQuoted 被quote的代码不求值，只解析其中unquote的参数
+func (r *Resolver) resolveQuoted(node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			if isUnquoteCall(node) {
+				for _, arg := range node.Arguments {
+					r.resolve(arg)
+				}
+				return false
+			}
+		case *ast.UnquotePattern:
+			r.resolve(node.Argument)
+			return false
+		}
+		return true
+	})
+}
+
+func (r *Resolver) resolveFunction(fn *ast.FunctionLiteral) {
+	outerPending := r.pending
+	r.pending = nil
+	r.scope = &scope{names: make(map[string]int), outer: r.scope}
+	r.functions++
+
+	for _, param := range fn.Parameters {
+		r.declarePattern(param)
+	}
+	r.resolve(fn.Body)
+	r.resolvePending()
+
+	r.functions--
+	r.scope = r.scope.outer
+	r.pending = outerPending
+}
+
+// resolvePending 解析当前作用域中定义的函数，这时作用域中的变量都已经声明
+func (r *Resolver) resolvePending() {
+	for len(r.pending) > 0 {
+		pending := r.pending[0]
+		r.pending = r.pending[1:]
+
+		current, limits := r.scope, r.limits
+		r.scope, r.limits = pending.scope, pending.limits
+		r.resolveFunction(pending.fn)
+		r.scope, r.limits = current, limits
+	}
+}
+
+// visible 记录当前位置各层作用域中已经声明的变量个数。正在解析的函数体之外的作用域沿用函数定义处的个数
+func (r *Resolver) visible() map[*scope]int {
+	limits := make(map[*scope]int)
+	for s := r.scope; s != nil; s = s.outer {
+		if limit, ok := r.limits[s]; ok {
+			limits[s] = limit
+		} else {
+			limits[s] = len(s.names)
+		}
+	}
+	return limits
+}
+
+// resolveIdentifier 解析为声明这个名字的最内层作用域。变量在外层函数中、函数定义之后才声明时，
+// 再记录外层的同名变量，例如let x = 1; let f = fn() { let g = fn() { x }; g(); let x = 2; }中
+// 调用g时f的x还没有赋值，读取全局变量。都没有赋值时在运行时报NameError
+func (r *Resolver) resolveIdentifier(ident *ast.Identifier) {
+	found := false
+	ident.Fallbacks, ident.FallbackByName = nil, false
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if slot, ok := s.names[ident.Value]; ok {
+			if !found {
+				found = true
+				ident.Resolved, ident.Depth, ident.Slot = true, depth, slot
+				if s == r.global {
+					r.globals = append(r.globals, ident)
+				}
+			} else {
+				ident.Fallbacks = append(ident.Fallbacks, ast.Location{Depth: depth, Slot: slot})
+			}
+			if limit, limited := r.limits[s]; !limited || slot < limit || s == r.global {
+				return
+			}
+		}
+		depth++
+	}
+	if found {
+		ident.FallbackByName = true
+		return
+	}
+	// 内置函数按名字查找
+	if _, ok := builtins[ident.Value]; ok {
+		return
+	}
+	r.errors = append(r.errors, fmt.Sprintf("%s: undeclared variable %s", ident.Token.Position(), ident.Value))
+}
+
+// declare 在当前作用域中声明变量，重复声明的变量使用同一个位置
+func (r *Resolver) declare(ident *ast.Identifier) {
+	slot, ok := r.scope.names[ident.Value]
+	if !ok {
+		slot = len(r.scope.names)
+		r.scope.names[ident.Value] = slot
+		if r.scope == r.global {
+			r.added = append(r.added, ident.Value)
+		}
+	}
+	ident.Resolved, ident.Depth, ident.Slot = true, 0, slot
+	if r.scope == r.global {
+		r.globals = append(r.globals, ident)
+	}
+}
+
+func (r *Resolver) declarePattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		r.declare(pattern)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			r.declarePattern(element)
+		}
+		if pattern.Rest != nil {
+			r.declare(pattern.Rest)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			r.declarePattern(pair.Value)
+		}
+	}
+	// 绑定位置上的unquote在求值时报错
+}
@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"Interp/ast"
+	"Interp/object"
+	"reflect"
+	"testing"
+)
+
+func TestResolverBindings(t *testing.T) {
+	input := `let a = 1;
+let f = fn(x, [y, ...z]) {
+	let b = x + a;
+	try { b } catch (e) { fn() { e + b + a } }
+};`
+
+	program := testParseProgram(input)
+	if errors := NewResolver().Resolve(program); len(errors) != 0 {
+		t.Fatalf("unexpected resolver errors: %v", errors)
+	}
+
+	// 按在源代码中出现的顺序记录每个标识符的(Depth, Slot)
+	var got []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			got = append(got, describeBinding(ident))
+		}
+		return true
+	})
+
+	expected := []string{
+		"a@0:0", "f@0:1",
+		"x@0:0", "y@0:1", "z@0:2",
+		"b@0:3", "x@0:0", "a@1:0",
+		"b@0:3", "e@0:0",
+		"e@1:0", "b@2:3", "a@3:0",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong bindings.\nwant=%v\ngot =%v", expected, got)
+	}
+}
+
+func describeBinding(ident *ast.Identifier) string {
+	if !ident.Resolved {
+		return ident.Value + "@name"
+	}
+	return ident.Value + "@" + string(rune('0'+ident.Depth)) + ":" + string(rune('0'+ident.Slot))
+}
+
+func TestResolverErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + len([])", nil},
+		{"foobar", []string{"1:1: undeclared variable foobar"}},
+		{"x; let x = 1;", []string{"1:1: undeclared variable x"}},
+		{"let x = x + 1;", []string{"1:9: undeclared variable x"}},
+		// 函数体在外层的变量都声明之后才解析
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { even(n) };", nil},
+		{"let f = fn() { let g = fn() { h() }; let h = fn() { 1 }; g() };", nil},
+		{"let f = fn() { missing };", []string{"1:16: undeclared variable missing"}},
+		// catch的参数只在catch块中可见，if块不创建作用域
+		{"try { 1 } catch (e) { e }; e", []string{"1:28: undeclared variable e"}},
+		{"if (true) { let y = 1 }; y", nil},
+		{"let {a, b: [b, ...c]} = p; [a, b, c]", []string{"1:25: undeclared variable p"}},
+		// quote中只有unquote的参数会被求值
+		{"quote(foo + unquote(1 + bar))", []string{"1:25: undeclared variable bar"}},
+		{"fn(a) { a }(b); c", []string{"1:13: undeclared variable b", "1:17: undeclared variable c"}},
+		// 只有模块最外层的let可以导出
+		{"export let x = 1; x", nil},
+		{"let f = fn() { export let y = 1; y };", []string{"1:16: export is only allowed at the top level of a module"}},
+		// 最外层的?会悄悄结束程序，只能在函数中使用
+		{"let v = Err(1)?; puts(v)", []string{"1:15: ? is only allowed inside a function"}},
+		{"try { Some(1)? } catch (e) { e }", []string{"1:14: ? is only allowed inside a function"}},
+		{"let f = fn(r) { let g = fn() { r? }; g()? };", nil},
+	}
+
+	for _, tt := range tests {
+		errors := NewResolver().Resolve(testParseProgram(tt.input))
+		if !reflect.DeepEqual(errors, tt.expected) {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot =%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestResolverKeepsGlobalsAcrossPrograms(t *testing.T) {
+	resolver := NewResolver()
+	env := object.NewEnvironment()
+
+	steps := []struct {
+		input    string
+		errors   int
+		expected interface{}
+	}{
+		{"let a = 2;", 0, nil},
+		{"let f = fn(n) { n * a };", 0, nil},
+		// 被拒绝的程序中声明的变量会被撤销
+		{"let b = 1; missing", 1, nil},
+		{"b", 1, nil},
+		{"let b = 3; f(b)", 0, 6},
+	}
+
+	for _, step := range steps {
+		program := testParseProgram(step.input)
+		errors := resolver.Resolve(program)
+		if len(errors) != step.errors {
+			t.Fatalf("wrong number of errors for %q. got=%v", step.input, errors)
+		}
+		if len(errors) != 0 {
+			continue
+		}
+		evaluated := Eval(program, env)
+		if expected, ok := step.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		}
+	}
+}
+
+func TestResolvedEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; let f = fn() { let x = x + 1; x }; [f(), x]", []int64{2, 1}},
+		{"let f = fn() { let g = fn(n) { if (n == 0) { 0 } else { h(n - 1) } }; let h = fn(n) { g(n) }; g(5) }; f()", 0},
+		{"let counter = fn() { let n = 10; fn(d) { n + d } }; counter()(5)", 15},
+		{"let f = fn() { try { throw 1 } catch (e) { let v = e[\"value\"]; fn() { v + 1 } } }; f()()", 2},
+		{"let [a, [b, ...c]] = [1, [2, 3, 4]]; a + b + len(c)", 5},
+		{"let len = fn(x) { 42 }; len([])", 42},
+		{"let f = fn() { g() }; f()", "identifier not found: g"},
+		// 已经声明但是let还没有执行
+		{"let f = fn() { later }; let r = try { f() } catch (e) { e[\"message\"] }; let later = 1; r", "identifier not found: later"},
+		// 闭包中的名字解析为声明它的最内层作用域，那里的let在函数定义之后、还没有执行时读取外层的同名变量
+		{"let x = 1; let f = fn() { let g = fn() { x }; let r = try { g() } catch (e) { e[\"message\"] }; let x = 2; r }; f()", 1},
+		{"let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f()", 2},
+		{"let x = 1; let f = fn() { let g = fn() { fn() { x } }; let a = g()(); let x = 2; [a, g()(), x] }; f()", []int64{1, 2, 2}},
+		{"let f = fn(x) { fn() { let g = fn() { x }; let a = g(); let x = a + 1; [a, g()] } }; f(1)()", []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok || len(array.Elements) != len(expected) {
+				t.Errorf("wrong result for %q. got=%s", tt.input, evaluated.Inspect())
+				continue
+			}
+			for i, value := range expected {
+				testIntegerObject(t, array.Elements[i], value)
+			}
+		case string:
+			var message string
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				message = obj.Message
+			case *object.String:
+				message = obj.Value
+			}
+			if message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
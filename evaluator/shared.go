@@ -0,0 +1,46 @@
+package evaluator
+
+import "Interp/object"
+
+// 以下函数供字节码虚拟机使用，两种执行方式共用同一套运算规则和内置函数
+
+// EvalInfix 按照rt的设置计算二元运算left operator right
+func EvalInfix(rt *object.Runtime, operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right, rt)
+}
+
+// EvalPrefix 按照rt的设置计算一元运算operator right
+func EvalPrefix(rt *object.Runtime, operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right, rt)
+}
+
+// EvalIndex 计算索引表达式left[index]
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// Import 查找已经加载的模块导出的值，path是源代码中写的路径，resolved是解析出的绝对路径
+func Import(rt *object.Runtime, path, resolved string) object.Object {
+	return evalImport(path, resolved, rt)
+}
+
+// IsTruthy 按照rt的规则判断条件是否为真，严格模式下条件不是布尔值时返回错误
+func IsTruthy(rt *object.Runtime, obj object.Object) (bool, *object.Error) {
+	return isTruthy(obj, rt)
+}
+
+// Throw 将throw的值转换为传播中的错误
+func Throw(val object.Object) *object.Error {
+	return throwValue(val)
+}
+
+// Catch 将传播中的错误转换为catch可以查看的值
+func Catch(err *object.Error) *object.ErrorValue {
+	return catchError(err)
+}
+
+// LookupBuiltin 按名字查找内置函数
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
@@ -0,0 +1,233 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxStringLength repeat的结果最多的字节数
+const maxStringLength = 1 << 26
+
+// strings模块按照Unicode字符计算长度和下标，内置的len返回的是字节数
+func init() {
+	registerModule("strings", map[string]object.BuiltinFunction{
+		"split":           stringsSplit,
+		"join":            stringsJoin,
+		"trim":            stringsTrim,
+		"contains":        stringsPredicate("contains", strings.Contains),
+		"starts_with":     stringsPredicate("starts_with", strings.HasPrefix),
+		"ends_with":       stringsPredicate("ends_with", strings.HasSuffix),
+		"index_of":        stringsIndexOf,
+		"replace":         stringsReplace,
+		"upper":           stringsMap("upper", strings.ToUpper),
+		"lower":           stringsMap("lower", strings.ToLower),
+		"repeat":          stringsRepeat,
+		"len":             stringsLen,
+		"substring":       stringsSubstring,
+		"char_codes":      stringsCharCodes,
+		"from_char_codes": stringsFromCharCodes,
+	})
+}
+
+// stringArguments 检查参数的数量，并且前n个参数都是字符串
+func stringArguments(name string, args []object.Object, n int) ([]string, *object.Error) {
+	if len(args) != n {
+		return nil, newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	values := make([]string, n)
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+// stringsSplit split(s, sep) 分隔符为空时拆分为单个字符
+func stringsSplit(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("split", args, 2)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(values[0], values[1])
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// stringsJoin join(arr, sep) 数组的元素必须都是字符串
+func stringsJoin(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "first argument to `join` must be ARRAY, got %s", args[0].Type())
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "separator passed to `join` must be STRING, got %s", args[1].Type())
+	}
+	parts := make([]string, len(array.Elements))
+	for i, element := range array.Elements {
+		str, ok := element.(*object.String)
+		if !ok {
+			return newError(object.TYPE_ERROR, "elements passed to `join` must be STRING, got %s at index %d", element.Type(), i)
+		}
+		parts[i] = str.Value
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+// stringsTrim trim(s) 去掉两端的空白字符，trim(s, chars) 去掉两端出现在chars中的字符
+func stringsTrim(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) == 2 {
+		values, err := stringArguments("trim", args, 2)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: strings.Trim(values[0], values[1])}
+	}
+	values, err := stringArguments("trim", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.TrimFunc(values[0], unicode.IsSpace)}
+}
+
+func stringsPredicate(name string, predicate func(s, sub string) bool) object.BuiltinFunction {
+	return func(rt *object.Runtime, args ...object.Object) object.Object {
+		values, err := stringArguments(name, args, 2)
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(predicate(values[0], values[1]))
+	}
+}
+
+func stringsMap(name string, mapping func(s string) string) object.BuiltinFunction {
+	return func(rt *object.Runtime, args ...object.Object) object.Object {
+		values, err := stringArguments(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: mapping(values[0])}
+	}
+}
+
+// stringsIndexOf index_of(s, sub) 返回sub第一次出现的字符下标，没有出现时返回-1
+func stringsIndexOf(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("index_of", args, 2)
+	if err != nil {
+		return err
+	}
+	index := strings.Index(values[0], values[1])
+	if index < 0 {
+		return &object.Integer{Value: -1}
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(values[0][:index]))}
+}
+
+// stringsReplace replace(s, old, new) 替换所有出现的old
+func stringsReplace(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("replace", args, 3)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+}
+
+// stringsRepeat repeat(s, n) n不能为负数
+func stringsRepeat(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `repeat` must be STRING, got %s", args[0].Type())
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "count passed to `repeat` must be INTEGER, got %s", args[1].Type())
+	}
+	if count.Value < 0 {
+		return newError(object.ARGUMENT_ERROR, "count passed to `repeat` must not be negative, got %d", count.Value)
+	}
+	if len(str.Value) > 0 && count.Value > maxStringLength/int64(len(str.Value)) {
+		return newError(object.ARGUMENT_ERROR, "result of `repeat` is too large")
+	}
+	return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+}
+
+// stringsLen 字符数而不是字节数
+func stringsLen(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("len", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(values[0]))}
+}
+
+// stringsSubstring substring(s, start, end) 按照字符下标截取[start, end)，省略end时截取到末尾
+func stringsSubstring(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `substring` must be STRING, got %s", args[0].Type())
+	}
+	runes := []rune(str.Value)
+	bounds := []int64{0, int64(len(runes))}
+	for i, arg := range args[1:] {
+		index, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "index passed to `substring` must be INTEGER, got %s", arg.Type())
+		}
+		bounds[i] = index.Value
+	}
+	start, end := bounds[0], bounds[1]
+	if start < 0 || end > int64(len(runes)) || start > end {
+		return newError(object.ARGUMENT_ERROR, "substring [%d, %d) out of range for string of length %d", start, end, len(runes))
+	}
+	return &object.String{Value: string(runes[start:end])}
+}
+
+// stringsCharCodes 每个字符的Unicode码点
+func stringsCharCodes(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("char_codes", args, 1)
+	if err != nil {
+		return err
+	}
+	var codes []object.Object
+	for _, r := range values[0] {
+		codes = append(codes, &object.Integer{Value: int64(r)})
+	}
+	return &object.Array{Elements: codes}
+}
+
+// stringsFromCharCodes 由Unicode码点组成字符串，是char_codes的逆运算
+func stringsFromCharCodes(rt *object.Runtime, args ...object.Object) object.Object {
+	array, err := arrayArgument("from_char_codes", args)
+	if err != nil {
+		return err
+	}
+	var out strings.Builder
+	for _, element := range array.Elements {
+		code, ok := element.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "char codes passed to `from_char_codes` must be INTEGER, got %s", element.Type())
+		}
+		if code.Value < 0 || code.Value > unicode.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+			return newError(object.ARGUMENT_ERROR, "invalid char code %d", code.Value)
+		}
+		out.WriteRune(rune(code.Value))
+	}
+	return &object.String{Value: out.String()}
+}
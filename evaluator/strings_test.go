@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"testing"
+)
+
+const stringsPrelude = `let {split, join, trim, contains, index_of, replace, upper, lower, starts_with, ends_with, repeat, len: count, substring, char_codes, from_char_codes} = import "strings"; `
+
+func TestStringsModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,,c", ",")`, `[a, b, , c]`},
+		{`split("日本語", "")`, `[日, 本, 語]`},
+		{`split("😀-😁", "-")`, `[😀, 😁]`},
+		{`join(["α", "β", "γ"], "·")`, `α·β·γ`},
+		{`join([], ", ")`, ``},
+		{`trim("\t  héllo \n")`, `héllo`},
+		{`trim("　全角　")`, `全角`},
+		{`trim("--ü--", "-")`, `ü`},
+		{`contains("naïve café", "é")`, `true`},
+		{`contains("abc", "d")`, `false`},
+		{`index_of("héllo wörld", "wö")`, `6`},
+		{`index_of("日本語テキスト", "テ")`, `3`},
+		{`index_of("abc", "z")`, `-1`},
+		{`replace("ñaña", "ñ", "n")`, `nana`},
+		{`upper("grüße ǆ")`, `GRÜßE Ǆ`},
+		{`lower("ΑΒΓ ÉCOLE")`, `αβγ école`},
+		{`starts_with("über", "ü")`, `true`},
+		{`ends_with("日本語", "本")`, `false`},
+		{`repeat("ab€", 3)`, `ab€ab€ab€`},
+		{`repeat("x", 0)`, ``},
+		{`count("héllo, 世界")`, `9`},
+		{`count("👍🏽")`, `2`},
+		{`count("")`, `0`},
+		{`substring("héllo, 世界", 7)`, `世界`},
+		{`substring("héllo", 1, 3)`, `él`},
+		{`substring("日本語", 3, 3)`, ``},
+		{`char_codes("aé世😀")`, `[97, 233, 19990, 128512]`},
+		{`from_char_codes([72, 233, 19990, 128512])`, `Hé世😀`},
+		{`from_char_codes(char_codes("ünïcödé"))`, `ünïcödé`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(stringsPrelude + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringsErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{`split("a", 1)`, object.TYPE_ERROR, "argument to `split` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, object.ARGUMENT_ERROR, "wrong number of arguments. got=2, want=1"},
+		{`join("abc", "")`, object.TYPE_ERROR, "first argument to `join` must be ARRAY, got STRING"},
+		{`join(["a", 1], "")`, object.TYPE_ERROR, "elements passed to `join` must be STRING, got INTEGER at index 1"},
+		{`repeat("a", -1)`, object.ARGUMENT_ERROR, "count passed to `repeat` must not be negative, got -1"},
+		{`repeat("ab", 9223372036854775807)`, object.ARGUMENT_ERROR, "result of `repeat` is too large"},
+		{`substring("日本語", 2, 4)`, object.ARGUMENT_ERROR, "substring [2, 4) out of range for string of length 3"},
+		{`substring("日本語", 2, 1)`, object.ARGUMENT_ERROR, "substring [2, 1) out of range for string of length 3"},
+		{`substring("abc", "1")`, object.TYPE_ERROR, "index passed to `substring` must be INTEGER, got STRING"},
+		{`from_char_codes([55296])`, object.ARGUMENT_ERROR, "invalid char code 55296"},
+		{`from_char_codes([1114112])`, object.ARGUMENT_ERROR, "invalid char code 1114112"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(stringsPrelude + tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
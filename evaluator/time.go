@@ -0,0 +1,303 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"math"
+	"time"
+	// 内嵌时区数据库，没有安装时区数据的系统上in_zone的结果也相同
+	_ "time/tzdata"
+)
+
+// time模块。时间点是TIME值，时长是以毫秒为单位的整数。now读取rt的时钟，宿主可以把它换成固定的时钟
+func init() {
+	registerModule("time", map[string]object.BuiltinFunction{
+		"now":             timeNow,
+		"parse":           timeParse,
+		"format":          timeFormat,
+		"add":             timeAdd,
+		"add_date":        timeAddDate,
+		"diff":            timeDiff,
+		"in_zone":         timeInZone,
+		"fields":          timeFields,
+		"from_millis":     timeFromMillis,
+		"to_millis":       timeToMillis,
+		"duration":        timeDuration,
+		"format_duration": timeFormatDuration,
+	})
+}
+
+// timeLayouts 可以按名字使用的格式，其他格式使用Go的参考时间写法，例如"2006-01-02 15:04"
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// maxDurationMillis time.Duration能表示的最长的毫秒数
+const maxDurationMillis = math.MaxInt64 / int64(time.Millisecond)
+
+func timeArgument(name string, arg object.Object) (time.Time, *object.Error) {
+	t, ok := arg.(*object.Time)
+	if !ok {
+		return time.Time{}, newError(object.TYPE_ERROR, "argument to `%s` must be TIME, got %s", name, arg.Type())
+	}
+	return t.Value, nil
+}
+
+// millisArgument 以毫秒为单位的时长，必须能用time.Duration表示
+func millisArgument(name string, arg object.Object) (time.Duration, *object.Error) {
+	ms, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TYPE_ERROR, "duration passed to `%s` must be INTEGER milliseconds, got %s", name, arg.Type())
+	}
+	if ms.Value > maxDurationMillis || ms.Value < -maxDurationMillis {
+		return 0, newError(object.ARGUMENT_ERROR, "duration passed to `%s` is out of range: %d", name, ms.Value)
+	}
+	return time.Duration(ms.Value) * time.Millisecond, nil
+}
+
+func layoutArgument(name string, arg object.Object) (string, *object.Error) {
+	layout, ok := arg.(*object.String)
+	if !ok {
+		return "", newError(object.TYPE_ERROR, "layout passed to `%s` must be STRING, got %s", name, arg.Type())
+	}
+	if named, ok := timeLayouts[layout.Value]; ok {
+		return named, nil
+	}
+	return layout.Value, nil
+}
+
+// zoneArgument 按名字查找时区。"Local"是宿主的时区，确定性模式下不能使用
+func zoneArgument(rt *object.Runtime, name string, arg object.Object) (*time.Location, *object.Error) {
+	zone, ok := arg.(*object.String)
+	if !ok {
+		return nil, newError(object.TYPE_ERROR, "time zone passed to `%s` must be STRING, got %s", name, arg.Type())
+	}
+	if rt.Deterministic && zone.Value == "Local" {
+		return nil, newError(object.NONDETERMINISM_ERROR, "`%s` cannot use the host's local time zone in deterministic mode", name)
+	}
+	loc, err := time.LoadLocation(zone.Value)
+	if err != nil {
+		return nil, newError(object.ARGUMENT_ERROR, "unknown time zone %q", zone.Value)
+	}
+	return loc, nil
+}
+
+// timeNow 确定性模式下只能读取宿主提供的时钟
+func timeNow(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=0", len(args))
+	}
+	if rt.Deterministic && rt.Clock == nil {
+		return newError(object.NONDETERMINISM_ERROR, "`time.now` cannot read the real clock in deterministic mode, the host must provide a fixed clock")
+	}
+	return &object.Time{Value: rt.Now()}
+}
+
+// timeParse parse(text, layout, zone) 文本中没有时区时按照zone解释，省略zone时为UTC
+func timeParse(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	text, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `parse` must be STRING, got %s", args[0].Type())
+	}
+	layout, err := layoutArgument("parse", args[1])
+	if err != nil {
+		return err
+	}
+	loc := time.UTC
+	if len(args) == 3 {
+		if loc, err = zoneArgument(rt, "parse", args[2]); err != nil {
+			return err
+		}
+	}
+	t, parseErr := time.ParseInLocation(layout, text.Value, loc)
+	if parseErr != nil {
+		return newError(object.ARGUMENT_ERROR, "cannot parse %q as time: %s", text.Value, parseErr)
+	}
+	return &object.Time{Value: t}
+}
+
+func timeFormat(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	t, err := timeArgument("format", args[0])
+	if err != nil {
+		return err
+	}
+	layout, err := layoutArgument("format", args[1])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: t.Format(layout)}
+}
+
+// timeAdd add(t, ms) 加上一段时长，ms可以为负数
+func timeAdd(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	t, err := timeArgument("add", args[0])
+	if err != nil {
+		return err
+	}
+	d, err := millisArgument("add", args[1])
+	if err != nil {
+		return err
+	}
+	return &object.Time{Value: t.Add(d)}
+}
+
+// timeAddDate add_date(t, years, months, days) 按照日历加减，在t的时区中计算，例如1月31日加一个月得到3月2日或3日
+func timeAddDate(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 4 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=4", len(args))
+	}
+	t, err := timeArgument("add_date", args[0])
+	if err != nil {
+		return err
+	}
+	var parts [3]int
+	for i, arg := range args[1:] {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument to `add_date` must be INTEGER, got %s", arg.Type())
+		}
+		if n.Value > math.MaxInt32 || n.Value < math.MinInt32 {
+			return newError(object.ARGUMENT_ERROR, "argument to `add_date` is out of range: %d", n.Value)
+		}
+		parts[i] = int(n.Value)
+	}
+	return &object.Time{Value: t.AddDate(parts[0], parts[1], parts[2])}
+}
+
+// timeDiff diff(a, b) a减去b的毫秒数，不足一毫秒的部分向零截断
+func timeDiff(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	a, err := timeArgument("diff", args[0])
+	if err != nil {
+		return err
+	}
+	b, err := timeArgument("diff", args[1])
+	if err != nil {
+		return err
+	}
+	// Sub在超出time.Duration的范围时饱和，这里按照Unix时间精确计算
+	ms := a.UnixMilli() - b.UnixMilli()
+	if sub := a.Sub(b); sub > -math.MaxInt64 && sub < math.MaxInt64 {
+		ms = sub.Milliseconds()
+	}
+	return &object.Integer{Value: ms}
+}
+
+// timeInZone 同一个时间点在另一个时区中的表示
+func timeInZone(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	t, err := timeArgument("in_zone", args[0])
+	if err != nil {
+		return err
+	}
+	loc, err := zoneArgument(rt, "in_zone", args[1])
+	if err != nil {
+		return err
+	}
+	return &object.Time{Value: t.In(loc)}
+}
+
+// timeFields 在t的时区中的年月日等各个部分，offset是与UTC相差的秒数
+func timeFields(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	t, err := timeArgument("fields", args[0])
+	if err != nil {
+		return err
+	}
+	zone, offset := t.Zone()
+	fields := object.NewHash()
+	set := func(key string, value object.Object) {
+		fields.Set(&object.String{Value: key}, value)
+	}
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"year", t.Year()}, {"month", int(t.Month())}, {"day", t.Day()},
+		{"hour", t.Hour()}, {"minute", t.Minute()}, {"second", t.Second()}, {"nanosecond", t.Nanosecond()},
+	} {
+		set(field.name, &object.Integer{Value: int64(field.value)})
+	}
+	set("weekday", &object.String{Value: t.Weekday().String()})
+	set("zone", &object.String{Value: zone})
+	set("offset", &object.Integer{Value: int64(offset)})
+	return fields
+}
+
+// timeFromMillis from_millis(ms, zone) Unix时间的毫秒数对应的时间点，省略zone时为UTC
+func timeFromMillis(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	ms, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `from_millis` must be INTEGER, got %s", args[0].Type())
+	}
+	loc := time.UTC
+	if len(args) == 2 {
+		var err *object.Error
+		if loc, err = zoneArgument(rt, "from_millis", args[1]); err != nil {
+			return err
+		}
+	}
+	return &object.Time{Value: time.UnixMilli(ms.Value).In(loc)}
+}
+
+func timeToMillis(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	t, err := timeArgument("to_millis", args[0])
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: t.UnixMilli()}
+}
+
+// timeDuration duration("1h30m") 把Go格式的时长转换为毫秒数，不足一毫秒的部分向零截断
+func timeDuration(rt *object.Runtime, args ...object.Object) object.Object {
+	values, err := stringArguments("duration", args, 1)
+	if err != nil {
+		return err
+	}
+	d, parseErr := time.ParseDuration(values[0])
+	if parseErr != nil {
+		return newError(object.ARGUMENT_ERROR, "invalid duration %q", values[0])
+	}
+	return &object.Integer{Value: d.Milliseconds()}
+}
+
+// timeFormatDuration format_duration(ms) 例如5400000得到"1h30m0s"
+func timeFormatDuration(rt *object.Runtime, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	d, err := millisArgument("format_duration", args[0])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: d.String()}
+}
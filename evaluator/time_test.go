@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"Interp/object"
+	"testing"
+	"time"
+)
+
+const timePrelude = `let {now, parse, format, add, add_date, diff, in_zone, fields, from_millis, to_millis, duration, format_duration} = import "time"; `
+
+var fixedNow = time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+func TestTimeModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`now()`, "2024-06-01T12:00:00Z"},
+		{`now() == now()`, "true"},
+		{`parse("2024-01-02T15:04:05.5+08:00", "RFC3339")`, "2024-01-02T15:04:05.5+08:00"},
+		{`parse("02/01/2024", "02/01/2006")`, "2024-01-02T00:00:00Z"},
+		{`parse("2024-03-10 01:30", "2006-01-02 15:04", "America/New_York")`, "2024-03-10T01:30:00-05:00"},
+		{`format(now(), "2006年01月02日 15:04")`, "2024年06月01日 12:00"},
+		{`format(parse("2024-01-02", "DateOnly"), "RFC1123")`, "Tue, 02 Jan 2024 00:00:00 UTC"},
+		{`add(now(), duration("1h30m"))`, "2024-06-01T13:30:00Z"},
+		{`add(now(), -1)`, "2024-06-01T11:59:59.999Z"},
+		{`add_date(parse("2024-01-31", "DateOnly"), 0, 1, 0)`, "2024-03-02T00:00:00Z"},
+		{`add_date(now(), -1, 0, 1)`, "2023-06-02T12:00:00Z"},
+		{`diff(now(), parse("2024-06-01", "DateOnly"))`, "43200000"},
+		{`diff(parse("0001-01-01", "DateOnly"), parse("9999-01-01", "DateOnly"))`, "-315506361600000"},
+		{`in_zone(now(), "Asia/Shanghai")`, "2024-06-01T20:00:00+08:00"},
+		{`in_zone(now(), "Asia/Shanghai") == now()`, "true"},
+		{`parse("2024-01-01", "DateOnly") < now()`, "true"},
+		// 夏令时开始的那天在纽约只有23个小时
+		{`let d = parse("2024-03-10", "DateOnly", "America/New_York"); diff(add_date(d, 0, 0, 1), d)`, "82800000"},
+		{`fields(in_zone(now(), "America/New_York"))`, "{year: 2024, month: 6, day: 1, hour: 8, minute: 0, second: 0, nanosecond: 0, weekday: Saturday, zone: EDT, offset: -14400}"},
+		{`from_millis(1700000000123)`, "2023-11-14T22:13:20.123Z"},
+		{`from_millis(0, "Asia/Tokyo")`, "1970-01-01T09:00:00+09:00"},
+		{`to_millis(now())`, "1717243200000"},
+		{`duration("1.5s")`, "1500"},
+		{`duration("-2m")`, "-120000"},
+		{`format_duration(5400000)`, "1h30m0s"},
+		{`format_duration(1)`, "1ms"},
+	}
+
+	for _, tt := range tests {
+		rt := &object.Runtime{Clock: object.FixedClock{Time: fixedNow}}
+		evaluated := testEvalWithRuntime(timePrelude+tt.input, rt)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTimeSystemClock(t *testing.T) {
+	before := time.Now()
+	evaluated := testEval(timePrelude + "now()")
+	after := time.Now()
+
+	now, ok := evaluated.(*object.Time)
+	if !ok {
+		t.Fatalf("expected TIME, got %s %s", evaluated.Type(), evaluated.Inspect())
+	}
+	if now.Value.Before(before) || now.Value.After(after) {
+		t.Errorf("now() returned %s, not between %s and %s", now.Inspect(), before, after)
+	}
+}
+
+func TestTimeErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{`format("2024", "DateOnly")`, object.TYPE_ERROR, "argument to `format` must be TIME, got STRING"},
+		{`in_zone(now(), "Mars/Olympus")`, object.ARGUMENT_ERROR, "unknown time zone \"Mars/Olympus\""},
+		{`add(now(), 1.5d)`, object.TYPE_ERROR, "duration passed to `add` must be INTEGER milliseconds, got DECIMAL"},
+		{`add(now(), 9223372036854775)`, object.ARGUMENT_ERROR, "duration passed to `add` is out of range: 9223372036854775"},
+		{`parse("2024-01-02 15:04", "DateTime")`, object.ARGUMENT_ERROR,
+			`cannot parse "2024-01-02 15:04" as time: parsing time "2024-01-02 15:04" as "2006-01-02 15:04:05": cannot parse "" as ":"`},
+		{`duration("soon")`, object.ARGUMENT_ERROR, "invalid duration \"soon\""},
+		{`parse("2024", 1)`, object.TYPE_ERROR, "layout passed to `parse` must be STRING, got INTEGER"},
+		{`now(1)`, object.ARGUMENT_ERROR, "wrong number of arguments. got=1, want=0"},
+		{`now() < 1`, object.TYPE_ERROR, "type mismatch: TIME < INTEGER"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(timePrelude + tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("%s: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
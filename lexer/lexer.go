@@ -7,17 +7,24 @@ type Lexer struct {
 	position     int  //当前字符的位置
 	readPosition int  //当前读取字符的位置，即当前字符的下一个字符的位置
 	ch           byte //当前字符
+	line         int  //当前字符所在的行
+	column       int  //当前字符所在的列
 }
 
 // NewLexer 创建一个新的Lexer
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input} //初始化一个Lexer l，将input赋值给l.input
-	l.readChar()              //初始化l.ch、l.position、l.readPosition
+	l := &Lexer{input: input, line: 1} //初始化一个Lexer l，将input赋值给l.input
+	l.readChar()                       //初始化l.ch、l.position、l.readPosition
 	return l
 }
 
 // readChar 读取下一个字符，将l.readPosition向后移动一位
 func (l *Lexer) readChar() {
+	//离开换行符时进入下一行
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
 	//如果读取到了input的末尾，就将ch设置为0，表示到达了文件末尾
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
@@ -26,6 +33,7 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column += 1
 }
 
 // NextToken 读取下一个token
@@ -33,6 +41,8 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token //声明一个token
 
 	l.skipWhitespace() //跳过空白符
+	//记录token起始的位置
+	line, column := l.line, l.column
 
 	//根据当前字符，判断当前token的类型
 	switch l.ch {
@@ -71,7 +81,12 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '/' {
+			l.readChar()
+			tok = token.Token{Type: token.DOUBLE_SLASH, Literal: "//"}
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -80,6 +95,31 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
+	case '.':
+		//只有连续三个'.'才组成ELLIPSIS，否则视为非法字符
+		if l.peekChar() == '.' && l.peekCharN(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '"':
+		literal, ok := l.readString()
+		if ok {
+			tok = token.Token{Type: token.STRING, Literal: literal}
+		} else {
+			//字符串没有闭合
+			tok = token.Token{Type: token.ILLEGAL, Literal: literal}
+		}
 	case 0:
 		//EOF
 		tok.Literal = ""
@@ -89,10 +129,11 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readCharIdent()
 			tok.Type = token.LookupIdent(tok.Literal) //判断标识符是否是关键字
-			return tok                                //返回一个标识符token
+			tok.Line, tok.Column = line, column
+			return tok //返回一个标识符token
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readCharIdent()
-			tok.Type = token.INT
+			tok = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -101,6 +142,7 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -142,16 +184,32 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-// readNumber 读取数字
-func (l *Lexer) readNumber() string {
+// readNumber 读取整数或者小数。小数必须以d结尾，例如1.25d和3d，没有后缀的小数是非法的
+func (l *Lexer) readNumber() token.Token {
 	// 记录当前字符的位置
 	currentPosition := l.position
-	// 如果当前字符是数字，就一直读取下一个字符
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	// 返回从current_position到l.position的字符串
-	return l.input[currentPosition:l.position]
+	fraction := l.ch == '.' && isDigit(l.peekChar())
+	if fraction {
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+	if l.ch == 'd' && !isDigit(l.peekChar()) && !isLetter(l.peekChar()) {
+		l.readChar()
+		return token.Token{Type: token.DECIMAL, Literal: l.input[currentPosition:l.position]}
+	}
+	if fraction {
+		return token.Token{Type: token.ILLEGAL, Literal: l.input[currentPosition:l.position]}
+	}
+	// 与标识符一样读到字母和数字结束，12abc作为一个整数由语法分析器报错
+	for isDigit(l.ch) || isLetter(l.ch) {
+		l.readChar()
+	}
+	return token.Token{Type: token.INT, Literal: l.input[currentPosition:l.position]}
 }
 
 // 完全可以整合number 和 identifier到一个函数readCharIdent中
@@ -177,3 +235,44 @@ func (l *Lexer) peekChar() byte {
 		return l.input[l.readPosition]
 	}
 }
+
+// peekCharN 返回当前字符之后第n个字符，但不会改变l.ch的值
+func (l *Lexer) peekCharN(n int) byte {
+	position := l.position + n
+	if position >= len(l.input) {
+		return 0
+	}
+	return l.input[position]
+}
+
+// readString 读取字符串字面量，进入时l.ch为起始的'"'，返回时l.ch为结束的'"'
+// 支持\n、\t、\r、\"、\\几种转义，如果直到文件末尾都没有遇到结束的'"'，第二个返回值为false
+func (l *Lexer) readString() (string, bool) {
+	var out []byte
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return string(out), true
+		case 0:
+			return string(out), false
+		case '\\':
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out = append(out, '\n')
+			case 't':
+				out = append(out, '\t')
+			case 'r':
+				out = append(out, '\r')
+			case 0:
+				return string(out), false
+			default:
+				//包括\"和\\在内，其余字符原样保留
+				out = append(out, l.ch)
+			}
+		default:
+			out = append(out, l.ch)
+		}
+	}
+}
@@ -1,13 +1,73 @@
 package main
 
 import (
+	"Interp/object"
 	"Interp/repl"
+	"Interp/runner"
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const usage = `usage:
+  interp                          start the REPL
+  interp run [-engine vm] file    run a script (.mk) or a compiled script (.mkc)
+  interp compile file.mk -o out   compile a script to bytecode
+  interp disasm file              print the bytecode of a script (.mk or .mkc)
+  interp bundle file.mk -o out    merge a script and the modules it imports into one script
+
+run, compile, disasm and bundle accept -O to optimize the script before running or compiling it
+run accepts -integers promote|wrap|checked to choose what happens when integer arithmetic overflows,
+-precision n to set the significant digits kept by decimal arithmetic (default 28)
+-rounding half-even|half-up|down|up|floor|ceiling to choose how decimals are rounded
+and -truthiness default|falsy|strict to choose which values count as false in conditions and !
+run and bundle accept -path dirs to list the directories searched for imported modules, separated by the system's path list separator
+run accepts -allow-files dirs to let the file module access the listed directories, and -read-only to only allow reading them
+run accepts -allow-exec programs to let exec run the listed programs, separated by commas, or * to allow any program,
+and -inherit-env to pass the whole environment to them instead of only PATH
+run accepts -now time to fix the current time seen by the time module (for example 2024-01-02T15:04:05Z),
+-seed n to seed the random module, and -deterministic to fail when the script reads the real clock, uses unseeded randomness,
+runs programs, uses the host's local time zone or orders built-in functions
+`
+
 func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		startRepl()
+		return
+	}
+
+	var err error
+	switch args[0] {
+	case "repl":
+		startRepl()
+	case "run":
+		err = runCommand(args[1:])
+	case "compile":
+		err = compileCommand(args[1:])
+	case "disasm":
+		err = disasmCommand(args[1:])
+	case "bundle":
+		err = bundleCommand(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		// interp file.mk 等同于 interp run file.mk
+		err = runCommand(args)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func startRepl() {
 	current, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,3 +75,194 @@ func main() {
 	fmt.Printf("Hello %s! Ashside's Interp is running!\n", current.Username)
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func runCommand(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	engine := fs.String("engine", "evaluator", "execution engine: evaluator or vm")
+	optimize := fs.Bool("O", false, "fold constants and remove dead code before running")
+	integers := fs.String("integers", "promote", "integer overflow: promote to big integers, wrap or checked")
+	precision := fs.Int("precision", 0, "significant digits kept by decimal arithmetic, 0 for the default")
+	rounding := fs.String("rounding", "half-even", "decimal rounding: half-even, half-up, down, up, floor or ceiling")
+	truthiness := fs.String("truthiness", "default", "false values: default (false and null), falsy (also zero and empty values) or strict (booleans only)")
+	path := fs.String("path", "", "directories searched for imported modules, separated by "+string(os.PathListSeparator))
+	allowFiles := fs.String("allow-files", "", "directories the file module may access, separated by "+string(os.PathListSeparator))
+	readOnly := fs.Bool("read-only", false, "only allow the file module to read files")
+	allowExec := fs.String("allow-exec", "", "programs exec may run, separated by commas, or * for any program")
+	inheritEnv := fs.Bool("inherit-env", false, "let programs run by exec see the whole environment instead of only PATH")
+	now := fs.String("now", "", "fix the time returned by the time module, in RFC 3339 format")
+	seed := fs.String("seed", "", "seed for the random module, random when empty")
+	deterministic := fs.Bool("deterministic", false, "fail when the script reads the real clock, uses unseeded randomness, runs programs, uses the local time zone or orders built-in functions")
+	files := parseFlags(fs, args)
+	if len(files) != 1 {
+		return fmt.Errorf("run: expected exactly one script\n%s", usage)
+	}
+
+	options := runner.Options{Optimize: *optimize, DecimalPrecision: *precision}
+	if *path != "" {
+		options.SearchPath = filepath.SplitList(*path)
+	}
+	if *allowFiles != "" {
+		options.Files = &object.FileAccess{Roots: filepath.SplitList(*allowFiles), ReadOnly: *readOnly}
+	}
+	if *now != "" {
+		fixed, err := time.Parse(time.RFC3339Nano, *now)
+		if err != nil {
+			return fmt.Errorf("run: invalid -now time %q", *now)
+		}
+		options.Clock = object.FixedClock{Time: fixed}
+	}
+	if *seed != "" {
+		n, err := strconv.ParseInt(*seed, 10, 64)
+		if err != nil {
+			return fmt.Errorf("run: invalid -seed %q", *seed)
+		}
+		options.Seed = &n
+	}
+	options.Deterministic = *deterministic
+	switch *allowExec {
+	case "":
+	case "*":
+		options.Exec = &object.ExecAccess{InheritEnv: *inheritEnv}
+	default:
+		options.Exec = &object.ExecAccess{Allowed: strings.Split(*allowExec, ","), InheritEnv: *inheritEnv}
+	}
+	switch *engine {
+	case "evaluator":
+		options.Engine = runner.Evaluator
+	case "vm":
+		options.Engine = runner.VM
+	default:
+		return fmt.Errorf("run: unknown engine %q", *engine)
+	}
+	switch *integers {
+	case "promote":
+		options.IntegerMode = object.IntegerPromote
+	case "wrap":
+		options.IntegerMode = object.IntegerWrap
+	case "checked":
+		options.IntegerMode = object.IntegerChecked
+	default:
+		return fmt.Errorf("run: unknown integer mode %q", *integers)
+	}
+	mode, ok := roundingModes[*rounding]
+	if !ok {
+		return fmt.Errorf("run: unknown rounding mode %q", *rounding)
+	}
+	options.DecimalRounding = mode
+	switch *truthiness {
+	case "default":
+		options.Truthiness = object.TruthinessDefault
+	case "falsy":
+		options.Truthiness = object.TruthinessFalsyEmpty
+	case "strict":
+		options.Truthiness = object.TruthinessStrict
+	default:
+		return fmt.Errorf("run: unknown truthiness %q", *truthiness)
+	}
+
+	result, err := runner.New(options).RunFile(files[0])
+	if err != nil {
+		return err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", formatError(errObj))
+	}
+	return nil
+}
+
+var roundingModes = map[string]object.DecimalRounding{
+	"half-even": object.RoundHalfEven,
+	"half-up":   object.RoundHalfUp,
+	"down":      object.RoundDown,
+	"up":        object.RoundUp,
+	"floor":     object.RoundFloor,
+	"ceiling":   object.RoundCeiling,
+}
+
+// formatError 输出未捕获的错误和它经过的调用
+func formatError(err *object.Error) string {
+	var out strings.Builder
+	out.WriteString(err.Inspect())
+	for _, frame := range err.Stack {
+		out.WriteString("\n\tat " + frame)
+	}
+	return out.String()
+}
+
+func compileCommand(args []string) error {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	output := fs.String("o", "", "output file, defaults to the script name with the .mkc extension")
+	optimize := fs.Bool("O", false, "fold constants and remove dead code before compiling")
+	files := parseFlags(fs, args)
+	if len(files) != 1 {
+		return fmt.Errorf("compile: expected exactly one script\n%s", usage)
+	}
+
+	bytecode, err := runner.New(runner.Options{Optimize: *optimize}).CompileFile(files[0])
+	if err != nil {
+		return err
+	}
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(files[0], filepath.Ext(files[0])) + ".mkc"
+	}
+	return os.WriteFile(*output, data, 0644)
+}
+
+func disasmCommand(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	optimize := fs.Bool("O", false, "fold constants and remove dead code before compiling")
+	files := parseFlags(fs, args)
+	if len(files) != 1 {
+		return fmt.Errorf("disasm: expected exactly one script\n%s", usage)
+	}
+	listing, err := runner.New(runner.Options{Optimize: *optimize}).DisassembleFile(files[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(listing)
+	return nil
+}
+
+func bundleCommand(args []string) error {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	output := fs.String("o", "", "output file, defaults to standard output")
+	optimize := fs.Bool("O", false, "fold constants and remove dead code before bundling")
+	path := fs.String("path", "", "directories searched for imported modules, separated by "+string(os.PathListSeparator))
+	files := parseFlags(fs, args)
+	if len(files) != 1 {
+		return fmt.Errorf("bundle: expected exactly one script\n%s", usage)
+	}
+
+	options := runner.Options{Optimize: *optimize}
+	if *path != "" {
+		options.SearchPath = filepath.SplitList(*path)
+	}
+	bundled, err := runner.New(options).Bundle(files[0])
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		fmt.Print(bundled)
+		return nil
+	}
+	return os.WriteFile(*output, []byte(bundled), 0644)
+}
+
+// parseFlags 解析参数，允许选项出现在文件名之后，例如 compile file.mk -o file.mkc
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,159 @@
+package object
+
+import "Interp/ast"
+
+// Captured 按引用的顺序返回函数体中引用的外层变量的值，还没有赋值的变量为nil，用于比较两个函数
+func (f *Function) Captured() []Object {
+	c := &captureWalker{env: f.Env, local: make(map[string]bool)}
+	for _, p := range f.Parameters {
+		c.declarePattern(p)
+	}
+	ast.Inspect(f.Body, c.declare)
+	c.walk(f.Body, 0)
+	return c.values
+}
+
+// Captured 返回闭包捕获的变量的值，之后是函数读取的全局变量的值，与解释器的函数引用的外层变量对应
+func (c *Closure) Captured() []Object {
+	values := make([]Object, 0, len(c.Free)+len(c.Fn.Globals))
+	for _, free := range c.Free {
+		if cell, ok := free.(*Cell); ok {
+			values = append(values, cell.Value)
+		} else {
+			values = append(values, free)
+		}
+	}
+	for _, index := range c.Fn.Globals {
+		var value Object
+		if index < len(c.Globals) {
+			value = c.Globals[index]
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// captureWalker 查找函数体中引用的外层变量。resolver解析过的变量按层数和下标读取，
+// 函数体从depth为0的作用域开始，内层的函数和catch块各多一层；其余的变量按名字读取，函数体中声明的名字不是外层变量
+type captureWalker struct {
+	env    *Environment
+	local  map[string]bool
+	values []Object
+}
+
+func (c *captureWalker) declare(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			c.declarePattern(node.Pattern)
+		} else {
+			c.local[node.Name.Value] = true
+		}
+	case *ast.FunctionLiteral:
+		for _, p := range node.Parameters {
+			c.declarePattern(p)
+		}
+	case *ast.TryExpression:
+		if node.CatchParam != nil {
+			c.local[node.CatchParam.Value] = true
+		}
+	}
+	return true
+}
+
+func (c *captureWalker) declarePattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		c.local[pattern.Value] = true
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			c.declarePattern(element)
+		}
+		if pattern.Rest != nil {
+			c.local[pattern.Rest.Value] = true
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			c.declarePattern(pair.Value)
+		}
+	}
+}
+
+func (c *captureWalker) walk(node ast.Node, depth int) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, s := range node.Statements {
+			c.walk(s, depth)
+		}
+	case *ast.ExpressionStatement:
+		c.walk(node.Expression, depth)
+	case *ast.LetStatement:
+		c.walk(node.Value, depth)
+	case *ast.ReturnStatement:
+		c.walk(node.ReturnValue, depth)
+	case *ast.ThrowStatement:
+		c.walk(node.Value, depth)
+	case *ast.Identifier:
+		c.reference(node, depth)
+	case *ast.PrefixExpression:
+		c.walk(node.Right, depth)
+	case *ast.InfixExpression:
+		c.walk(node.Left, depth)
+		c.walk(node.Right, depth)
+	case *ast.PostfixExpression:
+		c.walk(node.Left, depth)
+	case *ast.IfExpression:
+		c.walk(node.Condition, depth)
+		c.walk(node.Consequence, depth)
+		c.walk(node.Alternative, depth)
+	case *ast.FunctionLiteral:
+		c.walk(node.Body, depth+1)
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return
+		}
+		c.walk(node.Function, depth)
+		for _, arg := range node.Arguments {
+			c.walk(arg, depth)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			c.walk(element, depth)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.walk(pair.Key, depth)
+			c.walk(pair.Value, depth)
+		}
+	case *ast.IndexExpression:
+		c.walk(node.Left, depth)
+		c.walk(node.Index, depth)
+	case *ast.TryExpression:
+		c.walk(node.Block, depth)
+		c.walk(node.Catch, depth+1)
+		c.walk(node.Finally, depth)
+	}
+}
+
+func (c *captureWalker) reference(ident *ast.Identifier, depth int) {
+	var value Object
+	switch {
+	case ident.Resolved:
+		if ident.Depth <= depth {
+			return
+		}
+		var ok bool
+		value, ok = c.env.GetAt(ident.Depth-depth-1, ident.Slot)
+		for i := 0; !ok && i < len(ident.Fallbacks); i++ {
+			value, ok = c.env.GetAt(ident.Fallbacks[i].Depth-depth-1, ident.Fallbacks[i].Slot)
+		}
+	case c.local[ident.Value]:
+		return
+	default:
+		value, _ = c.env.Get(ident.Value)
+	}
+	c.values = append(c.values, value)
+}
@@ -0,0 +1,258 @@
+package object
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Equal 判断两个值是否相等。数字按照数值比较，1 == 1.0d；字符串、数组、哈希表、Result、Option等按照内容递归比较；
+// 函数的参数和函数体相同并且捕获的变量相等时相等；内置函数和宏只与自身相等
+func Equal(a, b Object) bool {
+	return Compare(a, b) == 0
+}
+
+// Compare 在所有的值上定义一个全序，a小于、等于、大于b时分别返回-1、0、1，用于比较运算和排序。
+// 不同种类的值按照种类排序：null < 布尔值 < 数字 < 字符串 < 数组 < 哈希表 < Result < Option < 错误 < quote < 时间 < 其他。
+// 同种类的值中，false < true，数字按数值，字符串按字节，数组按元素的字典序，哈希表按照排好序的键值对的字典序，
+// Err < Ok，None < Some，时间按先后而不考虑时区，函数先按源代码再按捕获的变量，
+// 内置函数和宏等其他值按照它们在内存中的位置，只保证在一次运行中稳定
+func Compare(a, b Object) int {
+	return compare(a, b, &compareState{})
+}
+
+// CompareByContent 与Compare相同，结果取决于内置函数等值在内存中的位置时ok为false，确定性模式下据此拒绝排序
+func CompareByContent(a, b Object) (c int, ok bool) {
+	state := &compareState{}
+	c = compare(a, b, state)
+	return c, !state.byAddress
+}
+
+// compareState 一次比较的状态
+type compareState struct {
+	byAddress bool               // byAddress 是否按照内存中的位置比较了两个不同的值
+	functions map[[2]Object]bool // functions 正在比较的函数，函数捕获自己时这一对视为相等
+}
+
+func compare(a, b Object, state *compareState) int {
+	if a == b {
+		return 0
+	}
+	if rankA, rankB := rank(a), rank(b); rankA != rankB {
+		return compareInts(rankA, rankB)
+	}
+
+	switch a := a.(type) {
+	case *Null:
+		return 0
+	case *Boolean:
+		return compareBools(a.Value, b.(*Boolean).Value)
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return compareInt64(a.Value, b.Value)
+		}
+		return ToRat(a).Cmp(ToRat(b))
+	case *BigInt, *Decimal, *Rational:
+		return ToRat(a).Cmp(ToRat(b))
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	case *Array:
+		return compareSlices(a.Elements, b.(*Array).Elements, state)
+	case *Hash:
+		return compareHashes(a, b.(*Hash), state)
+	case *Result:
+		b := b.(*Result)
+		if a.IsOk != b.IsOk {
+			return compareBools(a.IsOk, b.IsOk)
+		}
+		return compare(a.Value, b.Value, state)
+	case *Option:
+		b := b.(*Option)
+		if a.IsSome != b.IsSome {
+			return compareBools(a.IsSome, b.IsSome)
+		}
+		if !a.IsSome {
+			return 0
+		}
+		return compare(a.Value, b.Value, state)
+	case *ErrorValue:
+		b := b.(*ErrorValue)
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Message, b.Message); c != 0 {
+			return c
+		}
+		return compareOptional(a.Value, b.Value, state)
+	case *Quote:
+		return strings.Compare(a.Node.String(), b.(*Quote).Node.String())
+	case *Time:
+		return a.Value.Compare(b.(*Time).Value)
+	case *Function:
+		if b, ok := b.(*Function); ok {
+			return compareFunctions(a, b, a.Inspect(), b.Inspect(), a.Captured, b.Captured, state)
+		}
+	case *Closure:
+		if b, ok := b.(*Closure); ok {
+			return compareFunctions(a, b, a.Fn.Source, b.Fn.Source, a.Captured, b.Captured, state)
+		}
+	}
+
+	if c := strings.Compare(string(a.Type()), string(b.Type())); c != 0 {
+		return c
+	}
+	pa, pb := reflect.ValueOf(a).Pointer(), reflect.ValueOf(b).Pointer()
+	if pa != pb {
+		state.byAddress = true
+	}
+	if pa < pb {
+		return -1
+	} else if pa > pb {
+		return 1
+	}
+	return 0
+}
+
+// compareFunctions 先比较函数字面量的源代码，相同时按顺序比较捕获的变量
+func compareFunctions(a, b Object, sourceA, sourceB string, capturedA, capturedB func() []Object, state *compareState) int {
+	if c := strings.Compare(sourceA, sourceB); c != 0 {
+		return c
+	}
+	pair := [2]Object{a, b}
+	if state.functions[pair] {
+		return 0
+	}
+	if state.functions == nil {
+		state.functions = make(map[[2]Object]bool)
+	}
+	state.functions[pair] = true
+	defer delete(state.functions, pair)
+
+	valuesA, valuesB := capturedA(), capturedB()
+	for i := 0; i < len(valuesA) && i < len(valuesB); i++ {
+		if c := compareOptional(valuesA[i], valuesB[i], state); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(valuesA), len(valuesB))
+}
+
+// ToRat 将数字转换为分数，不是数字时返回nil
+func ToRat(obj Object) *big.Rat {
+	switch obj := obj.(type) {
+	case *Integer:
+		return new(big.Rat).SetInt64(obj.Value)
+	case *BigInt:
+		return new(big.Rat).SetInt(obj.Value)
+	case *Decimal:
+		return obj.Rat()
+	case *Rational:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+// rank 值的种类在全序中的位置，所有的数字属于同一个种类
+func rank(obj Object) int {
+	switch obj.Type() {
+	case NULL_OBJ:
+		return 0
+	case BOOLEAN_OBJ:
+		return 1
+	case INTEGER_OBJ, BIGINT_OBJ, DECIMAL_OBJ, RATIONAL_OBJ:
+		return 2
+	case STRING_OBJ:
+		return 3
+	case ARRAY_OBJ:
+		return 4
+	case HASH_OBJ:
+		return 5
+	case RESULT_OBJ:
+		return 6
+	case OPTION_OBJ:
+		return 7
+	case ERROR_VALUE_OBJ:
+		return 8
+	case QUOTE_OBJ:
+		return 9
+	case TIME_OBJ:
+		return 10
+	default:
+		return 11
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareInts(a, b int) int {
+	return compareInt64(int64(a), int64(b))
+}
+
+func compareBools(a, b bool) int {
+	if a == b {
+		return 0
+	}
+	if b {
+		return -1
+	}
+	return 1
+}
+
+func compareSlices(a, b []Object, state *compareState) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compare(a[i], b[i], state); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+// compareOptional 比较可能为nil的值，nil最小
+func compareOptional(a, b Object, state *compareState) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return compare(a, b, state)
+	}
+}
+
+// compareHashes 两个哈希表的键值对分别按键排序后按字典序比较，与插入的顺序无关
+func compareHashes(a, b *Hash, state *compareState) int {
+	pairsA, pairsB := sortedPairs(a), sortedPairs(b)
+	for i := 0; i < len(pairsA) && i < len(pairsB); i++ {
+		if c := compare(pairsA[i].Key, pairsB[i].Key, state); c != 0 {
+			return c
+		}
+		if c := compare(pairsA[i].Value, pairsB[i].Value, state); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(pairsA), len(pairsB))
+}
+
+func sortedPairs(h *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, key := range h.Keys {
+		pairs = append(pairs, h.Pairs[key])
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return Compare(pairs[i].Key, pairs[j].Key) < 0
+	})
+	return pairs
+}
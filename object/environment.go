@@ -1,19 +1,37 @@
 package object
 
+// Environment 保存变量。resolver解析过的变量按下标保存在slots中，其余的按名字保存在store中
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	slots   []Object
+	outer   *Environment
+	runtime *Runtime
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	// store在第一次按名字绑定时才创建，只使用slots的函数调用不需要分配map
+	return &Environment{outer: outer, runtime: outer.runtime}
 }
+
+// NewEnvironment 创建使用默认设置的全局环境。每个全局环境有自己的Runtime，
+// random.seed等修改运行状态的函数不会影响其他的运行
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{runtime: &Runtime{}}
+}
+
+// NewEnvironmentWithRuntime 创建使用指定设置的全局环境，内层的环境共享同样的设置
+func NewEnvironmentWithRuntime(runtime *Runtime) *Environment {
+	return &Environment{runtime: runtime}
+}
+
+// Runtime 返回这个环境所在的运行的设置
+func (e *Environment) Runtime() *Runtime {
+	if e.runtime == nil {
+		e.runtime = &Runtime{}
+	}
+	return e.runtime
 }
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -24,6 +42,30 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// GetAt 读取向外depth层的环境中第slot个变量，变量还没有赋值时返回false
+func (e *Environment) GetAt(depth, slot int) (Object, bool) {
+	env := e
+	for i := 0; i < depth && env != nil; i++ {
+		env = env.outer
+	}
+	if env == nil || slot >= len(env.slots) || env.slots[slot] == nil {
+		return nil, false
+	}
+	return env.slots[slot], true
+}
+
+// SetAt 给当前环境的第slot个变量赋值，slots的长度不够时自动增长
+func (e *Environment) SetAt(slot int, val Object) Object {
+	for slot >= len(e.slots) {
+		e.slots = append(e.slots, nil)
+	}
+	e.slots[slot] = val
+	return val
+}
@@ -2,9 +2,14 @@ package object
 
 import (
 	"Interp/ast"
+	"Interp/code"
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math/big"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ObjectType string
@@ -14,8 +19,11 @@ type Object interface {
 }
 
 const (
-	INTEGER_OBJ = "INTEGER"
-	BOOLEAN_OBJ = "BOOLEAN"
+	INTEGER_OBJ  = "INTEGER"
+	BIGINT_OBJ   = "BIGINT"
+	DECIMAL_OBJ  = "DECIMAL"
+	RATIONAL_OBJ = "RATIONAL"
+	BOOLEAN_OBJ  = "BOOLEAN"
 
 	NULL_OBJ = "NULL"
 
@@ -24,6 +32,39 @@ const (
 	ERROR_OBJ = "ERROR"
 
 	FUNCTION_OBJ = "FUNCTION"
+
+	STRING_OBJ = "STRING"
+	ARRAY_OBJ  = "ARRAY"
+	HASH_OBJ   = "HASH"
+
+	BUILTIN_OBJ = "BUILTIN"
+
+	ERROR_VALUE_OBJ = "ERROR_VALUE"
+
+	RESULT_OBJ = "RESULT"
+	OPTION_OBJ = "OPTION"
+
+	TIME_OBJ = "TIME"
+
+	QUOTE_OBJ = "QUOTE"
+	MACRO_OBJ = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+)
+
+// 错误的种类，被捕获后可以通过kind区分
+const (
+	GENERIC_ERROR        = "Error"               // GENERIC_ERROR 由throw抛出的普通值
+	TYPE_ERROR           = "TypeError"           // TYPE_ERROR 运算对象的类型不正确
+	NAME_ERROR           = "NameError"           // NAME_ERROR 标识符不存在
+	ARGUMENT_ERROR       = "ArgumentError"       // ARGUMENT_ERROR 函数参数的数量或取值不正确
+	PATTERN_ERROR        = "PatternError"        // PATTERN_ERROR 解构时形状不匹配
+	ARITHMETIC_ERROR     = "ArithmeticError"     // ARITHMETIC_ERROR 算术错误，例如除以零
+	IMPORT_ERROR         = "ImportError"         // IMPORT_ERROR 模块没有被加载
+	PERMISSION_ERROR     = "PermissionError"     // PERMISSION_ERROR 宿主没有授予操作需要的权限
+	IO_ERROR             = "IOError"             // IO_ERROR 读写文件失败，例如文件不存在
+	NONDETERMINISM_ERROR = "NondeterminismError" // NONDETERMINISM_ERROR 确定性模式下使用了结果不能重现的操作
 )
 
 // Integer 每当在源代码中遇到整数字面值时，需要先转换为ast.IntegerLiteral。在对该节点求值时，再将其转换为Object.Integer
@@ -39,6 +80,90 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// BigInt 超出int64范围的整数，由整数运算溢出时产生。能用Integer表示的值总是转换回Integer，
+// 所以BigInt与Integer的取值不会重叠
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+// Decimal 精确的十进制小数，值为Value * 10^-Scale，Scale不小于0。
+// Scale记录小数的位数，1.50d与1.5d相等，但输出时保留末尾的0
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+func (d *Decimal) Type() ObjectType {
+	return DECIMAL_OBJ
+}
+
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Value).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rat 返回与小数相等的分数
+func (d *Decimal) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(d.Value, denom)
+}
+
+// ParseDecimal 解析形如-12.50的字符串，不接受指数和结尾的d
+func ParseDecimal(s string) (*Decimal, bool) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "+"), "-")
+	scale := 0
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		scale = len(digits) - dot - 1
+		digits = digits[:dot] + digits[dot+1:]
+		if dot == 0 || scale == 0 {
+			return nil, false
+		}
+	}
+	for _, ch := range digits {
+		if ch < '0' || ch > '9' {
+			return nil, false
+		}
+	}
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, false
+	}
+	if strings.HasPrefix(s, "-") {
+		value.Neg(value)
+	}
+	return &Decimal{Value: value, Scale: scale}, true
+}
+
+// Rational 精确除法//的结果。分母为1的结果总是转换回整数，所以Rational的值不会是整数
+type Rational struct {
+	Value *big.Rat
+}
+
+func (r *Rational) Type() ObjectType {
+	return RATIONAL_OBJ
+}
+
+func (r *Rational) Inspect() string {
+	return r.Value.String()
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -74,8 +199,13 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Error 正在传播的错误，会中断求值直到被try捕获或到达程序顶层
 type Error struct {
-	Message string
+	Message  string
+	Kind     string   // 错误的种类，例如TypeError
+	Stack    []string // 错误传播时经过的调用，最内层在前
+	Value    Object   // throw抛出的原始值，宿主产生的错误为nil
+	Position string   // 产生错误的节点在源代码中的位置，宏展开的代码同时包含宏定义处和调用处
 }
 
 func (e *Error) Type() ObjectType {
@@ -83,13 +213,42 @@ func (e *Error) Type() ObjectType {
 }
 
 func (e *Error) Inspect() string {
+	if e.Position != "" {
+		return "ERROR: " + e.Message + " (at " + e.Position + ")"
+	}
 	return "ERROR: " + e.Message
 }
 
+// AddFrame 在调用栈的末尾记录count次同样的调用。连续相同的调用合并为一项，形如 "f (1:5) ×3"，
+// 深层递归出错时调用栈的长度不随递归的深度增长
+func (e *Error) AddFrame(frame string, count int) {
+	if n := len(e.Stack); n > 0 {
+		if last, times := splitFrame(e.Stack[n-1]); last == frame {
+			e.Stack[n-1] = fmt.Sprintf("%s ×%d", frame, times+count)
+			return
+		}
+	}
+	if count > 1 {
+		frame = fmt.Sprintf("%s ×%d", frame, count)
+	}
+	e.Stack = append(e.Stack, frame)
+}
+
+// splitFrame 拆分调用栈中的一项，返回调用和它连续出现的次数
+func splitFrame(entry string) (string, int) {
+	if i := strings.LastIndex(entry, " ×"); i >= 0 {
+		if times, err := strconv.Atoi(entry[i+len(" ×"):]); err == nil {
+			return entry[:i], times
+		}
+	}
+	return entry, 1
+}
+
 type Function struct {
-	Parameters []*ast.Identifier
+	Parameters []ast.Pattern
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string // 函数名，匿名函数为空
 }
 
 func (f *Function) Type() ObjectType {
@@ -106,8 +265,303 @@ func (f *Function) Inspect() string {
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(") ")
 	out.WriteString(f.Body.String())
-	out.WriteString("\n}")
 	return out.String()
 }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashKey 哈希表中键的摘要，只有实现了Hashable接口的对象才能作为键
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable 可以作为哈希键的对象
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write(b.Value.Bytes())
+	value := h.Sum64()
+	if b.Value.Sign() < 0 {
+		value = ^value
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair 保存原始的键对象，便于Inspect时输出
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash 哈希表，Keys记录键的插入顺序，保证Inspect的输出稳定
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+// NewHash 创建一个空的哈希表
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set 设置键值对，新键追加到Keys末尾，已有的键保持原来的位置
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+// Get 根据键查找值
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	var pairs []string
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// ErrorValue 被catch捕获后的错误，是一个普通的值，可以通过message、kind、stack、value、position索引查看
+type ErrorValue struct {
+	Message  string
+	Kind     string
+	Stack    []string
+	Value    Object
+	Position string
+}
+
+func (ev *ErrorValue) Type() ObjectType {
+	return ERROR_VALUE_OBJ
+}
+
+func (ev *ErrorValue) Inspect() string {
+	return ev.Kind + ": " + ev.Message
+}
+
+// BuiltinFunction 由宿主实现的内置函数，rt是调用它的这次运行的设置，出错时返回*Error
+type BuiltinFunction func(rt *Runtime, args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function"
+}
+
+// Result 显式的错误值，IsOk为true时表示Ok(Value)，否则表示Err(Value)
+type Result struct {
+	IsOk  bool
+	Value Object
+}
+
+func (r *Result) Type() ObjectType {
+	return RESULT_OBJ
+}
+
+func (r *Result) Inspect() string {
+	if r.IsOk {
+		return "Ok(" + r.Value.Inspect() + ")"
+	}
+	return "Err(" + r.Value.Inspect() + ")"
+}
+
+// Option 可能不存在的值，IsSome为true时表示Some(Value)，否则表示None，此时Value为nil
+type Option struct {
+	IsSome bool
+	Value  Object
+}
+
+func (o *Option) Type() ObjectType {
+	return OPTION_OBJ
+}
+
+func (o *Option) Inspect() string {
+	if o.IsSome {
+		return "Some(" + o.Value.Inspect() + ")"
+	}
+	return "None"
+}
+
+// Time 由time模块产生的时间点，带有时区
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType {
+	return TIME_OBJ
+}
+
+func (t *Time) Inspect() string {
+	return t.Value.Format(time.RFC3339Nano)
+}
+
+// Quote 由quote产生，携带一棵未求值的语法树
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	var params []string
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(m.Body.String())
+	return out.String()
+}
+
+// CompiledFunction 编译后的函数，保存在常量池中
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // NumLocals 局部变量的个数，包括参数
+	NumParameters int
+	NumFree       int            // NumFree 闭包捕获的自由变量个数
+	Name          string         // 函数名，匿名函数为空
+	SourceMap     code.SourceMap // SourceMap 指令对应的源代码位置
+	LocalNames    []string       // LocalNames 按下标记录局部变量的名字，用于报告还没有赋值的变量
+	Source        string         // Source 函数字面量的源代码，用于比较两个函数是否相等
+	Globals       []int          // Globals 函数体和其中的内层函数读取的全局变量的下标，用于比较两个函数是否相等
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure 运行时的函数，Free保存捕获的自由变量，局部变量以Cell的形式捕获
+type Closure struct {
+	Fn      *CompiledFunction
+	Free    []Object
+	Globals []Object // Globals 创建闭包的虚拟机的全局变量，函数按下标直接读取它们
+}
+
+// Type 与解释器的函数相同，两种执行方式在错误信息中报告同样的类型
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+// Inspect 输出函数字面量的源代码，与解释器的函数相同
+func (c *Closure) Inspect() string {
+	return c.Fn.Source
+}
+
+// Cell 被闭包捕获的局部变量，函数和它创建的闭包共享同一个Cell。Value为nil表示声明变量的let还没有执行
+type Cell struct {
+	Name  string
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "Cell[]"
+	}
+	return fmt.Sprintf("Cell[%s]", c.Value.Inspect())
+}
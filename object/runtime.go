@@ -0,0 +1,155 @@
+package object
+
+import (
+	"math/rand"
+	"time"
+)
+
+// IntegerMode 整数运算溢出时的处理方式
+type IntegerMode int
+
+const (
+	IntegerPromote IntegerMode = iota // IntegerPromote 溢出时转换为BigInt，结果能用int64表示时再转换回Integer
+	IntegerWrap                       // IntegerWrap 按照int64回绕
+	IntegerChecked                    // IntegerChecked 溢出时报ArithmeticError
+)
+
+// Truthiness 条件和!运算符判断真假的规则
+type Truthiness int
+
+const (
+	TruthinessDefault    Truthiness = iota // TruthinessDefault 只有false和null为假
+	TruthinessFalsyEmpty                   // TruthinessFalsyEmpty false、null、数字0、空字符串、空数组和空哈希表为假
+	TruthinessStrict                       // TruthinessStrict 条件和!的操作数必须是布尔值，否则报TypeError
+)
+
+// DecimalRounding 小数运算结果超出精度时的舍入方式
+type DecimalRounding int
+
+const (
+	RoundHalfEven DecimalRounding = iota // RoundHalfEven 四舍六入，恰好一半时舍入到偶数
+	RoundHalfUp                          // RoundHalfUp 四舍五入，恰好一半时远离零
+	RoundDown                            // RoundDown 向零舍入
+	RoundUp                              // RoundUp 远离零舍入
+	RoundFloor                           // RoundFloor 向负无穷舍入
+	RoundCeiling                         // RoundCeiling 向正无穷舍入
+)
+
+// DefaultDecimalPrecision 小数运算默认保留的有效数字位数
+const DefaultDecimalPrecision = 28
+
+// MaxCallDepth 两种引擎共同的调用深度上限，最外层的程序也算一层，超过时报stack overflow。
+// 解释器的每层调用都占用Go的调用栈，上限要保证它在耗尽Go的调用栈之前报错
+const MaxCallDepth = 1 << 15
+
+// MaxEvalDepth 解释器中节点求值嵌套的层数上限。函数体中嵌套很深的表达式使每层调用占用更多的Go调用栈，
+// 调用深度还没有达到MaxCallDepth时也在这里报stack overflow
+const MaxEvalDepth = 1 << 18
+
+// Runtime 一次运行的设置，由这次运行中的所有环境共享。零值使用默认的设置
+type Runtime struct {
+	IntegerMode IntegerMode
+	Truthiness  Truthiness
+	// DecimalPrecision 小数运算的结果最多保留的有效数字，整数部分不会被舍入。0表示DefaultDecimalPrecision
+	DecimalPrecision int
+	DecimalRounding  DecimalRounding
+
+	// Modules 已经执行过的模块导出的哈希表，键是模块文件的绝对路径。每个模块只执行一次，之后的导入共享同一个值
+	Modules map[string]Object
+	// LoadModule 第一次导入模块文件时执行它，返回导出的哈希表，模块执行出错时返回错误，宿主没有准备这个模块时返回nil。
+	// 由宿主在执行之前设置，nil表示只能导入Modules中已经执行过的模块
+	LoadModule func(path string) Object
+
+	// Files 宿主授予脚本的文件权限，nil表示file模块的所有操作都被拒绝
+	Files *FileAccess
+	// Exec 宿主授予脚本的执行外部程序的权限，nil表示exec被禁用
+	Exec *ExecAccess
+	// Clock time模块读取当前时间的时钟，nil表示使用操作系统的时间
+	Clock Clock
+	// Random random模块使用的随机数源。nil表示还没有设置种子，第一次使用时随机地初始化
+	Random *rand.Rand
+	// Deterministic 禁止结果不能重现的操作：没有固定时钟时读取当前时间，没有设置种子时生成随机数，
+	// 执行外部程序，使用宿主的时区，以及按照内置函数或宏在内存中的位置排序
+	Deterministic bool
+
+	// CallClosure 在正在运行的虚拟机中执行编译后的函数，内置函数借此调用作为参数传入的闭包。由虚拟机在运行时设置
+	CallClosure func(cl *Closure, args []Object) Object
+
+	callDepth int // 解释器中正在执行的函数调用的层数，尾调用不增加层数
+	evalDepth int // 解释器中正在求值的节点嵌套的层数
+}
+
+// FileAccess 脚本可以访问的文件。符号链接按照它指向的位置检查，不能借此访问目录之外的文件
+type FileAccess struct {
+	// Roots 允许访问的目录，其中的文件和各级子目录都可以访问
+	Roots []string
+	// ReadOnly 只允许读取，写入和删除文件被拒绝
+	ReadOnly bool
+}
+
+// ExecAccess exec可以执行的程序
+type ExecAccess struct {
+	// Allowed 允许执行的程序名或路径，与要执行的程序都按照PATH查找之后比较。为空时可以执行任何程序。
+	// 不为空时脚本不能为程序设置PATH和LD_、DYLD_开头的环境变量，否则可以借允许的程序执行其他程序
+	Allowed []string
+	// InheritEnv 程序是否继承宿主的全部环境变量。默认只继承PATH，宿主环境中的密钥等不会交给程序
+	InheritEnv bool
+}
+
+// Clock 提供当前时间。宿主可以换成固定的时钟，使读取时间的脚本每次运行的结果相同
+type Clock interface {
+	Now() time.Time
+}
+
+// FixedClock 总是返回同一个时间的时钟
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
+
+// Now 返回rt的时钟的当前时间
+func (rt *Runtime) Now() time.Time {
+	if rt.Clock == nil {
+		return time.Now()
+	}
+	return rt.Clock.Now()
+}
+
+// EnterCall 进入一层函数调用，超过MaxCallDepth时返回false，这时不需要调用LeaveCall
+func (rt *Runtime) EnterCall() bool {
+	if rt.callDepth+1 >= MaxCallDepth {
+		return false
+	}
+	rt.callDepth++
+	return true
+}
+
+// LeaveCall 离开EnterCall进入的一层调用
+func (rt *Runtime) LeaveCall() {
+	rt.callDepth--
+}
+
+// EnterEval 开始对一个节点求值，超过MaxEvalDepth时返回false，这时不需要调用LeaveEval
+func (rt *Runtime) EnterEval() bool {
+	if rt.evalDepth >= MaxEvalDepth {
+		return false
+	}
+	rt.evalDepth++
+	return true
+}
+
+// LeaveEval 结束EnterEval开始的求值
+func (rt *Runtime) LeaveEval() {
+	rt.evalDepth--
+}
+
+// Precision 返回小数运算使用的有效数字位数
+func (rt *Runtime) Precision() int {
+	if rt.DecimalPrecision <= 0 {
+		return DefaultDecimalPrecision
+	}
+	return rt.DecimalPrecision
+}
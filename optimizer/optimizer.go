@@ -0,0 +1,218 @@
+// Package optimizer 在执行之前化简语法树：折叠常量表达式，删除条件为字面量的if中不会执行的分支，
+// 删除return和throw之后不会执行的语句。化简不改变程序的行为，可能出错的表达式（例如除以零和溢出）保持原样，
+// 留到运行时报错
+package optimizer
+
+import (
+	"Interp/ast"
+	"Interp/token"
+	"math"
+	"strconv"
+)
+
+// Optimize 在原来的语法树上化简程序并返回它。应当在resolver之后调用，这样不会执行的代码中使用的未声明变量仍然会被报告
+func Optimize(program *ast.Program) *ast.Program {
+	skip := quotedNodes(program)
+	optimized := ast.Modify(program, func(node ast.Node) ast.Node {
+		if skip[node] {
+			return node
+		}
+		switch node := node.(type) {
+		case *ast.PrefixExpression:
+			return foldPrefix(node)
+		case *ast.InfixExpression:
+			return foldInfix(node)
+		case *ast.IfExpression:
+			return pruneIf(node)
+		case *ast.BlockStatement:
+			node.Statements = simplifyStatements(node.Statements)
+		case *ast.Program:
+			node.Statements = simplifyStatements(node.Statements)
+		}
+		return node
+	}).(*ast.Program)
+
+	// 删除分支之后，原来在if中的调用可能成为函数的最后一个表达式
+	ast.Inspect(optimized, func(node ast.Node) bool {
+		if fn, ok := node.(*ast.FunctionLiteral); ok && !skip[fn] {
+			ast.MarkTailCalls(fn.Body)
+		}
+		return true
+	})
+	return optimized
+}
+
+// quotedNodes 收集quote的参数和宏字面量中的节点。被quote的代码是数据，化简会改变它的值
+func quotedNodes(program *ast.Program) map[ast.Node]bool {
+	nodes := make(map[ast.Node]bool)
+	collect := func(root ast.Node) {
+		ast.Inspect(root, func(node ast.Node) bool {
+			if node != nil {
+				nodes[node] = true
+			}
+			return true
+		})
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			if node.Function.TokenLiteral() == "quote" {
+				for _, arg := range node.Arguments {
+					collect(arg)
+				}
+				return false
+			}
+		case *ast.MacroLiteral:
+			collect(node)
+			return false
+		}
+		return true
+	})
+	return nodes
+}
+
+func foldPrefix(node *ast.PrefixExpression) ast.Expression {
+	switch right := node.Right.(type) {
+	case *ast.IntegerLiteral:
+		// 整数的!取决于真值的规则，不折叠
+		if node.Operator == "-" && right.Value != math.MinInt64 {
+			return newInteger(node.Token, -right.Value)
+		}
+	case *ast.Boolean:
+		if node.Operator == "!" {
+			return newBoolean(node.Token, !right.Value)
+		}
+	}
+	return node
+}
+
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	switch left := node.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := node.Right.(*ast.IntegerLiteral); ok {
+			return foldIntegers(node, left.Value, right.Value)
+		}
+	case *ast.Boolean:
+		if right, ok := node.Right.(*ast.Boolean); ok {
+			switch node.Operator {
+			case "==":
+				return newBoolean(node.Token, left.Value == right.Value)
+			case "!=":
+				return newBoolean(node.Token, left.Value != right.Value)
+			}
+		}
+	}
+	return node
+}
+
+// foldIntegers 计算两个整数常量，溢出和除以零时不折叠
+func foldIntegers(node *ast.InfixExpression, left, right int64) ast.Expression {
+	switch node.Operator {
+	case "+":
+		if sum := left + right; (sum > left) == (right > 0) {
+			return newInteger(node.Token, sum)
+		}
+	case "-":
+		if diff := left - right; (diff < left) == (right > 0) {
+			return newInteger(node.Token, diff)
+		}
+	case "*":
+		if left == 0 || right == 0 {
+			return newInteger(node.Token, 0)
+		}
+		product := left * right
+		if product/right == left && !(right == -1 && left == math.MinInt64) {
+			return newInteger(node.Token, product)
+		}
+	case "/":
+		if right != 0 && !(left == math.MinInt64 && right == -1) {
+			return newInteger(node.Token, left/right)
+		}
+	case "==":
+		return newBoolean(node.Token, left == right)
+	case "!=":
+		return newBoolean(node.Token, left != right)
+	case "<":
+		return newBoolean(node.Token, left < right)
+	case ">":
+		return newBoolean(node.Token, left > right)
+	}
+	return node
+}
+
+// pruneIf 条件是布尔字面量，并且会执行的分支只有一个表达式时，用这个表达式替换整个if。
+// 其余情况在simplifyStatements中处理
+func pruneIf(node *ast.IfExpression) ast.Expression {
+	branch, ok := literalBranch(node)
+	if !ok || branch == nil || len(branch.Statements) != 1 {
+		return node
+	}
+	if statement, ok := branch.Statements[0].(*ast.ExpressionStatement); ok && statement.Expression != nil {
+		return statement.Expression
+	}
+	return node
+}
+
+// literalBranch 返回条件为布尔字面量的if会执行的分支，没有else时可能为nil
+func literalBranch(node *ast.IfExpression) (*ast.BlockStatement, bool) {
+	condition, ok := node.Condition.(*ast.Boolean)
+	if !ok {
+		return nil, false
+	}
+	if condition.Value {
+		return node.Consequence, true
+	}
+	return node.Alternative, true
+}
+
+// simplifyStatements 将作为语句的if替换为会执行的分支中的语句，并删除return和throw之后的语句。
+// if块不创建作用域，所以分支中的let可以直接放到外层。
+// 最后一个语句的值是整个块的值，只有会执行的分支不为空时才能替换
+func simplifyStatements(statements []ast.Statement) []ast.Statement {
+	var result []ast.Statement
+	for i, statement := range statements {
+		last := i == len(statements)-1
+		if expression, ok := statement.(*ast.ExpressionStatement); ok {
+			if node, ok := expression.Expression.(*ast.IfExpression); ok {
+				if branch, ok := literalBranch(node); ok {
+					if !last {
+						if branch != nil {
+							result = append(result, branch.Statements...)
+						}
+						continue
+					}
+					if branch != nil && len(branch.Statements) > 0 {
+						result = append(result, branch.Statements...)
+						break
+					}
+				}
+			}
+		}
+		result = append(result, statement)
+	}
+
+	for i, statement := range result {
+		switch statement.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			return result[:i+1]
+		}
+	}
+	return result
+}
+
+// newInteger 折叠的结果使用原表达式的位置
+func newInteger(tok token.Token, value int64) *ast.IntegerLiteral {
+	tok.Type = token.INT
+	tok.Literal = strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{Token: tok, Value: value}
+}
+
+func newBoolean(tok token.Token, value bool) *ast.Boolean {
+	tok.Type = token.FALSE
+	tok.Literal = "false"
+	if value {
+		tok.Type = token.TRUE
+		tok.Literal = "true"
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
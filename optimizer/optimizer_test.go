@@ -0,0 +1,116 @@
+package optimizer
+
+import (
+	"Interp/ast"
+	"Interp/evaluator"
+	"Interp/lexer"
+	"Interp/object"
+	"Interp/parser"
+	"fmt"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{"1 + 2 * 3 - 4 / 2", "5"},
+		{"-(3 - 5)", "2"},
+		{"1 < 2 == true", "true"},
+		{"!(1 > 2) != false", "true"},
+		{"x + 2 * 3", "(x + 6)"},
+		// 可能出错的表达式留到运行时
+		{"1 / 0", "(1 / 0)"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+		{"-9223372036854775807 - 2", "(-9223372036854775807 - 2)"},
+		{"4611686018427387904 * 2", "(4611686018427387904 * 2)"},
+		{"1 + true", "(1 + true)"},
+		{"!1", "(!1)"},
+		// 条件为字面量的if
+		{"let x = if (1 < 2) { 10 } else { 20 };", "let x = 10;"},
+		{"if (false) { a } else { b }", "b"},
+		{"if (true) { let a = 1; puts(a) }; a", "let a = 1;\nputs(a);\na"},
+		{"if (false) { a }; b", "b"},
+		{"if (x) { 1 } else { 2 }", "if (x) { 1 } else { 2 }"},
+		// 最后一个语句的值是程序的值，没有else时不能删除
+		{"1; if (false) { 2 }", "1;\nif (false) { 2 }"},
+		// return和throw之后的语句
+		{"fn() { return 1; puts(2); 3 }", "fn() { return 1; }"},
+		{"fn() { if (true) { throw \"e\" }; 3 }", "fn() { throw \"e\"; }"},
+		// 被quote的代码是数据
+		{"quote(1 + 2)", "quote((1 + 2))"},
+		{"let m = macro() { quote(2 * 3) };", "let m = macro() { quote((2 * 3)) };"},
+	}
+
+	for _, tt := range tests {
+		optimized := Optimize(parse(t, tt.input))
+		if optimized.String() != tt.expected {
+			t.Errorf("wrong optimization of %q.\nwant=%q\ngot =%q", tt.input, tt.expected, optimized.String())
+		}
+	}
+}
+
+func TestOptimizeMarksNewTailCalls(t *testing.T) {
+	program := Optimize(parse(t, "fn() { if (true) { f() }; }"))
+
+	var tail []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && call.Tail {
+			tail = append(tail, call.Function.String())
+		}
+		return true
+	})
+	if len(tail) != 1 || tail[0] != "f" {
+		t.Errorf("expected f() to become a tail call. got=%v", tail)
+	}
+}
+
+func TestOptimizePreservesBehavior(t *testing.T) {
+	inputs := []string{
+		"let day = 60 * 60 * 24; day / 3600",
+		"let f = fn(x) { if (true) { return x * 2; x } else { x } }; f(21)",
+		"let f = fn() { if (false) { 1 } }; f()",
+		"if (true) { }",
+		"let x = 1; if (false) { let x = 2 }; x",
+		"1 / 0",
+		"let r = try { if (true) { throw 1 + 1 }; 0 } catch (e) { e[\"value\"] }; r",
+		"let f = fn(n) { if (n == 0) { 1 + true } else { f(n - 1) } }; f(2 * 1)",
+		"quote(unquote(1 + 2) + 3 * 4)",
+		"9223372036854775807 + 1",
+	}
+
+	for _, input := range inputs {
+		expected := eval(t, parse(t, input))
+		got := eval(t, Optimize(parse(t, input)))
+		if got != expected {
+			t.Errorf("optimization changed the result of %q.\nwant=%s\ngot =%s", input, expected, got)
+		}
+	}
+}
+
+func eval(t *testing.T, program *ast.Program) string {
+	t.Helper()
+	if errors := evaluator.NewResolver().Resolve(program); len(errors) != 0 {
+		t.Fatalf("resolver errors: %v", errors)
+	}
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if result == nil {
+		return "<nil>"
+	}
+	if err, ok := result.(*object.Error); ok {
+		return fmt.Sprintf("%s %s %v", err.Inspect(), err.Kind, err.Stack)
+	}
+	return result.Inspect()
+}
@@ -4,8 +4,11 @@ import (
 	"Interp/ast"
 	"Interp/lexer"
 	"Interp/token"
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
@@ -27,7 +30,9 @@ const (
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
+	POSTFIX     // X?
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 
 )
 
@@ -35,15 +40,18 @@ const (
 // 当右约束能力达到最大值，那么当前解析的结果，即分配给leftExp的值就不会传递给下一个运算符关联的infixParseFn
 // 也就是说，leftExp不会成为左子节点，因为此时parseExpression函数中for循环的条件为false
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.EQ:           EQUALS,
+	token.NOT_EQ:       EQUALS,
+	token.LT:           LESSGREATER,
+	token.GT:           LESSGREATER,
+	token.PLUS:         SUM,
+	token.MINUS:        SUM,
+	token.SLASH:        PRODUCT,
+	token.DOUBLE_SLASH: PRODUCT,
+	token.ASTERISK:     PRODUCT,
+	token.QUESTION:     POSTFIX,
+	token.LPAREN:       CALL,
+	token.LBRACKET:     INDEX,
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
@@ -54,6 +62,7 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.DECIMAL, p.parseDecimalLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -61,16 +70,25 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.DOUBLE_SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.QUESTION, p.parsePostfixExpression)
 	return p
 }
 func (p *Parser) Errors() []string {
@@ -113,6 +131,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -122,13 +144,29 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	//初始化一个let语句
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	//判断下一个token是否是标识符
-	if !p.expectPeekMove(token.IDENT) {
-		return nil
-	}
+	//如果下一个token是[或{，就解析解构模式
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		//判断下一个token是否是标识符
+		if !p.expectPeekMove(token.IDENT) {
+			return nil
+		}
 
-	//初始化一个标识符
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		//标识符也可能是quote中的unquote(...)
+		pattern := p.parsePattern()
+		if ident, ok := pattern.(*ast.Identifier); ok {
+			stmt.Name = ident
+		} else if pattern != nil {
+			stmt.Pattern = pattern
+		} else {
+			return nil
+		}
+	}
 
 	//判断下一个token是否是等号
 	if !p.expectPeekMove(token.ASSIGN) {
@@ -136,6 +174,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	//记录函数名，便于在调用栈中显示
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fl.Name = stmt.Name.Value
+	}
 
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -155,6 +197,40 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// parseThrowStatement 进入函数时，p.cur指向throw
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseExportStatement export之后必须是let语句
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if !p.expectPeekMove(token.LET) {
+		return nil
+	}
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
+// parseImportExpression import之后必须是模块路径的字符串
+func (p *Parser) parseImportExpression() ast.Expression {
+	expr := &ast.ImportExpression{Token: p.curToken}
+	if !p.expectPeekMove(token.STRING) {
+		return nil
+	}
+	expr.Path = p.curToken.Literal
+	return expr
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	//defer untrace(trace("parseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{Token: p.curToken} //初始化一个表达式语句
@@ -198,12 +274,18 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	}
 }
 
-// parseIntegerLiteral 调用了strconv.ParseInt，将p.curToken的字面值赋给Expression(IntegerLiteral)的value字段
+// parseIntegerLiteral 调用了strconv.ParseInt，将p.curToken的字面值赋给Expression(IntegerLiteral)的value字段，
+// 超出int64范围的字面值解析为BigIntegerLiteral
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	//defer untrace(trace("parseIntegerLiteral"))
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: bigValue}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
 		p.errors = append(p.errors, msg)
@@ -213,6 +295,23 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// parseDecimalLiteral 去掉结尾的d和小数点，剩下的数字是Value，小数点之后的位数是Scale
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	digits := strings.TrimSuffix(p.curToken.Literal, "d")
+	scale := 0
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		scale = len(digits) - dot - 1
+		digits = digits[:dot] + digits[dot+1:]
+	}
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		msg := fmt.Sprintf("could not parse %q as decimal", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	return &ast.DecimalLiteral{Token: p.curToken, Value: value, Scale: scale}
+}
+
 // parsePrefixExpression 会调用p.nextToken()来前移词法单元，开始的时候p.curToken是前缀运算符，返回时指向前缀表达式的操作数
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	//defer untrace(trace("parsePrefixExpression"))
@@ -241,6 +340,15 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parsePostfixExpression 后缀运算符没有右操作数，开始和返回的时候p.curToken都是后缀运算符
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Operator: p.curToken.Literal,
+	}
+}
+
 // curTokenIs 判断当前token是否是期望的token
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
@@ -366,6 +474,30 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 	lit.Parameters = p.parseFunctionParameters()
 
+	if !p.expectPeekMove(token.LBRACE) {
+		return nil
+	}
+	lit.Body = p.parseBlockStatement()
+	ast.MarkTailCalls(lit.Body)
+	return lit
+}
+
+// parseMacroLiteral 宏的参数只能是标识符
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeekMove(token.LPAREN) {
+		return nil
+	}
+	for _, param := range p.parseFunctionParameters() {
+		ident, ok := param.(*ast.Identifier)
+		if !ok {
+			msg := fmt.Sprintf("macro parameter must be an identifier, got %s", param)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+	}
+
 	if !p.expectPeekMove(token.LBRACE) {
 		return nil
 	}
@@ -374,32 +506,25 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 }
 
 // parseFunctionParameters 进入函数时，p.cur指向(
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	var idts []*ast.Identifier
+// 每个参数都是一个绑定模式，可以是标识符，也可以是数组或哈希解构模式
+func (p *Parser) parseFunctionParameters() []ast.Pattern {
+	var params []ast.Pattern
 	//如果没有参数
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken() // 再右移一个词法单元
-		return idts
+		return params
 	}
 	p.nextToken() //指向第一个参数
-	ident := &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
-	}
-	idts = append(idts, ident)
+	params = append(params, p.parsePattern())
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() //指向逗号
 		p.nextToken() //指向下一个参数
-		ident := &ast.Identifier{
-			Token: p.curToken,
-			Value: p.curToken.Literal,
-		}
-		idts = append(idts, ident)
+		params = append(params, p.parsePattern())
 	}
 	if !p.expectPeekMove(token.RPAREN) {
 		return nil
 	}
-	return idts
+	return params
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -432,3 +557,200 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	}
 	return args
 }
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseArrayLiteral 进入函数时，p.cur指向[
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
+
+// parseExpressionList 解析以逗号分隔的表达式列表，直到遇见end为止
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	var list []ast.Expression
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeekMove(end) {
+		return nil
+	}
+	return list
+}
+
+// parseHashLiteral 进入函数时，p.cur指向{
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []*ast.HashPair{}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeekMove(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, &ast.HashPair{Key: key, Value: value})
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeekMove(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeekMove(token.RBRACE) {
+		return nil
+	}
+	return hash
+}
+
+// parseIndexExpression 进入函数时，p.cur指向[
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if !p.expectPeekMove(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+// parsePattern 解析一个绑定模式，进入函数时p.cur指向模式的第一个词法单元
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "unquote" && p.peekTokenIs(token.LPAREN) {
+			return p.parseUnquotePattern()
+		}
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		msg := fmt.Sprintf("expected binding pattern, got %s instead", p.curToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+}
+
+// parseUnquotePattern 解析绑定位置上的unquote(...)，进入函数时p.cur指向unquote，返回时指向)
+func (p *Parser) parseUnquotePattern() ast.Pattern {
+	pattern := &ast.UnquotePattern{Token: p.curToken}
+	p.nextToken()
+	p.nextToken()
+	pattern.Argument = p.parseExpression(LOWEST)
+	if !p.expectPeekMove(token.RPAREN) {
+		return nil
+	}
+	return pattern
+}
+
+// parseArrayPattern 解析形如[a, b, ...rest]的模式，进入函数时p.cur指向[，返回时指向]
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			//剩余元素只能出现在最后，并且只能绑定到标识符
+			if !p.expectPeekMove(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeekMove(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeekMove(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+// parseHashPattern 解析形如{name, age: years}的模式，进入函数时p.cur指向{，返回时指向}
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeekMove(token.IDENT) {
+			return nil
+		}
+		pair := &ast.HashPatternPair{Key: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			pair.Value = p.parsePattern()
+			if pair.Value == nil {
+				return nil
+			}
+		} else {
+			//简写形式，绑定到与键同名的标识符
+			pair.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+		pattern.Pairs = append(pattern.Pairs, pair)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeekMove(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeekMove(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
+// parseTryExpression 解析 try { } catch (e) { } finally { }，catch和finally至少出现一个
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeekMove(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken() //此时指向catch
+		if !p.expectPeekMove(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeekMove(token.IDENT) {
+			return nil
+		}
+		expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeekMove(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeekMove(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken() //此时指向finally
+		if !p.expectPeekMove(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errors = append(p.errors, "expected catch or finally after try block")
+		return nil
+	}
+	return expression
+}
@@ -241,6 +241,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		input    string
 		expected string
 	}{
+		{
+			"a + b // c * d",
+			"(a + ((b // c) * d))",
+		},
 		{
 			"-a * b",
 			"((-a) * b)",
@@ -275,7 +279,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		},
 		{
 			"3 + 4; -5 * 5",
-			"(3 + 4)((-5) * 5)",
+			"(3 + 4);\n((-5) * 5)",
 		},
 		{
 			"5 > 4 == 3 < 4",
@@ -341,6 +345,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a + b?",
+			"(a + (b?))",
+		},
+		{
+			"-f(x)?",
+			"(-(f(x)?))",
+		},
+		{
+			"a[0]? * 2",
+			"(((a[0])?) * 2)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -815,3 +831,466 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestDecimalLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue int64
+		expectedScale int
+	}{
+		{"12.50d", 1250, 2},
+		{"3d", 3, 0},
+		{"0.05d", 5, 2},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.DecimalLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value.Int64() != tt.expectedValue || literal.Scale != tt.expectedScale {
+			t.Errorf("%s: wrong value. expected=%d scale %d, got=%s scale %d",
+				tt.input, tt.expectedValue, tt.expectedScale, literal.Value, literal.Scale)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	tests := []string{"9223372036854775808", "123456789012345678901234567890"}
+
+	for _, input := range tests {
+		p := NewParser(lexer.NewLexer(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value.String() != input {
+			t.Errorf("wrong value. expected=%s, got=%s", input, literal.Value)
+		}
+		if literal.String() != input {
+			t.Errorf("literal.String() not %q. got=%q", input, literal.String())
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, `{}`},
+		{`{"one": 1, "two": 2}`, `{"one": 1, "two": 2}`},
+		{`{"one": 0 + 1, true: 2 * 3}`, `{"one": (0 + 1), true: (2 * 3)}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+		}
+		if hash.String() != tt.expected {
+			t.Errorf("hash.String() wrong. want=%q, got=%q", tt.expected, hash.String())
+		}
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1 + 1]", "(myArray[(1 + 1)])"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestDestructuringPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [a, ...rest] = arr;", "let [a, ...rest] = arr;"},
+		{"let [...all] = arr;", "let [...all] = arr;"},
+		{"let [] = arr;", "let [] = arr;"},
+		{"let {name, age: years} = person;", "let {name, age: years} = person;"},
+		{"let {pos: [x, y], tags: {first}} = p;", "let {pos: [x, y], tags: {first}} = p;"},
+		{"fn([a, b], {c}, d) { a };", "fn([a, b], {c}, d) { a }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestDestructuringPatternErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [1] = arr;", "expected binding pattern, got INT instead"},
+		{"let [...rest, a] = arr;", "expected next token to be ], got , instead"},
+		{"let {\"name\"} = p;", "expected next token to be IDENT, got STRING instead"},
+		{"fn(1) {};", "expected binding pattern, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestTryExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x } catch (e) { y }", "try { x } catch (e) { y }"},
+		{"try { x } finally { z }", "try { x } finally { z }"},
+		{"try { x } catch (e) { y } finally { z }", "try { x } catch (e) { y } finally { z }"},
+		{"throw 1 + 2;", "throw (1 + 2);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryExpressionParsingErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x }", "expected catch or finally after try block"},
+		{"try { x } catch e { y }", "expected next token to be (, got IDENT instead"},
+		{"try { x } catch ([a]) { y }", "expected next token to be IDENT, got [ instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestImportExportParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "./util.mk"`, `import "./util.mk"`},
+		{`let {add, sub} = import "math";`, `let {add, sub} = import "math";`},
+		{`import "a"["x"] + 1`, `((import "a"["x"]) + 1)`},
+		{"export let x = 1;", "export let x = 1;"},
+		{"export let [a, b] = pair;", "export let [a, b] = pair;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"import util", "expected next token to be STRING, got IDENT instead"},
+		{"export fn() {}", "expected next token to be LET, got FUNCTION instead"},
+	}
+	for _, tt := range errorTests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+// String的输出可以重新解析为同样的程序
+func TestStringRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let a = 1; a; (a)",
+		"let f = fn(x, [y, ...z], {k: v}) { if (x) { y } else { z }; v }; f(1)[0]",
+		"fn() { }(); [1, 2][0]; -1; !true",
+		"let r = try { throw \"a\\\"b\\n\" } catch (e) { e } finally { 1 }; r?",
+		"let m = macro(a) { quote(unquote(a) + 1) }; m(2)",
+		`export let x = import "lib"["y"]; {"k": 1.50d}`,
+		"if (a) { return 1; } ; b",
+	}
+
+	for _, input := range inputs {
+		p := NewParser(lexer.NewLexer(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		printed := program.String()
+		p = NewParser(lexer.NewLexer(printed))
+		reparsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("cannot parse %q: %v", printed, p.Errors())
+			continue
+		}
+		if reparsed.String() != printed {
+			t.Errorf("round trip changed the program.\nfirst =%q\nsecond=%q", printed, reparsed.String())
+		}
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := "let myFunction = fn() { };"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestMacroLiteralRejectsPatterns(t *testing.T) {
+	l := lexer.NewLexer(`macro([a]) { a }`)
+	p := NewParser(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	if errors[0] != "macro parameter must be an identifier, got [a]" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
+
+func TestUnquotePatternParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let unquote(x) = 1;`, "let unquote(x) = 1;"},
+		{`fn(unquote(x), y) { y }`, "fn(unquote(x), y) { y }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+
+		found := false
+		ast.Inspect(program, func(node ast.Node) bool {
+			if _, ok := node.(*ast.UnquotePattern); ok {
+				found = true
+			}
+			return true
+		})
+		if !found {
+			t.Errorf("no ast.UnquotePattern found in %q", tt.input)
+		}
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // 被标记为尾调用的函数
+	}{
+		{"fn() { a(); b() }", []string{"b"}},
+		{"fn() { a(b()) }", []string{"a"}},
+		{"fn() { a() + b() }", nil},
+		{"fn() { if (c()) { a() } else { b() } }", []string{"a", "b"}},
+		{"fn() { if (x) { a() }; b }", nil},
+		{"fn() { if (x) { return a() }; let y = if (x) { return b() } else { 1 }; c() }", []string{"a", "b", "c"}},
+		{"fn() { return if (x) { a() } else { b() } }", []string{"a", "b"}},
+		// try中的调用和嵌套函数外层的调用不是尾调用
+		{"fn() { try { a() } catch (e) { return b() } finally { c() } }", nil},
+		{"fn() { fn() { a() } }", []string{"a"}},
+		{"fn() { quote(a()) }", nil},
+		{"a(); b()", nil},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var marked []string
+		ast.Inspect(program, func(node ast.Node) bool {
+			if call, ok := node.(*ast.CallExpression); ok && call.Tail {
+				marked = append(marked, call.Function.String())
+			}
+			return true
+		})
+		if fmt.Sprint(marked) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong tail calls in %q. want=%v, got=%v", tt.input, tt.expected, marked)
+		}
+	}
+}
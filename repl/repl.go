@@ -1,10 +1,13 @@
 package repl
 
 import (
+	"Interp/ast"
+	"Interp/compiler"
 	"Interp/evaluator"
 	"Interp/lexer"
 	"Interp/object"
 	"Interp/parser"
+	"Interp/runner"
 	"bufio"
 	"fmt"
 	"io"
@@ -12,9 +15,20 @@ import (
 
 const PROMPT = ">> "
 
+// DISASM 切换是否在求值前输出输入的字节码
+const DISASM = ":disasm"
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	// 导入的模块保存在运行设置中，之后的输入再导入同一个模块时不会重新执行
+	rt := &object.Runtime{Modules: make(map[string]object.Object)}
+	env := object.NewEnvironmentWithRuntime(rt)
+	loader := runner.New(runner.Options{})
+	macroEnv := object.NewEnvironment()
+	resolver := evaluator.NewResolver()
+	// 反汇编时保留全局变量的符号，之后的输入可以按名字引用
+	symbolTable := compiler.NewSymbolTable()
+	disasm := false
 	for {
 		_, err := fmt.Fprintf(out, PROMPT)
 		if err != nil {
@@ -26,16 +40,56 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if line == DISASM {
+			disasm = !disasm
+			state := "off"
+			if disasm {
+				state = "on"
+			}
+			if _, err := io.WriteString(out, "disassembly "+state+"\n"); err != nil {
+				return
+			}
+			continue
+		}
+
 		l := lexer.NewLexer(line)
 		p := parser.NewParser(l)
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printErrors(out, "parser errors", p.Errors())
+			continue
+		}
+
+		// 在求值之前定义并展开宏
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			_, err := io.WriteString(out, " macro error: "+err.Error()+"\n")
+			if err != nil {
+				return
+			}
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		if errors := resolver.Resolve(expanded); len(errors) != 0 {
+			printErrors(out, "resolver errors", errors)
+			continue
+		}
+
+		if disasm {
+			printDisassembly(out, expanded, symbolTable, line)
+		}
+
+		// import的相对路径从当前目录开始查找
+		if err := loader.LoadImports(expanded.(*ast.Program), ".", rt); err != nil {
+			if _, err := io.WriteString(out, " import error: "+err.Error()+"\n"); err != nil {
+				return
+			}
+			continue
+		}
+
+		evaluated := evaluator.Eval(expanded, env)
 		if evaluated != nil {
 			_, err := io.WriteString(out, evaluated.Inspect())
 			if err != nil {
@@ -50,8 +104,18 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	_, err := io.WriteString(out, " parser errors:\n")
+// printDisassembly 编译输入并输出字节码，常量池只包含这次输入的常量
+func printDisassembly(out io.Writer, program ast.Node, symbolTable *compiler.SymbolTable, line string) {
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		_, _ = io.WriteString(out, " compile error: "+err.Error()+"\n")
+		return
+	}
+	_, _ = io.WriteString(out, compiler.Disassemble(comp.Bytecode(), line))
+}
+
+func printErrors(out io.Writer, title string, errors []string) {
+	_, err := io.WriteString(out, " "+title+":\n")
 	if err != nil {
 		return
 	}
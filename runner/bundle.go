@@ -0,0 +1,503 @@
+package runner
+
+import (
+	"Interp/ast"
+	"Interp/evaluator"
+	"Interp/token"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bundle 把脚本和它导入的模块合并为一个不依赖其他文件的程序。
+// 被导入的模块的全局变量加上模块名作为前缀，import替换为导出的变量组成的哈希字面量，没有被用到的导出被删除。
+// 只定义函数和常量的模块放在脚本之前；其他模块包装为函数，在import处调用，与直接运行时一样在第一次导入时执行。
+// 这样的模块只能在函数之外的一个地方导入，否则合并后无法保证它只执行一次，返回错误
+func (r *Runner) Bundle(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	l := newLoader(r, filepath.Dir(abs), r.runtime())
+	if _, err := l.load(abs, path, string(data)); err != nil {
+		return "", err
+	}
+	program, err := newBundler(l.order).bundle()
+	if err != nil {
+		return "", err
+	}
+	return program.String() + "\n", nil
+}
+
+// export 模块导出的一个变量，ident在重命名之后指向新的名字
+type export struct {
+	name  string
+	ident *ast.Identifier
+}
+
+// bundler 合并按照依赖顺序排列的模块，最后一个是入口文件
+type bundler struct {
+	modules []*module
+	used    map[string]bool     // 所有模块中出现过的名字，新名字不能与它们重复
+	exports map[string][]export // 键是模块的绝对路径
+	// wrapped 执行时有副作用或者可能出错的模块包装成的函数名，sites是导入它们的唯一的import，键都是模块的绝对路径
+	wrapped map[string]string
+	sites   map[string]*ast.ImportExpression
+	// partial 值被解构、用字符串字面量索引，或者绑定到只这样索引的变量的import只用到这几个导出，其余的import用到全部导出
+	partial map[*ast.ImportExpression][]string
+}
+
+func newBundler(modules []*module) *bundler {
+	b := &bundler{
+		modules: modules,
+		used:    make(map[string]bool),
+		exports: make(map[string][]export),
+		wrapped: make(map[string]string),
+		sites:   make(map[string]*ast.ImportExpression),
+		partial: make(map[*ast.ImportExpression][]string),
+	}
+	for _, m := range modules {
+		ast.Inspect(m.program, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok {
+				b.used[ident.Value] = true
+			}
+			return true
+		})
+	}
+	return b
+}
+
+func (b *bundler) bundle() (*ast.Program, error) {
+	dependencies, main := b.modules[:len(b.modules)-1], b.modules[len(b.modules)-1]
+	for _, m := range dependencies {
+		for _, ident := range exportedNames(m.program) {
+			b.exports[m.path] = append(b.exports[m.path], export{name: ident.Value, ident: ident})
+		}
+	}
+	hoisted, err := b.findHoisted(dependencies)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range dependencies {
+		b.rename(m)
+	}
+	for _, m := range dependencies {
+		if !hoisted[m.path] {
+			b.wrapped[m.path] = b.unique(identifierPrefix(m.path) + "_module")
+		}
+	}
+
+	b.collectPartial()
+	for _, m := range b.modules {
+		b.replaceImports(m)
+	}
+
+	bundled := &ast.Program{}
+	for _, m := range dependencies {
+		if hoisted[m.path] {
+			bundled.Statements = append(bundled.Statements, m.program.Statements...)
+		} else {
+			bundled.Statements = append(bundled.Statements, b.wrap(m))
+		}
+	}
+	bundled.Statements = append(bundled.Statements, main.program.Statements...)
+	bundled.Statements = dropUnusedExports(bundled.Statements, len(bundled.Statements)-len(main.program.Statements))
+	return bundled, nil
+}
+
+// findHoisted 找出可以放在脚本之前的模块：最外层只有求值没有副作用也不会出错的let，
+// 以及从同样的模块中解构出存在的导出，什么时候执行都得到同样的结果。
+// 其余模块必须只在函数之外的一个地方导入，在那里调用包装成的函数
+func (b *bundler) findHoisted(dependencies []*module) (map[string]bool, error) {
+	hoisted := make(map[string]bool)
+	for _, m := range dependencies {
+		hoisted[m.path] = b.hoistable(m, hoisted)
+	}
+
+	inFunction := make(map[*ast.ImportExpression]bool)
+	for _, m := range b.modules {
+		ast.Inspect(m.program, func(node ast.Node) bool {
+			if fn, ok := node.(*ast.FunctionLiteral); ok {
+				for _, imp := range imports(fn) {
+					inFunction[imp] = true
+				}
+				return false
+			}
+			return true
+		})
+		for _, imp := range imports(m.program) {
+			if hoisted[imp.Resolved] {
+				continue
+			}
+			if inFunction[imp] {
+				return nil, fmt.Errorf("%s: %s: cannot bundle %q, a module with side effects imported inside a function", m.name, imp.Token.Position(), imp.Path)
+			}
+			if _, ok := b.sites[imp.Resolved]; ok {
+				return nil, fmt.Errorf("%s: %s: cannot bundle %q, a module with side effects imported in more than one place", m.name, imp.Token.Position(), imp.Path)
+			}
+			b.sites[imp.Resolved] = imp
+		}
+	}
+
+	// 最外层的return结束模块，包装成函数之后会变成导入的值
+	for _, m := range dependencies {
+		if !hoisted[m.path] && returns(m.program) {
+			return nil, fmt.Errorf("%s: cannot bundle a module with a top-level return", m.name)
+		}
+	}
+	return hoisted, nil
+}
+
+// hoistable 模块的每条语句是否都可以提前执行，hoisted是已经确定可以提前的模块
+func (b *bundler) hoistable(m *module, hoisted map[string]bool) bool {
+	for _, stmt := range m.program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			return false
+		}
+		if imp, ok := let.Value.(*ast.ImportExpression); ok {
+			if !hoisted[imp.Resolved] || !b.destructuresExports(let.Pattern, imp.Resolved) {
+				return false
+			}
+		} else if !isPure(let.Value) || let.Pattern != nil && !matchesShape(let.Pattern, let.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// destructuresExports 用pattern解构模块path导出的哈希表时是否不会出错
+func (b *bundler) destructuresExports(pattern ast.Pattern, path string) bool {
+	switch pattern := pattern.(type) {
+	case nil:
+		return true
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			if _, ok := pair.Value.(*ast.Identifier); !ok || !b.exported(path, pair.Key.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (b *bundler) exported(path, name string) bool {
+	for _, e := range b.exports[path] {
+		if e.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// returns 程序的最外层是否有return语句
+func returns(program *ast.Program) bool {
+	found := false
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.ReturnStatement:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// wrap 把模块包装成函数，函数执行模块的语句，返回导入处用到的导出组成的哈希表
+func (b *bundler) wrap(m *module) ast.Statement {
+	name := b.wrapped[m.path]
+	body := append(m.program.Statements, &ast.ExpressionStatement{Expression: b.exportsHash(b.sites[m.path])})
+	fn := &ast.FunctionLiteral{
+		Token: token.Token{Type: token.FUNCTION, Literal: "fn"},
+		Body:  &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: dropUnusedExports(body, len(body)-1)},
+		Name:  name,
+	}
+	return &ast.LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let"},
+		Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name},
+		Value: fn,
+	}
+}
+
+// rename 给模块的全局变量加上模块名作为前缀，入口文件的变量保持原来的名字
+func (b *bundler) rename(m *module) {
+	resolver := evaluator.NewResolver()
+	resolver.Resolve(m.program)
+
+	prefix := identifierPrefix(m.path)
+	names := make(map[string]string)
+	for _, ident := range resolver.GlobalIdentifiers() {
+		name, ok := names[ident.Value]
+		if !ok {
+			name = b.unique(prefix + "_" + ident.Value)
+			names[ident.Value] = name
+		}
+		ident.Value = name
+	}
+}
+
+// unique 在名字后面追加下划线，直到它不与任何已有的名字和内置函数重复。标识符中不能有数字
+func (b *bundler) unique(name string) string {
+	for {
+		if _, builtin := evaluator.LookupBuiltin(name); !b.used[name] && !builtin {
+			b.used[name] = true
+			return name
+		}
+		name += "_"
+	}
+}
+
+// identifierPrefix 由模块的文件名得到变量名的前缀，标识符中不允许的字符替换为下划线
+func identifierPrefix(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, base)
+}
+
+// collectPartial 找出只用到部分导出的import
+func (b *bundler) collectPartial() {
+	for _, m := range b.modules {
+		ast.Inspect(m.program, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.LetStatement:
+				imp, ok := node.Value.(*ast.ImportExpression)
+				pattern, isHash := node.Pattern.(*ast.HashPattern)
+				if ok && isHash {
+					keys := []string{}
+					for _, pair := range pattern.Pairs {
+						keys = append(keys, pair.Key.Value)
+					}
+					b.partial[imp] = keys
+				}
+			case *ast.IndexExpression:
+				imp, ok := node.Left.(*ast.ImportExpression)
+				key, isString := node.Index.(*ast.StringLiteral)
+				if ok && isString {
+					b.partial[imp] = []string{key.Value}
+				}
+			}
+			return true
+		})
+		b.collectBoundImports(m.program)
+	}
+}
+
+// collectBoundImports 处理let u = import "m"绑定的import。u只用字符串字面量索引时只用到这些导出，
+// 以其他任何方式出现时用到全部导出。按名字判断，同名的其他变量只会使保留的导出更多
+func (b *bundler) collectBoundImports(program *ast.Program) {
+	bound := make(map[string][]*ast.ImportExpression)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if let, ok := node.(*ast.LetStatement); ok && let.Name != nil {
+			if imp, ok := let.Value.(*ast.ImportExpression); ok {
+				bound[let.Name.Value] = append(bound[let.Name.Value], imp)
+			}
+		}
+		return true
+	})
+	if len(bound) == 0 {
+		return
+	}
+
+	keys := make(map[string][]string)
+	indexed := make(map[*ast.Identifier]bool)
+	declared := make(map[*ast.Identifier]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			if node.Name != nil {
+				declared[node.Name] = true
+			}
+		case *ast.IndexExpression:
+			ident, ok := node.Left.(*ast.Identifier)
+			key, isString := node.Index.(*ast.StringLiteral)
+			if ok && isString && bound[ident.Value] != nil {
+				keys[ident.Value] = append(keys[ident.Value], key.Value)
+				indexed[ident] = true
+			}
+		}
+		return true
+	})
+
+	escaped := make(map[string]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok && !indexed[ident] && !declared[ident] {
+			escaped[ident.Value] = true
+		}
+		return true
+	})
+
+	for name, imps := range bound {
+		if escaped[name] {
+			continue
+		}
+		for _, imp := range imps {
+			b.partial[imp] = append([]string{}, keys[name]...)
+		}
+	}
+}
+
+// replaceImports 把import替换为由用到的导出组成的哈希字面量，包装成函数的模块替换为对函数的调用
+func (b *bundler) replaceImports(m *module) {
+	replacements := make(map[ast.Node]bool)
+	for _, imp := range imports(m.program) {
+		replacements[imp] = true
+	}
+	ast.Modify(m.program, func(node ast.Node) ast.Node {
+		imp, ok := node.(*ast.ImportExpression)
+		if !ok || !replacements[imp] {
+			return node
+		}
+		if name, ok := b.wrapped[imp.Resolved]; ok {
+			return &ast.CallExpression{
+				Token:    token.Token{Type: token.LPAREN, Literal: "(", Line: imp.Token.Line, Column: imp.Token.Column},
+				Function: &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name},
+			}
+		}
+		return b.exportsHash(imp)
+	})
+}
+
+// exportsHash import用到的导出组成的哈希字面量
+func (b *bundler) exportsHash(imp *ast.ImportExpression) *ast.HashLiteral {
+	keys, partial := b.partial[imp]
+	hash := &ast.HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{", Line: imp.Token.Line, Column: imp.Token.Column}}
+	for _, e := range b.exports[imp.Resolved] {
+		if !partial || contains(keys, e.name) {
+			hash.Pairs = append(hash.Pairs, &ast.HashPair{
+				Key:   &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: e.name}, Value: e.name},
+				Value: &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: e.ident.Value}, Value: e.ident.Value},
+			})
+		}
+	}
+	return hash
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dropUnusedExports 删除没有被其他语句引用的导出，其余的export改为普通的let。
+// 只删除求值没有副作用的导出，删除一个函数可能使其他导出也不再被引用，所以重复直到没有变化。
+// 从下标main开始的语句属于入口文件或者是包装的函数返回的值，保持不变
+func dropUnusedExports(statements []ast.Statement, main int) []ast.Statement {
+	for {
+		references := make(map[string]int)
+		for _, stmt := range statements {
+			for name, n := range countReferences(stmt) {
+				references[name] += n
+			}
+		}
+		var kept []ast.Statement
+		for i, stmt := range statements {
+			export, ok := stmt.(*ast.ExportStatement)
+			if ok && i < main && isPure(export.Statement.Value) && unreferenced(export.Statement, references) {
+				continue
+			}
+			kept = append(kept, stmt)
+		}
+
+		dropped := len(statements) - len(kept)
+		statements, main = kept, main-dropped
+		if dropped == 0 {
+			break
+		}
+	}
+
+	for i, stmt := range statements[:main] {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			statements[i] = export.Statement
+		}
+	}
+	return statements
+}
+
+// unreferenced 语句绑定的名字是否都只在语句自己之中出现，函数引用自己不算被引用。
+// 解构的模式必须与值的形状一致，删除语句不会去掉一个解构错误
+func unreferenced(stmt *ast.LetStatement, references map[string]int) bool {
+	var names []*ast.Identifier
+	if stmt.Name != nil {
+		names = []*ast.Identifier{stmt.Name}
+	} else if matchesShape(stmt.Pattern, stmt.Value) {
+		names = appendPatternNames(nil, stmt.Pattern)
+	} else {
+		return false
+	}
+
+	own := countReferences(stmt)
+	for _, name := range names {
+		if references[name.Value] != own[name.Value] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesShape 没有副作用的值能否按照模式解构而不出错
+func matchesShape(pattern ast.Pattern, value ast.Expression) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.ArrayPattern:
+		array, ok := value.(*ast.ArrayLiteral)
+		if !ok || len(array.Elements) < len(pattern.Elements) || pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for i, element := range pattern.Elements {
+			if !matchesShape(element, array.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	// 哈希字面量不是没有副作用的值，模式中的unquote在求值时报错
+	return false
+}
+
+// countReferences 统计node中每个名字出现的次数
+func countReferences(node ast.Node) map[string]int {
+	references := make(map[string]int)
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			references[ident.Value]++
+		}
+		return true
+	})
+	return references
+}
+
+// isPure 表达式求值时是否没有副作用也不会出错
+func isPure(node ast.Expression) bool {
+	switch node := node.(type) {
+	case *ast.FunctionLiteral, *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.DecimalLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			if !isPure(element) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package runner
+
+import (
+	"Interp/ast"
+	"Interp/compiler"
+	"Interp/evaluator"
+	"Interp/object"
+	"Interp/vm"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// module 一个已经解析好的模块文件
+type module struct {
+	path    string // 绝对路径
+	name    string // 错误信息中显示的路径
+	program *ast.Program
+}
+
+// loader 在执行之前加载程序直接或间接导入的所有模块，每个文件只解析一次
+type loader struct {
+	runner  *Runner
+	dir     string             // 入口文件所在的目录，错误信息中的路径相对于它显示
+	loaded  map[string]bool    // 已经执行过的模块，REPL中之前的输入导入过的模块不再加载
+	modules map[string]*module // 键是绝对路径
+	order   []*module          // 被依赖的模块在前，导入它们的模块在后
+	loading []string           // 正在加载的模块，出现循环时从这里取出循环的链
+}
+
+func newLoader(r *Runner, dir string, rt *object.Runtime) *loader {
+	loaded := make(map[string]bool, len(rt.Modules))
+	for path := range rt.Modules {
+		loaded[path] = true
+	}
+	return &loader{runner: r, dir: dir, loaded: loaded, modules: make(map[string]*module)}
+}
+
+// load 解析一个模块和它导入的模块，加载完成后把它加入order
+func (l *loader) load(path, name, source string) (*module, error) {
+	l.loading = append(l.loading, path)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	program, err := l.runner.parse(name, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.resolveImports(program, filepath.Dir(path), name); err != nil {
+		return nil, err
+	}
+	m := &module{path: path, name: name, program: program}
+	l.modules[path] = m
+	l.order = append(l.order, m)
+	return m, nil
+}
+
+// resolveImports 找到program中每个import对应的文件，记录在Resolved中，并加载还没有加载的模块
+func (l *loader) resolveImports(program *ast.Program, dir, name string) error {
+	for _, node := range imports(program) {
+		path, ok := l.find(dir, node.Path)
+		if !ok {
+			return fmt.Errorf("%s: %s: cannot find module %q", name, node.Token.Position(), node.Path)
+		}
+		node.Resolved = path
+		if err := l.require(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// require 加载还没有加载过的模块。模块正在加载时说明出现了循环导入
+func (l *loader) require(path string) error {
+	for i, loading := range l.loading {
+		if loading == path {
+			var chain []string
+			for _, p := range append(l.loading[i:], path) {
+				chain = append(chain, l.display(p))
+			}
+			return fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if _, ok := l.modules[path]; ok || l.loaded[path] {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = l.load(path, l.display(path), string(data))
+	return err
+}
+
+// find 先相对于导入它的文件所在的目录查找模块，再依次在SearchPath中查找。路径没有扩展名时也尝试加上.mk
+func (l *loader) find(dir, path string) (string, bool) {
+	candidates := []string{path}
+	if filepath.Ext(path) == "" {
+		candidates = append(candidates, path+".mk")
+	}
+	dirs := append([]string{dir}, l.runner.options.SearchPath...)
+	if filepath.IsAbs(path) {
+		dirs = []string{""}
+	}
+
+	for _, d := range dirs {
+		for _, candidate := range candidates {
+			full := filepath.Join(d, candidate)
+			if info, err := os.Stat(full); err != nil || info.IsDir() {
+				continue
+			}
+			if abs, err := filepath.Abs(full); err == nil {
+				return abs, true
+			}
+		}
+	}
+	return "", false
+}
+
+// display 返回相对于入口文件所在目录的路径，在目录之外的文件显示绝对路径
+func (l *loader) display(path string) string {
+	rel, err := filepath.Rel(l.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// imports 收集node中导入模块文件的import表达式。原生模块不需要加载，被quote的代码是数据，其中的import也不加载
+func imports(node ast.Node) []*ast.ImportExpression {
+	var found []*ast.ImportExpression
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ImportExpression:
+			if _, native := evaluator.NativeModule(node.Path); !native {
+				found = append(found, node)
+			}
+		case *ast.CallExpression:
+			if node.Function.TokenLiteral() == "quote" {
+				return false
+			}
+		case *ast.MacroLiteral:
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// exportedNames 模块最外层export语句绑定的变量
+func exportedNames(program *ast.Program) []*ast.Identifier {
+	var names []*ast.Identifier
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		if export.Statement.Name != nil {
+			names = append(names, export.Statement.Name)
+		} else {
+			names = appendPatternNames(names, export.Statement.Pattern)
+		}
+	}
+	return names
+}
+
+func appendPatternNames(names []*ast.Identifier, pattern ast.Pattern) []*ast.Identifier {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		names = append(names, pattern)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			names = appendPatternNames(names, element)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			names = appendPatternNames(names, pair.Value)
+		}
+	}
+	return names
+}
+
+// execution 一次运行中所有模块共享的状态。虚拟机中的函数按下标读取常量和全局变量，
+// 导出的函数在导入它的模块中调用时也要能读到，所以各个模块编译时共享常量池，并使用同一组全局变量中不重叠的位置
+type execution struct {
+	runner     *Runner
+	rt         *object.Runtime
+	constants  []object.Object
+	globals    []object.Object
+	numGlobals int
+}
+
+func (r *Runner) newExecution(rt *object.Runtime) *execution {
+	return &execution{runner: r, rt: rt, constants: []object.Object{}}
+}
+
+// prepare 为用选择的引擎执行一个模块做准备，返回执行它的函数。执行的函数返回结果和读取模块全局变量的函数。
+// 虚拟机在这里编译模块，所以编译错误在任何模块执行之前报告
+func (e *execution) prepare(m *module) (func() (object.Object, func(*ast.Identifier) (object.Object, bool), error), error) {
+	if e.runner.options.Engine == Evaluator {
+		return func() (object.Object, func(*ast.Identifier) (object.Object, bool), error) {
+			env := object.NewEnvironmentWithRuntime(e.rt)
+			result := evaluator.Eval(m.program, env)
+			return result, func(ident *ast.Identifier) (object.Object, bool) {
+				return env.GetAt(0, ident.Slot)
+			}, nil
+		}, nil
+	}
+
+	comp := compiler.NewWithState(compiler.NewSymbolTableFrom(e.numGlobals), e.constants)
+	if err := comp.Compile(m.program); err != nil {
+		return nil, fmt.Errorf("%s: compile error: %w", m.name, err)
+	}
+	bytecode := comp.Bytecode()
+	e.constants, e.numGlobals = bytecode.Constants, len(bytecode.GlobalNames)
+	if e.globals == nil {
+		e.globals = make([]object.Object, vm.GlobalsSize)
+	}
+
+	return func() (object.Object, func(*ast.Identifier) (object.Object, bool), error) {
+		machine := vm.NewWithGlobalsAndRuntime(bytecode, e.globals, e.rt)
+		if err := machine.Run(); err != nil {
+			return nil, nil, err
+		}
+		return machine.LastPoppedStackElem(), func(ident *ast.Identifier) (object.Object, bool) {
+			return machine.Global(ident.Value)
+		}, nil
+	}, nil
+}
+
+// prepareModules 准备加载的模块，并通过rt.LoadModule在第一次导入时执行它们。
+// 执行成功的模块把导出的哈希表保存在rt.Modules中；执行时的错误作为import的结果返回，调用栈的最外层记录出错的模块
+func (e *execution) prepareModules(modules []*module) error {
+	pending := make(map[string]func() object.Object, len(modules))
+	for _, m := range modules {
+		m := m
+		run, err := e.prepare(m)
+		if err != nil {
+			return err
+		}
+		pending[m.path] = func() object.Object {
+			result, global, err := run()
+			if err != nil {
+				return &object.Error{Kind: object.GENERIC_ERROR, Message: err.Error(), Stack: []string{"module " + m.name}}
+			}
+			if errObj, ok := result.(*object.Error); ok {
+				errObj.Stack = append(errObj.Stack, "module "+m.name)
+				return errObj
+			}
+
+			exports := object.NewHash()
+			for _, ident := range exportedNames(m.program) {
+				value, ok := global(ident)
+				if !ok {
+					value = evaluator.NULL
+				}
+				exports.Set(&object.String{Value: ident.Value}, value)
+			}
+			e.rt.Modules[m.path] = exports
+			return exports
+		}
+	}
+
+	// REPL中之前的输入准备的模块交给之前的函数执行
+	previous := e.rt.LoadModule
+	e.rt.LoadModule = func(path string) object.Object {
+		if load, ok := pending[path]; ok {
+			return load()
+		}
+		if previous != nil {
+			return previous(path)
+		}
+		return nil
+	}
+	return nil
+}
+
+// LoadImports 加载program导入的模块，它们在rt中第一次被导入时执行，相对路径从dir开始查找。
+// 供REPL这样逐段执行代码的宿主使用，rt中已经有的模块不会再执行
+func (r *Runner) LoadImports(program *ast.Program, dir string, rt *object.Runtime) error {
+	if rt.Modules == nil {
+		rt.Modules = make(map[string]object.Object)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	l := newLoader(r, abs, rt)
+	l.loading = append(l.loading, filepath.Join(abs, "<input>"))
+	if err := l.resolveImports(program, abs, "<input>"); err != nil {
+		return err
+	}
+	return r.newExecution(rt).prepareModules(l.order)
+}
@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"Interp/ast"
+	"Interp/compiler"
+	"Interp/evaluator"
+	"Interp/lexer"
+	"Interp/object"
+	"Interp/optimizer"
+	"Interp/parser"
+	"Interp/vm"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Engine 执行脚本的方式
+type Engine int
+
+const (
+	Evaluator Engine = iota // Evaluator 遍历语法树求值
+	VM                      // VM 编译为字节码后在虚拟机中执行
+)
+
+// Options 控制脚本的执行方式，零值表示使用解释器
+type Options struct {
+	Engine   Engine
+	Optimize bool // 执行和编译之前用optimizer化简语法树
+	// IntegerMode 整数运算溢出时的处理方式，默认改用BigInt
+	IntegerMode object.IntegerMode
+	// Truthiness 条件和!判断真假的规则，默认只有false和null为假
+	Truthiness object.Truthiness
+	// DecimalPrecision 小数运算保留的有效数字，0表示默认的28位
+	DecimalPrecision int
+	DecimalRounding  object.DecimalRounding
+	// SearchPath 相对于导入它的文件找不到模块时，依次在这些目录中查找
+	SearchPath []string
+	// Files 授予脚本的文件权限，nil表示不允许file模块访问任何文件
+	Files *object.FileAccess
+	// Exec 授予脚本执行外部程序的权限，nil表示禁用exec
+	Exec *object.ExecAccess
+	// Clock time模块读取当前时间的时钟，nil表示使用操作系统的时间
+	Clock object.Clock
+	// Seed random模块的种子，每次运行用它创建新的随机数源，同一个Runner的多次运行得到同样的结果。
+	// nil表示每次运行随机地初始化
+	Seed *int64
+	// Deterministic 禁止读取操作系统的时间和使用没有设置种子的随机数，脚本使用它们时报NondeterminismError
+	Deterministic bool
+}
+
+// Runner 是嵌入解释器的入口：解析源代码、展开宏，再按照Options执行。
+// 返回的error表示程序没有运行起来，例如语法错误；运行时的错误作为*object.Error结果返回
+type Runner struct {
+	options Options
+}
+
+func New(options Options) *Runner {
+	return &Runner{options: options}
+}
+
+// Run 执行源代码，name用于错误信息，源代码中import的相对路径从name所在的目录开始查找。
+// 导入的模块在执行到第一个导入它的import时执行，每个模块只执行一次
+func (r *Runner) Run(name, source string) (object.Object, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	rt := r.runtime()
+	l := newLoader(r, filepath.Dir(path), rt)
+	main, err := l.load(path, name, source)
+	if err != nil {
+		return nil, err
+	}
+
+	e := r.newExecution(rt)
+	if err := e.prepareModules(l.order[:len(l.order)-1]); err != nil {
+		return nil, err
+	}
+	run, err := e.prepare(main)
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := run()
+	return result, err
+}
+
+// RunFile 执行脚本文件。预编译的文件总是在虚拟机中执行
+func (r *Runner) RunFile(path string) (object.Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if compiler.IsCompiled(data) {
+		bytecode := &compiler.Bytecode{}
+		if err := bytecode.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return r.RunBytecode(bytecode)
+	}
+	return r.Run(path, string(data))
+}
+
+// RunBytecode 在虚拟机中执行编译好的字节码
+func (r *Runner) RunBytecode(bytecode *compiler.Bytecode) (object.Object, error) {
+	machine := vm.NewWithRuntime(bytecode, r.runtime())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+// runtime 根据Options创建一次运行的设置
+func (r *Runner) runtime() *object.Runtime {
+	var random *rand.Rand
+	if r.options.Seed != nil {
+		random = rand.New(rand.NewSource(*r.options.Seed))
+	}
+	return &object.Runtime{
+		IntegerMode:      r.options.IntegerMode,
+		Truthiness:       r.options.Truthiness,
+		DecimalPrecision: r.options.DecimalPrecision,
+		DecimalRounding:  r.options.DecimalRounding,
+		Modules:          make(map[string]object.Object),
+		Files:            r.options.Files,
+		Exec:             r.options.Exec,
+		Clock:            r.options.Clock,
+		Random:           random,
+		Deterministic:    r.options.Deterministic,
+	}
+}
+
+// Compile 将源代码编译为字节码。字节码中不包含导入的模块，导入模块的脚本不能预编译
+func (r *Runner) Compile(name, source string) (*compiler.Bytecode, error) {
+	program, err := r.parse(name, source)
+	if err != nil {
+		return nil, err
+	}
+	if found := imports(program); len(found) != 0 {
+		return nil, fmt.Errorf("%s: compile error: %s: imports are not supported in compiled scripts, bundle the script first", name, found[0].Token.Position())
+	}
+	return r.compile(name, program)
+}
+
+// DisassembleFile 反汇编脚本文件。源代码文件先编译，并用源代码标注指令；预编译的文件只能标注行号
+func (r *Runner) DisassembleFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if compiler.IsCompiled(data) {
+		bytecode := &compiler.Bytecode{}
+		if err := bytecode.UnmarshalBinary(data); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		return compiler.Disassemble(bytecode, ""), nil
+	}
+
+	bytecode, err := r.Compile(path, string(data))
+	if err != nil {
+		return "", err
+	}
+	return compiler.Disassemble(bytecode, string(data)), nil
+}
+
+// CompileFile 编译脚本文件
+func (r *Runner) CompileFile(path string) (*compiler.Bytecode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Compile(path, string(data))
+}
+
+// parse 解析源代码，展开其中的宏并解析变量，需要时再化简语法树
+func (r *Runner) parse(name, source string) (*ast.Program, error) {
+	p := parser.NewParser(lexer.NewLexer(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: parser errors:\n\t%s", name, strings.Join(p.Errors(), "\n\t"))
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%s: macro error: %w", name, err)
+	}
+
+	// 在执行之前报告未声明的变量，两种引擎都会检查
+	program = expanded.(*ast.Program)
+	if errors := evaluator.NewResolver().Resolve(program); len(errors) != 0 {
+		return nil, fmt.Errorf("%s: resolver errors:\n\t%s", name, strings.Join(errors, "\n\t"))
+	}
+	if r.options.Optimize {
+		program = optimizer.Optimize(program)
+	}
+	return program, nil
+}
+
+func (r *Runner) compile(name string, program *ast.Program) (*compiler.Bytecode, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("%s: compile error: %w", name, err)
+	}
+	return comp.Bytecode(), nil
+}
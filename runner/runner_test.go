@@ -0,0 +1,671 @@
+package runner
+
+import (
+	"Interp/object"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const script = `let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+unless(false, fib(10), 0);`
+
+func TestEnginesAgree(t *testing.T) {
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine}).Run("test.mk", script)
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "55" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+}
+
+func TestOptimizeOption(t *testing.T) {
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine, Optimize: true}).Run("test.mk", script)
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "55" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+
+	// 优化之后常量表达式编译为一个常量
+	for _, optimize := range []bool{false, true} {
+		bytecode, err := New(Options{Optimize: optimize}).Compile("day.mk", "60 * 60 * 24")
+		if err != nil {
+			t.Fatalf("compile error: %s", err)
+		}
+		if folded := len(bytecode.Constants) == 1; folded != optimize {
+			t.Errorf("Optimize=%t: wrong constants %v", optimize, bytecode.Constants)
+		}
+	}
+}
+
+func TestIntegerModeOption(t *testing.T) {
+	tests := []struct {
+		mode     object.IntegerMode
+		expected string
+	}{
+		{object.IntegerPromote, "9223372036854775808"},
+		{object.IntegerWrap, "-9223372036854775808"},
+		{object.IntegerChecked, "ERROR: integer overflow: 9223372036854775807 + 1 (at 1:21)"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Engine{Evaluator, VM} {
+			result, err := New(Options{Engine: engine, IntegerMode: tt.mode}).Run("test.mk", "9223372036854775807 + 1")
+			if err != nil {
+				t.Fatalf("engine %d: unexpected error: %s", engine, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("engine %d, mode %d: wrong result. expected=%s, got=%s", engine, tt.mode, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestDecimalOptions(t *testing.T) {
+	options := Options{DecimalPrecision: 3, DecimalRounding: object.RoundDown}
+	for _, engine := range []Engine{Evaluator, VM} {
+		options.Engine = engine
+		result, err := New(options).Run("test.mk", "2d / 3")
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "0.666" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+}
+
+func TestTruthinessOption(t *testing.T) {
+	source := "let n = 0;\nif (n) { 1 } else { 2 }"
+	tests := []struct {
+		truthiness object.Truthiness
+		expected   string
+	}{
+		{object.TruthinessDefault, "1"},
+		{object.TruthinessFalsyEmpty, "2"},
+		{object.TruthinessStrict, "ERROR: condition must be BOOLEAN, got INTEGER (at 2:1)"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Engine{Evaluator, VM} {
+			result, err := New(Options{Engine: engine, Truthiness: tt.truthiness}).Run("test.mk", source)
+			if err != nil {
+				t.Fatalf("engine %d: unexpected error: %s", engine, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("engine %d, truthiness %d: wrong result. expected=%s, got=%s", engine, tt.truthiness, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestFilesOption(t *testing.T) {
+	dir := t.TempDir()
+	source := fmt.Sprintf(`let {write_file, read_file} = import "file"; write_file(%q, "é"); read_file(%q)`,
+		filepath.Join(dir, "out.txt"), filepath.Join(dir, "out.txt"))
+	tests := []struct {
+		files    *object.FileAccess
+		expected string
+	}{
+		{&object.FileAccess{Roots: []string{dir}}, "é"},
+		{&object.FileAccess{Roots: []string{dir}, ReadOnly: true}, "ERROR: permission denied: `write_file` cannot modify " + filepath.Join(dir, "out.txt") + ", file access is read-only (at 1:56)"},
+		{nil, "ERROR: permission denied: `write_file` needs file access, which is not enabled (at 1:56)"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Engine{Evaluator, VM} {
+			result, err := New(Options{Engine: engine, Files: tt.files}).Run("test.mk", source)
+			if err != nil {
+				t.Fatalf("engine %d: unexpected error: %s", engine, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("engine %d: wrong result. expected=%s, got=%s", engine, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestExecOption(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	source := `exec("sh", {"args": ["-c", "echo ok"]})["stdout"]`
+	tests := []struct {
+		access   *object.ExecAccess
+		expected string
+	}{
+		{&object.ExecAccess{}, "ok\n"},
+		{&object.ExecAccess{Allowed: []string{"sh"}}, "ok\n"},
+		{&object.ExecAccess{Allowed: []string{"true"}}, "ERROR: permission denied: `exec` is not allowed to run sh (at 1:5)"},
+		{nil, "ERROR: permission denied: `exec` needs permission to run programs, which is not enabled (at 1:5)"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []Engine{Evaluator, VM} {
+			result, err := New(Options{Engine: engine, Exec: tt.access}).Run("test.mk", source)
+			if err != nil {
+				t.Fatalf("engine %d: unexpected error: %s", engine, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("engine %d: wrong result. expected=%q, got=%q", engine, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestDeterministicOption(t *testing.T) {
+	source := `let {int, shuffle} = import "random"; [int(1, 1000), shuffle([1, 2, 3, 4, 5])]`
+	var results []string
+	seed := int64(7)
+	for _, engine := range []Engine{Evaluator, VM} {
+		// 同一个Runner的每次运行都从种子重新开始
+		r := New(Options{Engine: engine, Seed: &seed, Deterministic: true})
+		for i := 0; i < 2; i++ {
+			result, err := r.Run("test.mk", source)
+			if err != nil {
+				t.Fatalf("engine %d: unexpected error: %s", engine, err)
+			}
+			results = append(results, result.Inspect())
+		}
+	}
+	for _, result := range results {
+		if result != results[0] || strings.HasPrefix(result, "ERROR") {
+			t.Fatalf("seeded runs differ: %v", results)
+		}
+	}
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine, Deterministic: true}).Run("test.mk", source)
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		errObj, ok := result.(*object.Error)
+		if !ok || errObj.Kind != object.NONDETERMINISM_ERROR {
+			t.Errorf("engine %d: expected a NondeterminismError, got %s", engine, result.Inspect())
+		}
+	}
+}
+
+func TestRunCompiledFile(t *testing.T) {
+	dir := t.TempDir()
+	r := New(Options{})
+
+	bytecode, err := r.Compile("test.mk", script)
+	if err != nil {
+		t.Fatalf("compile error: %s", err)
+	}
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+	path := filepath.Join(dir, "test.mkc")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 即使选择了解释器，预编译的文件也在虚拟机中执行
+	result, err := r.RunFile(path)
+	if err != nil {
+		t.Fatalf("RunFile failed: %s", err)
+	}
+	if result.Inspect() != "55" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	data[4] = 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RunFile(path); err == nil {
+		t.Errorf("expected an error for an incompatible compiled file")
+	}
+}
+
+func TestDisassembleFile(t *testing.T) {
+	dir := t.TempDir()
+	r := New(Options{})
+
+	source := filepath.Join(dir, "test.mk")
+	if err := os.WriteFile(source, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bytecode, err := r.CompileFile(source)
+	if err != nil {
+		t.Fatalf("compile error: %s", err)
+	}
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+	compiled := filepath.Join(dir, "test.mkc")
+	if err := os.WriteFile(compiled, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 预编译的文件没有源代码，只能标注行号
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{source, "; 2: let fib = fn(n) {"},
+		{compiled, "; line 2"},
+	}
+	for _, tt := range tests {
+		listing, err := r.DisassembleFile(tt.path)
+		if err != nil {
+			t.Fatalf("DisassembleFile(%s) failed: %s", tt.path, err)
+		}
+		if !strings.Contains(listing, "== <main> ==") || !strings.Contains(listing, tt.expected) {
+			t.Errorf("DisassembleFile(%s) does not contain %q:\n%s", tt.path, tt.expected, listing)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	r := New(Options{Engine: VM})
+
+	if _, err := r.Run("bad.mk", "let = 1;"); err == nil {
+		t.Errorf("expected a parser error")
+	}
+	if _, err := r.Run("bad.mk", "quote(1)"); err == nil || err.Error() != "bad.mk: compile error: quote is not supported by the compiler" {
+		t.Errorf("expected a compile error. got=%v", err)
+	}
+	// 未声明的变量在执行之前报告
+	if _, err := r.Run("bad.mk", "let f = fn() { g() };\nf();"); err == nil || err.Error() != "bad.mk: resolver errors:\n\t1:16: undeclared variable g" {
+		t.Errorf("expected a resolver error. got=%v", err)
+	}
+
+	result, err := r.Run("bad.mk", "let f = fn() { 1 + true };\nf();")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected a runtime error. got=%T (%+v)", result, result)
+	}
+	if errObj.Position != "1:18" || len(errObj.Stack) != 1 || errObj.Stack[0] != "f (2:2)" {
+		t.Errorf("wrong error location. got=%s %v", errObj.Position, errObj.Stack)
+	}
+}
+
+// writeModules 在dir中创建测试用的模块文件
+func writeModules(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	// 导出的函数在其他模块中调用时仍然读取自己模块的常量和变量
+	writeModules(t, dir, map[string]string{
+		"main.mk": `let util = import "./lib/util";
+let {square} = import "lib/util.mk";
+let {twice} = import "helpers";
+[util["offset"], square(3), twice(square, 2), util["greet"]("x")]`,
+		"lib/util.mk": `let {base} = import "../shared.mk";
+export let offset = base + 1;
+export let square = fn(x) { x * x };
+export let greet = fn(name) { "hi " + name + suffix };
+let suffix = "!";`,
+		"shared.mk":         "export let [base, other] = [10, 20];",
+		"vendor/helpers.mk": `export let twice = fn(f, x) { f(f(x)) };`,
+	})
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		r := New(Options{Engine: engine, SearchPath: []string{filepath.Join(dir, "vendor")}})
+		result, err := r.RunFile(filepath.Join(dir, "main.mk"))
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "[11, 9, 16, hi x!]" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+
+		// 只导出export的变量，每个模块只执行一次，第一次导入时才执行
+		rt := r.runtime()
+		program, err := r.parse("<input>", `import "lib/util"; import "shared"; import "lib/util.mk"`)
+		if err != nil {
+			t.Fatalf("engine %d: parse error: %s", engine, err)
+		}
+		if err := r.LoadImports(program, dir, rt); err != nil {
+			t.Fatalf("engine %d: LoadImports failed: %s", engine, err)
+		}
+		if len(rt.Modules) != 0 {
+			t.Errorf("engine %d: modules ran before they were imported. got=%v", engine, rt.Modules)
+		}
+		rt.LoadModule(filepath.Join(dir, "lib", "util.mk"))
+		if len(rt.Modules) != 2 {
+			t.Errorf("engine %d: expected 2 loaded modules. got=%v", engine, rt.Modules)
+		}
+		util, ok := rt.Modules[filepath.Join(dir, "lib", "util.mk")].(*object.Hash)
+		if !ok || len(util.Keys) != 3 {
+			t.Fatalf("engine %d: wrong exports. got=%v", engine, rt.Modules)
+		}
+		if offset, ok := util.Get(&object.String{Value: "offset"}); !ok || offset.Inspect() != "11" {
+			t.Errorf("engine %d: wrong offset. got=%v", engine, offset)
+		}
+	}
+}
+
+func TestImportOrder(t *testing.T) {
+	dir := t.TempDir()
+	// 模块在第一次执行到导入它的import时执行，没有调用的函数中导入的模块不执行
+	writeModules(t, dir, map[string]string{
+		"main.mk": `let log = import "log";
+log["add"]("start");
+let a = import "a";
+let unused = fn() { import "b" };
+log["add"]("end");
+let again = import "a";
+[log["read"](), a == again]`,
+		"log.mk": fmt.Sprintf(`let {read_file, write_file} = import "file";
+let path = %q;
+write_file(path, "");
+export let add = fn(line) { write_file(path, read_file(path) + line + " ") };
+export let read = fn() { read_file(path) };`, filepath.Join(dir, "log.txt")),
+		"a.mk": `let log = import "log"; log["add"]("a"); export let x = 1;`,
+		"b.mk": `let log = import "log"; log["add"]("b");`,
+	})
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		r := New(Options{Engine: engine, Files: &object.FileAccess{Roots: []string{dir}}})
+		result, err := r.RunFile(filepath.Join(dir, "main.mk"))
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "[start a end , true]" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+}
+
+func TestImportedFunctionsCompareGlobals(t *testing.T) {
+	dir := t.TempDir()
+	// 源代码相同的两个函数读取各自模块的全局变量，值不同时不相等
+	writeModules(t, dir, map[string]string{
+		"main.mk": `let a = import "ea"; let b = import "eb"; let c = import "ec";
+[a["f"] == b["f"], a["f"] == c["f"], a["f"](), b["f"]()]`,
+		"ea.mk": "let x = 1; export let f = fn() { x };",
+		"eb.mk": "let x = 2; export let f = fn() { x };",
+		"ec.mk": "let x = 1; export let f = fn() { x };",
+	})
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine}).RunFile(filepath.Join(dir, "main.mk"))
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "[false, true, 1, 2]" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"a.mk":       `import "b.mk";`,
+		"b.mk":       `import "sub/c.mk";`,
+		"sub/c.mk":   `import "../a.mk";`,
+		"self.mk":    `import "./self";`,
+		"fail.mk":    "export let x = 1;\nlet y = x + true;",
+		"use.mk":     `import "fail.mk"["x"]`,
+		"compile.mk": `let {x} = import "a.mk"; x`,
+	})
+	r := New(Options{})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"a.mk", "import cycle: a.mk -> b.mk -> " + filepath.Join("sub", "c.mk") + " -> a.mk"},
+		{"self.mk", "import cycle: self.mk -> self.mk"},
+	}
+	for _, tt := range tests {
+		if _, err := r.RunFile(filepath.Join(dir, tt.file)); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.file, tt.expected, err)
+		}
+	}
+
+	if _, err := r.Run(filepath.Join(dir, "main.mk"), "1;\nimport \"missing\""); err == nil || !strings.HasSuffix(err.Error(), `main.mk: 2:1: cannot find module "missing"`) {
+		t.Errorf("expected a missing module error. got=%v", err)
+	}
+
+	// 模块中未捕获的错误作为结果返回，调用栈记录出错的模块
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine}).RunFile(filepath.Join(dir, "use.mk"))
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		errObj, ok := result.(*object.Error)
+		if !ok || errObj.Position != "2:11" || len(errObj.Stack) != 1 || errObj.Stack[0] != "module fail.mk" {
+			t.Errorf("engine %d: wrong error. got=%+v", engine, result)
+		}
+	}
+
+	// 模块中的错误在import处抛出，可以捕获，出错的模块不缓存，再次导入时重新执行
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine}).Run(filepath.Join(dir, "catch.mk"), `let caught = try { import "fail.mk"; "no error" } catch (e) { e["message"] };
+let again = try { import "fail.mk"; "no error" } catch (e) { e["kind"] };
+[caught, again]`)
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "[type mismatch: INTEGER + BOOLEAN, TypeError]" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+
+	// 原生模块不需要加载文件，可以预编译
+	if _, err := r.Compile("native.mk", `let {sqrt} = import "math"; sqrt(16)`); err != nil {
+		t.Errorf("unexpected compile error: %s", err)
+	}
+	// 字节码不包含导入的模块
+	if _, err := r.CompileFile(filepath.Join(dir, "compile.mk")); err == nil || !strings.HasSuffix(err.Error(), "compile error: 1:11: imports are not supported in compiled scripts, bundle the script first") {
+		t.Errorf("expected a compile error. got=%v", err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	// 两个模块和入口文件都定义了x，合并之后不能冲突
+	writeModules(t, dir, map[string]string{
+		"main.mk": `let {area, x} = import "shapes";
+let counter = import "counter.mk";
+let x = x + 1;
+let lib_x = 0;
+[area(2), x, counter["next"](), counter["next"](), import "shapes"["x"], lib_x]`,
+		"shapes.mk": `let {scale} = import "lib";
+export let x = 10;
+export let area = fn(r) { scale(r * r) };
+export let perimeter = fn(r) { r * 4 };
+export let unused = fn(n) { if (n == 0) { 0 } else { unused(n - 1) } };`,
+		"lib.mk": `let x = 3;
+export let scale = fn(n) { n * x };`,
+		"counter.mk": `let count = [0];
+export let x = "counter";
+export let next = fn() { count[0] + 1 };`,
+	})
+
+	r := New(Options{})
+	bundled, err := r.Bundle(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatalf("Bundle failed: %s", err)
+	}
+	// 没有被用到的导出被删除，被用到的改为普通的let
+	for _, unexpected := range []string{"import", "export", "perimeter", "unused"} {
+		if strings.Contains(bundled, unexpected) {
+			t.Errorf("bundle contains %q:\n%s", unexpected, bundled)
+		}
+	}
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		original, err := New(Options{Engine: engine}).RunFile(filepath.Join(dir, "main.mk"))
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		result, err := New(Options{Engine: engine}).Run("bundle.mk", bundled)
+		if err != nil {
+			t.Fatalf("engine %d: cannot run the bundle: %s\n%s", engine, err, bundled)
+		}
+		if result.Inspect() != original.Inspect() || result.Inspect() != "[12, 11, 1, 1, 10, 0]" {
+			t.Errorf("engine %d: wrong result. original=%s, bundle=%s", engine, original.Inspect(), result.Inspect())
+		}
+	}
+
+	// 合并之后的脚本可以预编译
+	if _, err := r.Compile("bundle.mk", bundled); err != nil {
+		t.Errorf("cannot compile the bundle: %s", err)
+	}
+}
+
+// captureOutput 返回f执行时puts输出的内容
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = write
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	write.Close()
+	data, err := io.ReadAll(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBundleRunsModulesAtImport(t *testing.T) {
+	dir := t.TempDir()
+	// 有副作用的模块在import处执行，只在没有调用的函数中导入的模块不执行，模块中的错误可以在import处捕获
+	writeModules(t, dir, map[string]string{
+		"main.mk": `puts("main start");
+let b = import "./b";
+let r = try { let m = import "./bad"; m["v"] } catch (e) { "caught: " + e["message"] };
+puts("main end");
+[b["v"](), r, import "./pure"["k"]]`,
+		"b.mk":    `puts("b runs"); let lazy = import "./lazy"; export let v = lazy["later"]; export let unused = fn() { 0 };`,
+		"lazy.mk": `let {k} = import "pure"; export let later = fn() { import "./pure"["k"] + k };`,
+		"bad.mk":  `throw error("module failed"); export let v = 1;`,
+		"pure.mk": `export let k = 1;`,
+	})
+
+	bundled, err := New(Options{}).Bundle(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatalf("Bundle failed: %s", err)
+	}
+	if strings.Contains(bundled, "unused") {
+		t.Errorf("bundle contains an unused export:\n%s", bundled)
+	}
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		var original, result object.Object
+		originalOutput := captureOutput(t, func() {
+			original, err = New(Options{Engine: engine}).RunFile(filepath.Join(dir, "main.mk"))
+		})
+		if err != nil {
+			t.Fatalf("engine %d: unexpected error: %s", engine, err)
+		}
+		bundledOutput := captureOutput(t, func() {
+			result, err = New(Options{Engine: engine}).Run("bundle.mk", bundled)
+		})
+		if err != nil {
+			t.Fatalf("engine %d: cannot run the bundle: %s\n%s", engine, err, bundled)
+		}
+
+		if originalOutput != "main start\nb runs\nmain end\n" || bundledOutput != originalOutput {
+			t.Errorf("engine %d: wrong output. original=%q, bundle=%q", engine, originalOutput, bundledOutput)
+		}
+		if original.Inspect() != "[2, caught: module failed, 1]" || result.Inspect() != original.Inspect() {
+			t.Errorf("engine %d: wrong result. original=%s, bundle=%s", engine, original.Inspect(), result.Inspect())
+		}
+	}
+}
+
+func TestBundleRejectsModulesRunMoreThanOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"twice.mk":    `let a = import "log"; let b = import "log"; a == b`,
+		"function.mk": `let f = fn() { import "log" }; 1`,
+		"log.mk":      `puts("log runs"); export let x = 1;`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"twice.mk", `twice.mk: 1:31: cannot bundle "log", a module with side effects imported in more than one place`},
+		{"function.mk", `function.mk: 1:16: cannot bundle "log", a module with side effects imported inside a function`},
+	}
+	for _, tt := range tests {
+		_, err := New(Options{}).Bundle(filepath.Join(dir, tt.file))
+		if err == nil || !strings.HasSuffix(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.file, tt.expected, err)
+		}
+	}
+}
+
+func TestBundleDropsExportsOfBoundImports(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"main.mk": `let u = import "util";
+let tools = import "tools";
+let alias = tools;
+[u["double"](2), fn() { u["first"]() }(), alias["name"]]`,
+		"util.mk": `export let double = fn(x) { x * 2 };
+export let triple = fn(x) { x * 3 };
+export let [first, second] = [fn() { 1 }, 2];
+export let [third, fourth] = [fn() { 3 }, 4];`,
+		"tools.mk": `export let name = "tools";
+export let spare = fn() { 0 };`,
+	})
+
+	bundled, err := New(Options{}).Bundle(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatalf("Bundle failed: %s", err)
+	}
+	// u只用字符串字面量索引，没有用到的导出被删除，包括一个名字都没有用到的解构
+	for _, unexpected := range []string{"triple", "third", "fourth"} {
+		if strings.Contains(bundled, unexpected) {
+			t.Errorf("bundle contains %q:\n%s", unexpected, bundled)
+		}
+	}
+	// tools以其他方式使用，保留全部导出
+	if !strings.Contains(bundled, "spare") {
+		t.Errorf("bundle dropped an export of an import used as a value:\n%s", bundled)
+	}
+
+	for _, engine := range []Engine{Evaluator, VM} {
+		result, err := New(Options{Engine: engine}).Run("bundle.mk", bundled)
+		if err != nil {
+			t.Fatalf("engine %d: cannot run the bundle: %s\n%s", engine, err, bundled)
+		}
+		if result.Inspect() != "[4, 1, tools]" {
+			t.Errorf("engine %d: wrong result. got=%s", engine, result.Inspect())
+		}
+	}
+}
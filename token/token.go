@@ -1,36 +1,55 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type      TokenType
+	Literal   string
+	Line      int        // Line 词法单元所在的行，从1开始
+	Column    int        // Column 词法单元第一个字符所在的列，从1开始
+	Expansion *Expansion // Expansion 由宏展开产生的词法单元记录对应的宏调用，否则为nil
+}
+
+// Expansion 记录一次宏调用。展开结果中的词法单元仍然保留宏定义处的位置，同时通过Expansion指向调用处
+type Expansion struct {
+	Macro string // Macro 宏的名字
+	Call  Token  // Call 宏调用处的词法单元，嵌套展开时它本身也可能带有Expansion
 }
 
 const (
 	ILLEGAL = "ILLEGAL" // ILLEGAL 表示非法字符
 	EOF     = "EOF"     // EOF 表示到达文件末尾
 
-	IDENT = "IDENT" // IDENT 标识量
-	INT   = "INT"   // INT 整型
-
-	ASSIGN   = "="  // ASSIGN 赋值
-	PLUS     = "+"  // PLUS 加号
-	MINUS    = "-"  // MINUS 减号
-	BANG     = "!"  // BANG 感叹号
-	ASTERISK = "*"  // ASTERISK 星号
-	SLASH    = "/"  // SLASH 斜杠
-	LT       = "<"  // LT 小于号
-	GT       = ">"  // GT 大于号
-	EQ       = "==" // EQ 等于号
-	NOT_EQ   = "!=" // NOT_EQ 不等于号
-
-	COMMA     = "," // COMMA 逗号
-	SEMICOLON = ";" // SEMICOLON 分号
-	LPAREN    = "(" // LPAREN 左括号
-	RPAREN    = ")" // RPAREN 右括号
-	LBRACE    = "{" // LBRACE 左花括号
-	RBRACE    = "}" // RBRACE 右花括号
+	IDENT   = "IDENT"   // IDENT 标识量
+	INT     = "INT"     // INT 整型
+	DECIMAL = "DECIMAL" // DECIMAL 精确小数，以d结尾，例如1.25d
+	STRING  = "STRING"  // STRING 字符串
+
+	ASSIGN       = "="  // ASSIGN 赋值
+	PLUS         = "+"  // PLUS 加号
+	MINUS        = "-"  // MINUS 减号
+	BANG         = "!"  // BANG 感叹号
+	ASTERISK     = "*"  // ASTERISK 星号
+	SLASH        = "/"  // SLASH 斜杠
+	DOUBLE_SLASH = "//" // DOUBLE_SLASH 精确除法，结果是分数
+	LT           = "<"  // LT 小于号
+	GT           = ">"  // GT 大于号
+	EQ           = "==" // EQ 等于号
+	NOT_EQ       = "!=" // NOT_EQ 不等于号
+	QUESTION     = "?"  // QUESTION 问号，后缀运算符，用于传播Err和None
+
+	COMMA     = ","   // COMMA 逗号
+	SEMICOLON = ";"   // SEMICOLON 分号
+	COLON     = ":"   // COLON 冒号
+	ELLIPSIS  = "..." // ELLIPSIS 省略号，用于解构中的剩余元素
+	LPAREN    = "("   // LPAREN 左括号
+	RPAREN    = ")"   // RPAREN 右括号
+	LBRACE    = "{"   // LBRACE 左花括号
+	RBRACE    = "}"   // RBRACE 右花括号
+	LBRACKET  = "["   // LBRACKET 左方括号
+	RBRACKET  = "]"   // RBRACKET 右方括号
 
 	FUNCTION = "FUNCTION" // FUNCTION 函数
 	LET      = "LET"      // LET 标识量
@@ -39,16 +58,40 @@ const (
 	RETURN   = "RETURN"   // RETURN
 	TRUE     = "TRUE"     // TRUE
 	FALSE    = "FALSE"    // FALSE
+	TRY      = "TRY"      // TRY
+	CATCH    = "CATCH"    // CATCH
+	FINALLY  = "FINALLY"  // FINALLY
+	THROW    = "THROW"    // THROW
+	MACRO    = "MACRO"    // MACRO 宏
+	IMPORT   = "IMPORT"   // IMPORT 导入模块
+	EXPORT   = "EXPORT"   // EXPORT 导出模块中的变量
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"true":    TRUE,
+	"false":   FALSE,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"macro":   MACRO,
+	"import":  IMPORT,
+	"export":  EXPORT,
+}
+
+// Position 返回词法单元在源代码中的位置，形如 line:column。
+// 由宏展开产生的词法单元还会附上调用处的位置，形如 2:10 (expanded from unless at 7:7)
+func (t Token) Position() string {
+	position := fmt.Sprintf("%d:%d", t.Line, t.Column)
+	if t.Expansion != nil {
+		position += fmt.Sprintf(" (expanded from %s at %s)", t.Expansion.Macro, t.Expansion.Call.Position())
+	}
+	return position
 }
 
 // LookupIdent 判断标识符是否是关键字，关键字包括let、fn等
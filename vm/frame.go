@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"Interp/code"
+	"Interp/object"
+)
+
+// Frame 一次函数调用的执行状态
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+	fromBuiltin bool // fromBuiltin 由内置函数（例如map）发起的调用，与解释器一样不记录在错误的调用栈中
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// position 返回正在执行的指令在源代码中的位置
+func (f *Frame) position() (string, bool) {
+	pos, ok := f.cl.Fn.SourceMap.Lookup(f.ip)
+	return pos.Position, ok
+}
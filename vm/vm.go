@@ -0,0 +1,743 @@
+package vm
+
+import (
+	"Interp/code"
+	"Interp/compiler"
+	"Interp/evaluator"
+	"Interp/object"
+	"fmt"
+)
+
+// 值栈和调用栈按需扩大，上限足以容纳解释器能处理的递归深度，超过时报stack overflow
+const (
+	StackSize    = 2048    // StackSize 值栈的初始大小
+	MaxStackSize = 1 << 24 // MaxStackSize 值栈的上限
+	GlobalsSize  = 65536
+	MaxFrames    = object.MaxCallDepth // MaxFrames 调用深度的上限，与解释器相同
+)
+
+const initialFrames = 64
+
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// handler 由OpSetupTry注册的错误处理器
+type handler struct {
+	frames int // frames 注册时的调用深度，出错时先退出更深的调用
+	sp     int // sp 注册时的栈顶，出错时恢复
+	ip     int // ip 处理错误的指令位置
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // 始终指向栈中下一个空闲的位置，栈顶是stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler
+
+	runtime *object.Runtime
+
+	lastPopped object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, initialFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		runtime: &object.Runtime{},
+	}
+}
+
+// NewWithRuntime 使用指定的运行设置，例如整数溢出的处理方式
+func NewWithRuntime(bytecode *compiler.Bytecode, runtime *object.Runtime) *VM {
+	vm := New(bytecode)
+	vm.runtime = runtime
+	return vm
+}
+
+// NewWithGlobalsStore 沿用之前的全局变量，配合compiler.NewWithState在REPL中使用
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// NewWithGlobalsAndRuntime 沿用之前的全局变量并使用指定的运行设置，用于依次执行共享全局变量的多个模块
+func NewWithGlobalsAndRuntime(bytecode *compiler.Bytecode, s []object.Object, runtime *object.Runtime) *VM {
+	vm := NewWithGlobalsStore(bytecode, s)
+	vm.runtime = runtime
+	return vm
+}
+
+// Global 按名字读取全局变量，用于在模块执行完之后读取它导出的值
+func (vm *VM) Global(name string) (object.Object, bool) {
+	for i, globalName := range vm.globalNames {
+		if globalName == name && vm.globals[i] != nil {
+			return vm.globals[i], true
+		}
+	}
+	return nil, false
+}
+
+// LastPoppedStackElem 返回最后一条表达式语句的值。程序因为未捕获的错误终止时返回该错误
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// Run 执行主程序。未捕获的错误作为程序的结果，通过LastPoppedStackElem返回，与evaluator.Eval一致
+func (vm *VM) Run() (err error) {
+	defer func() {
+		// 只有错误的字节码会导致这里的panic，例如未定义的操作码
+		if r := recover(); r != nil {
+			err = fmt.Errorf("vm: %v", r)
+		}
+	}()
+
+	// 内置函数通过运行设置回到当前的虚拟机中执行闭包，嵌套执行的虚拟机结束后恢复外层的入口
+	previous := vm.runtime.CallClosure
+	vm.runtime.CallClosure = vm.callFromBuiltin
+	defer func() { vm.runtime.CallClosure = previous }()
+
+	if runErr := vm.run(0); runErr != nil {
+		vm.lastPopped = runErr
+	}
+	return nil
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) *object.Error {
+	if vm.framesIndex >= MaxFrames {
+		return newError(object.GENERIC_ERROR, "stack overflow")
+	}
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// run 执行指令直到调用深度回到base，未被处理的错误返回给调用者
+func (vm *VM) run(base int) *object.Error {
+	for vm.framesIndex > base {
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ins := frame.Instructions()
+		if frame.ip >= len(ins) {
+			// 只有主程序会执行到指令的末尾，函数总是以返回指令结束
+			vm.popFrame()
+			continue
+		}
+
+		if err := vm.step(frame, ins); err != nil {
+			if !vm.throw(err, base) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// step 执行ip指向的一条指令
+func (vm *VM) step(frame *Frame, ins code.Instructions) *object.Error {
+	ip := frame.ip
+	op := code.Opcode(ins[ip])
+
+	switch op {
+	case code.OpConstant:
+		constIndex := code.ReadUint16(ins[ip+1:])
+		frame.ip += 2
+		return vm.push(vm.constants[constIndex])
+
+	case code.OpPop:
+		vm.lastPopped = vm.pop()
+
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpExactDiv,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+		right := vm.pop()
+		left := vm.pop()
+		return vm.pushResult(evaluator.EvalInfix(vm.runtime, infixOperators[op], left, right))
+
+	case code.OpMinus:
+		return vm.pushResult(evaluator.EvalPrefix(vm.runtime, "-", vm.pop()))
+
+	case code.OpBang:
+		return vm.pushResult(evaluator.EvalPrefix(vm.runtime, "!", vm.pop()))
+
+	case code.OpTrue:
+		return vm.push(True)
+
+	case code.OpFalse:
+		return vm.push(False)
+
+	case code.OpNull:
+		return vm.push(Null)
+
+	case code.OpJump:
+		pos := int(code.ReadUint16(ins[ip+1:]))
+		frame.ip = pos - 1
+
+	case code.OpJumpNotTruthy:
+		pos := int(code.ReadUint16(ins[ip+1:]))
+		frame.ip += 2
+
+		truthy, err := evaluator.IsTruthy(vm.runtime, vm.pop())
+		if err != nil {
+			return err
+		}
+		if !truthy {
+			frame.ip = pos - 1
+		}
+
+	case code.OpSetGlobal:
+		globalIndex := code.ReadUint16(ins[ip+1:])
+		frame.ip += 2
+		vm.globals[globalIndex] = vm.pop()
+
+	case code.OpGetGlobal:
+		globalIndex := code.ReadUint16(ins[ip+1:])
+		frame.ip += 2
+		value := vm.globals[globalIndex]
+		if value == nil {
+			return newError(object.NAME_ERROR, "identifier not found: %s", vm.globalName(int(globalIndex)))
+		}
+		return vm.push(value)
+
+	case code.OpSetLocal:
+		localIndex := code.ReadUint8(ins[ip+1:])
+		frame.ip += 1
+		slot := frame.basePointer + int(localIndex)
+		// 被闭包捕获的变量保存在Cell中，赋值对闭包可见
+		if cell, ok := vm.stack[slot].(*object.Cell); ok {
+			cell.Value = vm.pop()
+		} else {
+			vm.stack[slot] = vm.pop()
+		}
+
+	case code.OpGetLocal:
+		localIndex := code.ReadUint8(ins[ip+1:])
+		frame.ip += 1
+		value := vm.stack[frame.basePointer+int(localIndex)]
+		if cell, ok := value.(*object.Cell); ok {
+			value = cell.Value
+		}
+		if value == nil {
+			return newError(object.NAME_ERROR, "identifier not found: %s", localName(frame.cl.Fn, int(localIndex)))
+		}
+		return vm.push(value)
+
+	case code.OpGetFree:
+		freeIndex := code.ReadUint8(ins[ip+1:])
+		frame.ip += 1
+		value := frame.cl.Free[freeIndex]
+		if cell, ok := value.(*object.Cell); ok {
+			if cell.Value == nil {
+				return newError(object.NAME_ERROR, "identifier not found: %s", cell.Name)
+			}
+			value = cell.Value
+		}
+		return vm.push(value)
+
+	case code.OpGetFreeIfSet:
+		freeIndex := code.ReadUint8(ins[ip+1:])
+		pos := int(code.ReadUint16(ins[ip+2:]))
+		frame.ip += 3
+		value := frame.cl.Free[freeIndex]
+		if cell, ok := value.(*object.Cell); ok {
+			value = cell.Value
+		}
+		if value != nil {
+			frame.ip = pos - 1
+			return vm.push(value)
+		}
+
+	case code.OpCaptureLocal:
+		localIndex := code.ReadUint8(ins[ip+1:])
+		frame.ip += 1
+		slot := frame.basePointer + int(localIndex)
+		cell, ok := vm.stack[slot].(*object.Cell)
+		if !ok {
+			cell = &object.Cell{Name: localName(frame.cl.Fn, int(localIndex)), Value: vm.stack[slot]}
+			vm.stack[slot] = cell
+		}
+		return vm.push(cell)
+
+	case code.OpCaptureFree:
+		freeIndex := code.ReadUint8(ins[ip+1:])
+		frame.ip += 1
+		return vm.push(frame.cl.Free[freeIndex])
+
+	case code.OpCurrentClosure:
+		return vm.push(frame.cl)
+
+	case code.OpArray:
+		numElements := int(code.ReadUint16(ins[ip+1:]))
+		frame.ip += 2
+
+		elements := make([]object.Object, numElements)
+		copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+		vm.sp = vm.sp - numElements
+		return vm.push(&object.Array{Elements: elements})
+
+	case code.OpHash:
+		numElements := int(code.ReadUint16(ins[ip+1:]))
+		frame.ip += 2
+
+		hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+		if err != nil {
+			return err
+		}
+		vm.sp = vm.sp - numElements
+		return vm.push(hash)
+
+	case code.OpIndex:
+		index := vm.pop()
+		left := vm.pop()
+		return vm.pushResult(evaluator.EvalIndex(left, index))
+
+	case code.OpCall:
+		numArgs := code.ReadUint8(ins[ip+1:])
+		frame.ip += 1
+		return vm.executeCall(int(numArgs))
+
+	case code.OpReturnValue:
+		returnValue := vm.pop()
+		vm.returnFrame()
+		if vm.framesIndex == 0 {
+			// 主程序中的return结束整个程序
+			vm.lastPopped = returnValue
+			return nil
+		}
+		return vm.push(returnValue)
+
+	case code.OpReturn:
+		vm.returnFrame()
+		if vm.framesIndex == 0 {
+			return nil
+		}
+		return vm.push(Null)
+
+	case code.OpClosure:
+		constIndex := code.ReadUint16(ins[ip+1:])
+		numFree := code.ReadUint8(ins[ip+3:])
+		frame.ip += 3
+		return vm.pushClosure(int(constIndex), int(numFree))
+
+	case code.OpThrow:
+		return evaluator.Throw(vm.pop())
+
+	case code.OpSetupTry:
+		pos := int(code.ReadUint16(ins[ip+1:]))
+		frame.ip += 2
+		vm.handlers = append(vm.handlers, handler{frames: vm.framesIndex, sp: vm.sp, ip: pos})
+
+	case code.OpPopTry:
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	case code.OpArrayPattern:
+		source := code.ReadUint16(ins[ip+1:])
+		numElements := code.ReadUint8(ins[ip+3:])
+		hasRest := code.ReadUint8(ins[ip+4:])
+		frame.ip += 4
+		pattern := vm.constants[source].(*object.String).Value
+		return vm.destructureArray(pattern, int(numElements), hasRest == 1)
+
+	case code.OpHashPattern:
+		source := code.ReadUint16(ins[ip+1:])
+		numKeys := code.ReadUint8(ins[ip+3:])
+		frame.ip += 3
+		pattern := vm.constants[source].(*object.String).Value
+		return vm.destructureHash(pattern, int(numKeys))
+
+	case code.OpUnwrap:
+		pos := int(code.ReadUint16(ins[ip+1:]))
+		frame.ip += 2
+		return vm.unwrap(pos)
+
+	case code.OpImport:
+		path := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.String)
+		resolved := vm.constants[code.ReadUint16(ins[ip+3:])].(*object.String)
+		frame.ip += 4
+		return vm.pushResult(evaluator.Import(vm.runtime, path.Value, resolved.Value))
+
+	default:
+		def, err := code.Lookup(byte(op))
+		if err != nil {
+			panic(err)
+		}
+		panic(fmt.Sprintf("opcode %s not implemented", def.Name))
+	}
+
+	return nil
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpExactDiv:    "//",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+// throw 将错误交给最近的处理器，途经的每一层调用都记录在错误的调用栈中。
+// 当前的执行范围内没有处理器时返回false
+func (vm *VM) throw(err *object.Error, base int) bool {
+	if err.Position == "" {
+		err.Position, _ = vm.currentFrame().position()
+	}
+
+	target := base
+	var h *handler
+	if n := len(vm.handlers); n > 0 && vm.handlers[n-1].frames > base {
+		h = &vm.handlers[n-1]
+		target = h.frames
+	}
+
+	for vm.framesIndex > target {
+		frame := vm.popFrame()
+		if vm.framesIndex == 0 || frame.fromBuiltin {
+			continue
+		}
+		// 此时调用者的ip仍然指向OpCall指令
+		caller := vm.currentFrame()
+		callPos, _ := caller.position()
+		err.AddFrame(stackFrame(frame.cl.Fn.Name, callPos), 1)
+		if err.Position == "" {
+			err.Position = callPos
+		}
+	}
+
+	if h == nil {
+		return false
+	}
+
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip - 1
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	// 注册处理器时的栈顶没有超过上限，这里压入错误值不会失败
+	vm.push(evaluator.Catch(err))
+	return true
+}
+
+// returnFrame 退出当前函数，丢弃其中没有注销的错误处理器
+func (vm *VM) returnFrame() {
+	frame := vm.popFrame()
+	for n := len(vm.handlers); n > 0 && vm.handlers[n-1].frames > vm.framesIndex; n-- {
+		vm.handlers = vm.handlers[:n-1]
+	}
+	vm.sp = frame.basePointer - 1
+	if vm.framesIndex == 0 {
+		vm.sp = 0
+	}
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	var err *object.Error
+	name := ""
+	switch callee := callee.(type) {
+	case *object.Closure:
+		err = vm.callClosure(callee, numArgs)
+		name = callee.Fn.Name
+	case *object.Builtin:
+		err = vm.callBuiltin(callee, numArgs)
+		name = callee.Name
+	default:
+		err = newError(object.TYPE_ERROR, "not a function: %s", callee.Type())
+	}
+
+	// 调用本身失败时也记录这一层调用，与解释器一致
+	if err != nil {
+		pos, _ := vm.currentFrame().position()
+		err.AddFrame(stackFrame(name, pos), 1)
+	}
+	return err
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	if err := vm.reserveStack(basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	if err := vm.pushFrame(NewFrame(cl, basePointer)); err != nil {
+		return err
+	}
+
+	// 为局部变量预留空间，清除之前留在栈上的值，读取还没有赋值的变量时报错
+	for i := vm.sp; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(vm.runtime, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	if result == nil {
+		result = Null
+	}
+	return vm.push(result)
+}
+
+// callFromBuiltin 执行内置函数传回的闭包直到它返回，闭包中未处理的错误作为结果返回
+func (vm *VM) callFromBuiltin(cl *object.Closure, args []object.Object) object.Object {
+	base := vm.framesIndex
+	sp := vm.sp
+
+	if err := vm.push(cl); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			vm.sp = sp
+			return err
+		}
+	}
+	if err := vm.callClosure(cl, len(args)); err != nil {
+		vm.sp = sp
+		return err
+	}
+	vm.currentFrame().fromBuiltin = true
+
+	if err := vm.run(base); err != nil {
+		vm.sp = sp
+		return err
+	}
+	return vm.pop()
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		panic(fmt.Sprintf("not a function: %+v", constant))
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free, Globals: vm.globals})
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+// destructureArray 弹出数组，压入剩余元素组成的数组和各个元素，第一个元素在栈顶
+func (vm *VM) destructureArray(pattern string, numElements int, hasRest bool) *object.Error {
+	val := vm.pop()
+	array, ok := val.(*object.Array)
+	if !ok {
+		return newError(object.PATTERN_ERROR, "cannot destructure %s as array", val.Type())
+	}
+	if !hasRest && len(array.Elements) != numElements {
+		return newError(object.PATTERN_ERROR, "array pattern %s expects %d elements, got %d",
+			pattern, numElements, len(array.Elements))
+	}
+	if len(array.Elements) < numElements {
+		return newError(object.PATTERN_ERROR, "array pattern %s expects at least %d elements, got %d",
+			pattern, numElements, len(array.Elements))
+	}
+
+	if hasRest {
+		rest := make([]object.Object, len(array.Elements)-numElements)
+		copy(rest, array.Elements[numElements:])
+		if err := vm.push(&object.Array{Elements: rest}); err != nil {
+			return err
+		}
+	}
+	for i := numElements - 1; i >= 0; i-- {
+		if err := vm.push(array.Elements[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// destructureHash 弹出哈希表和numKeys个键，压入各个键对应的值，第一个键的值在栈顶
+func (vm *VM) destructureHash(pattern string, numKeys int) *object.Error {
+	keys := make([]object.Object, numKeys)
+	copy(keys, vm.stack[vm.sp-numKeys:vm.sp])
+	vm.sp = vm.sp - numKeys
+
+	val := vm.pop()
+	hash, ok := val.(*object.Hash)
+	if !ok {
+		return newError(object.PATTERN_ERROR, "cannot destructure %s as hash", val.Type())
+	}
+
+	values := make([]object.Object, numKeys)
+	for i, key := range keys {
+		value, ok := hash.Get(key.(*object.String))
+		if !ok {
+			return newError(object.PATTERN_ERROR, "key not found in hash pattern %s: %s", pattern, key.Inspect())
+		}
+		values[i] = value
+	}
+	for i := numKeys - 1; i >= 0; i-- {
+		if err := vm.push(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwrap 执行?运算符：Ok和Some替换为内部的值并跳转到pos，Err和None留在栈顶
+func (vm *VM) unwrap(pos int) *object.Error {
+	top := vm.stack[vm.sp-1]
+	switch top := top.(type) {
+	case *object.Result:
+		if !top.IsOk {
+			return nil
+		}
+		vm.stack[vm.sp-1] = top.Value
+	case *object.Option:
+		if !top.IsSome {
+			return nil
+		}
+		vm.stack[vm.sp-1] = top.Value
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s?", top.Type())
+	}
+	vm.currentFrame().ip = pos - 1
+	return nil
+}
+
+func (vm *VM) globalName(index int) string {
+	if index < len(vm.globalNames) {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+func localName(fn *object.CompiledFunction, index int) string {
+	if index < len(fn.LocalNames) {
+		return fn.LocalNames[index]
+	}
+	return fmt.Sprintf("local %d", index)
+}
+
+// pushResult 压入运算的结果，结果是错误时交给调用者处理
+func (vm *VM) pushResult(result object.Object) *object.Error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= len(vm.stack) {
+		if err := vm.reserveStack(vm.sp + 1); err != nil {
+			return err
+		}
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// reserveStack 扩大值栈，使它至少能容纳size个值
+func (vm *VM) reserveStack(size int) *object.Error {
+	if size <= len(vm.stack) {
+		return nil
+	}
+	if size > MaxStackSize {
+		return newError(object.GENERIC_ERROR, "stack overflow")
+	}
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+	if newSize > MaxStackSize {
+		newSize = MaxStackSize
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// stackFrame 描述一次调用，格式与解释器相同
+func stackFrame(name string, pos string) string {
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("%s (%s)", name, pos)
+}
+
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
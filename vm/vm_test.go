@@ -0,0 +1,568 @@
+package vm
+
+import (
+	"Interp/ast"
+	"Interp/compiler"
+	"Interp/evaluator"
+	"Interp/lexer"
+	"Interp/object"
+	"Interp/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	return p.ParseProgram()
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		stackElem := runVm(t, tt.input)
+		testExpectedObject(t, tt.input, tt.expected, stackElem)
+	}
+}
+
+func runVm(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	program := parse(input)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	return vm.LastPoppedStackElem()
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, input, int64(expected), actual)
+	case bool:
+		testBooleanObject(t, input, expected, actual)
+	case string:
+		str, ok := actual.(*object.String)
+		if !ok {
+			t.Errorf("%q: object is not String. got=%T (%+v)", input, actual, actual)
+			return
+		}
+		if str.Value != expected {
+			t.Errorf("%q: object has wrong value. got=%q, want=%q", input, str.Value, expected)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("%q: object not Array: %T (%+v)", input, actual, actual)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("%q: wrong num of elements. want=%d, got=%d", input, len(expected), len(array.Elements))
+			return
+		}
+		for i, expectedElem := range expected {
+			testIntegerObject(t, input, int64(expectedElem), array.Elements[i])
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%q: object is not Null: %T (%+v)", input, actual, actual)
+		}
+	case *object.Error:
+		errObj, ok := actual.(*object.Error)
+		if !ok {
+			t.Errorf("%q: object is not Error: %T (%+v)", input, actual, actual)
+			return
+		}
+		if errObj.Message != expected.Message {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", input, expected.Message, errObj.Message)
+		}
+	}
+}
+
+func testIntegerObject(t *testing.T, input string, expected int64, actual object.Object) {
+	t.Helper()
+	result, ok := actual.(*object.Integer)
+	if !ok {
+		t.Errorf("%q: object is not Integer. got=%T (%+v)", input, actual, actual)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("%q: object has wrong value. got=%d, want=%d", input, result.Value, expected)
+	}
+}
+
+func testBooleanObject(t *testing.T, input string, expected bool, actual object.Object) {
+	t.Helper()
+	result, ok := actual.(*object.Boolean)
+	if !ok {
+		t.Errorf("%q: object is not Boolean. got=%T (%+v)", input, actual, actual)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("%q: object has wrong value. got=%t, want=%t", input, result.Value, expected)
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"1 / 0", &object.Error{Message: "division by zero"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1 > 2) { 10 }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { let a = 1; }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let a = 1; let a = a + 1; a", 2},
+		{"missing", &object.Error{Message: "identifier not found: missing"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+		{`{"a": 1, "b": 2}["b"]`, 2},
+		{`{[1]: 2}`, &object.Error{Message: "unusable as hash key: ARRAY"}},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`1[0]`, &object.Error{Message: "index operator not supported: INTEGER"}},
+		{`[1, 2][99999999999999999999]`, Null},
+		{`[1, 2][1.5d]`, &object.Error{Message: "array index must be an integer, got DECIMAL"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { }; f();", Null},
+		{"let f = fn() { 24 }; let g = fn() { f() + 1 }; g();", 25},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{"let g = fn() { later() }; let later = fn() { 7 }; g();", 7},
+		{"fn(a) { a }(1, 2)", &object.Error{Message: "wrong number of arguments: want=1, got=2"}},
+		{"1(2)", &object.Error{Message: "not a function: INTEGER"}},
+		{"fn(a, a) { a }(1, 2)", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosuresAndRecursion(t *testing.T) {
+	tests := []vmTestCase{
+		{`let newAdderOuter = fn(a, b) {
+			let c = a + b;
+			fn(d) { let e = d + c; fn(f) { e + f; }; };
+		};
+		let newAdderInner = newAdderOuter(1, 2);
+		let adder = newAdderInner(3);
+		adder(8);`, 14},
+		{`let fibonacci = fn(x) {
+			if (x == 0) { return 0; }
+			if (x == 1) { return 1; }
+			fibonacci(x - 1) + fibonacci(x - 2);
+		};
+		fibonacci(15);`, 610},
+		{`let wrapper = fn() {
+			let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1); };
+			countDown(1);
+		};
+		wrapper();`, 0},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		even(10);`, true},
+		{"let f = fn(x) { f(x + 1) }; f(0);", &object.Error{Message: "stack overflow"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTryAcrossFrames(t *testing.T) {
+	tests := []vmTestCase{
+		{`let f = fn() { throw "x"; 1 };
+		let g = fn() { try { f() } catch (e) { e["message"] } };
+		g() + "!";`, "x!"},
+		{`let f = fn(x) { try { x } finally { 0 } };
+		f(1) + f(2) + [f(3), f(4)][1];`, 7},
+		{`let safe = fn(g) { try { g() } catch (e) { -1 } };
+		safe(fn() { 1 / 0 }) + safe(fn() { 5 });`, 4},
+		{`let r = try { map(Ok(1), fn(x) { throw x + 1 }) } catch (e) { e["value"] }; r;`, 2},
+		{`let f = fn() { try { throw 1; } catch (e) { return e["value"] + 1; } finally { 10 } }; f();`, 2},
+		{`let f = fn() { try { 1 } finally { throw 3; } }; try { f() } catch (e) { e["value"] }`, 3},
+		{`unwrap_or(map(Ok(1), fn(x) { try { throw x } catch (e) { e["value"] * 5 } }), 0)`, 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+// describe 描述一个结果，用于比较虚拟机和解释器。错误同时比较种类、位置和调用栈
+func describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "<nil>"
+	case *object.Error:
+		return fmt.Sprintf("%s [%s] %v", obj.Inspect(), obj.Kind, obj.Stack)
+	case *object.Function, *object.Closure:
+		return "function"
+	default:
+		return obj.Inspect()
+	}
+}
+
+// evaluatorInputs 解释器测试中的所有程序，虚拟机必须给出相同的结果
+var evaluatorInputs = []string{
+	"true",
+	"false",
+	"1 < 2",
+	"1 > 2",
+	"1 < 1",
+	"1 > 1",
+	"1 == 1",
+	"1 != 1",
+	"1 == 2",
+	"1 != 2",
+	"true == true",
+	"false == false",
+	"true == false",
+	"true != false",
+	"false != true",
+	"(1 < 2) == true",
+	"(1 < 2) == false",
+	"(1 > 2) == true",
+	"(1 > 2) == false",
+	"!true",
+	"!false",
+	"!5",
+	"!!5",
+	"!!true",
+	"!!false",
+	"if(true){10}",
+	"if(false){10}",
+	"if(1){10}",
+	"if(1<2){10}",
+	"if(1>2){10}",
+	"if(1>2){10}else{20}",
+	"if(1<2){10}else{20}",
+	"return 10;",
+	"return 10; 9;",
+	"return 2*5; 9;",
+	"9; return 2*5; 9;",
+	`
+		if(10>1){
+			if(10>1){
+				return 10;
+			}
+			return 1;
+		}
+		`,
+	"5+true;",
+	"5+true; 5;",
+	"-true;",
+	"true+false;",
+	"5; true+false; 5",
+	"if(10>1){true+false;}",
+	`
+			if(10>1){
+				if(10>1){
+					return true+false;
+				}
+				return 1;
+			}
+			`,
+	"foobar",
+	"let a=5; a;",
+	"let a=5*5; a;",
+	"let a=5; let b=a; b;",
+	"let a=5; let b=a; let c=a+b+5; c;",
+	"let identity=fn(x){x;}; identity(5);",
+	"let identity=fn(x){return x;}; identity(5);",
+	"let double=fn(x){x*2;}; double(5);",
+	"let add=fn(x,y){x+y;}; add(5,5);",
+	"let add=fn(x,y){x+y;}; add(5+5,add(5,5));",
+	"fn(x){x;}(5)",
+	"[1, 2, 3][0]",
+	"[1, 2, 3][2]",
+	"let i = 0; [1][i];",
+	"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+	"[1, 2, 3][3]",
+	"[1, 2, 3][-1]",
+	`{"foo": 5}["foo"]`,
+	`{"foo": 5}["bar"]`,
+	`let key = "foo"; {"foo": 5}[key]`,
+	`{5: 5}[5]`,
+	`{true: 5}[true]`,
+	`{"a": 1, "a": 2}["a"]`,
+	"let [a, b] = [1, 2]; a * 10 + b;",
+	"let [a, ...rest] = [1, 2, 3]; rest[0] + rest[1];",
+	"let [a, ...rest] = [1]; rest[0] == rest[0]; a;",
+	`let {name, age: years} = {"name": 3, "age": 40}; name + years;`,
+	`let {pos: [x, y]} = {"pos": [4, 5], "extra": 0}; x * y;`,
+	`let [{v}, [w]] = [{"v": 1}, [2]]; v + w;`,
+	"let add = fn([a, b]) { a + b }; add([3, 4]);",
+	`let f = fn({x, y}, z) { x * y + z }; f({"x": 2, "y": 3}, 1);`,
+	"let head = fn([h, ...t]) { h }; head([9, 8, 7]);",
+	"let [a, b] = 5;",
+	"let [a, b] = [1, 2, 3];",
+	"let [a, b, ...c] = [1];",
+	`let {name} = [1];`,
+	`let {name} = {"age": 1};`,
+	"let f = fn([a]) { a }; f([1, 2]);",
+	"let f = fn(a, b) { a }; f(1);",
+	`len("")`,
+	`len("four")`,
+	`len([1, 2, 3])`,
+	`len({"a": 1})`,
+	`len(1)`,
+	`len("one", "two")`,
+	`first([1, 2, 3])`,
+	`first([])`,
+	`last([1, 2, 3])`,
+	`rest([1, 2, 3])[1]`,
+	`rest([])`,
+	`push([], 1)[0]`,
+	`push(1, 1)`,
+	`first(1)`,
+	`try { 1 } catch (e) { 2 }`,
+	`try { throw 5; 1 } catch (e) { e["value"] }`,
+	`try { throw "bad"; } catch (e) { e["message"] }`,
+	`try { throw "bad"; } catch (e) { e["kind"] }`,
+	`try { foo } catch (e) { e["kind"] }`,
+	`try { 1 + true } catch (e) { e["message"] }`,
+	`try { 1 / 0 } catch (e) { e["kind"] }`,
+	`try { len(1) } catch (e) { e["kind"] }`,
+	`try { let {a} = {}; } catch (e) { e["kind"] }`,
+	`try { throw error("no key", "KeyError"); } catch (e) { e["kind"] + ": " + e["message"] }`,
+	`try { throw error("x"); } catch (e) { e["value"] }`,
+	`let a = try { throw 1; } catch (e) { 10 } finally { 20 }; a;`,
+	`let f = fn() { try { return 1; } finally { 2 } }; f();`,
+	`let f = fn() { try { return 1; } finally { return 2; } }; f();`,
+	`let f = fn() { try { throw 1; } finally { return 3; } }; f();`,
+	`try { try { throw 1; } finally { 0 } } catch (e) { e["value"] + 1 }`,
+	`try { try { throw 1; } catch (e) { throw e; } } catch (e) { e["value"] + 10 }`,
+	`try { try { throw 1; } catch (e) { throw 2; } } catch (e) { e["value"] }`,
+	`try { throw 1; } catch (e) { 1 }; e;`,
+	`Ok(1)`,
+	`Err("bad")`,
+	`Some(2)`,
+	`None()`,
+	`map(Ok(1), fn(x) { x + 1 })`,
+	`map(Err(1), fn(x) { x + 1 })`,
+	`map(Some(1), fn(x) { x * 3 })`,
+	`map(None(), fn(x) { x * 3 })`,
+	`and_then(Ok(1), fn(x) { Err(x) })`,
+	`and_then(Err(0), fn(x) { Ok(x) })`,
+	`and_then(Some(4), fn(x) { Some(x * x) })`,
+	`unwrap_or(Ok(1), 2)`,
+	`unwrap_or(Err(1), 2)`,
+	`unwrap_or(None(), 3)`,
+	`is_ok(Ok(1))`,
+	`is_ok(Err(1))`,
+	`is_some(None())`,
+	`and_then(Ok(1), fn(x) { x })`,
+	`is_ok(1)`,
+	`map(Ok(1), fn(x) { x + true })`,
+	`let f = fn(r) { r? + 1 }; f(Ok(1));`,
+	`let f = fn(r) { r? + 1 }; f(Err("bad"));`,
+	`let f = fn(o) { let v = o?; Some(v * 2) }; f(Some(2));`,
+	`let f = fn(o) { let v = o?; Some(v * 2) }; f(None());`,
+	`let parse = fn(x) { if (x > 0) { Ok(x) } else { Err("negative") } };
+	let sum = fn(a, b) { Ok(parse(a)? + parse(b)?) };
+	sum(1, 2);`,
+	`let parse = fn(x) { if (x > 0) { Ok(x) } else { Err("negative") } };
+	let sum = fn(a, b) { Ok(parse(a)? + parse(b)?) };
+	sum(1, -2);`,
+	`let f = fn(r) { [r?, 1] }; f(Err(0));`,
+	`let f = fn(r) { try { r? } finally { 1 } }; f(Err(0));`,
+	`Err(1)?; 2;`,
+	`1?`,
+	"fn(x){x+2;};",
+	`
+		let newAdder=fn(x){
+			fn(y){x+y;};
+		};
+		let addTwo=newAdder(2);
+		addTwo(2);
+		`,
+	`let inner = fn() { throw "deep"; };
+	let outer = fn() { inner() };
+	try {
+		outer();
+	} catch (e) {
+		e["stack"]
+	}`,
+	`"Hello" + " " + "World!"`,
+	`throw "boom"; 1;`,
+	"9223372036854775807 + 1",
+	"-(-9223372036854775807 - 1)",
+	"(4294967296 * 4294967296) / 4294967296",
+	"9223372036854775807 + 1 > 9223372036854775807",
+	`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`,
+	"1.25d + 2",
+	"2d / 3",
+	"-(10.00d / 4)",
+	"1 // 3 + 1 // 6",
+	"6 // 3",
+	"1.5d == 3 // 2",
+	"1 // 0",
+	"decimal(2 // 3, 2)",
+	`[1, [2, "a"]] == [1, [2, "a"]]`,
+	`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`,
+	`let f = fn(x) { x }; [f == f, fn(x) { x } == fn(x) { x }, len == len]`,
+	`["apple" < "banana", [1, 2] < [1, 3], Err(5) < Ok(1)]`,
+	`1 < "a"`,
+	`sort([1 // 2, 0.25d, 1, -3, "a", true])`,
+	`let h = fn(n) { if (n > 0) { let y = n; 0 }; y }; [h(1), try { h(0) } catch (e) { e["message"] }]`,
+	`let h = fn(n) { if (n > 0) { let y = n; 0 }; y }; h(0)`,
+	`let fail = fn(n) { if (n == 0) { 1 + true } else { fail(n - 1) } }; fail(3)`,
+	`let fail = fn(n) { if (n == 0) { 1 + true } else { [fail(n - 1)] } }; try { fail(5) } catch (e) { e["stack"] }`,
+	`let g = fn() { let ev = fn(n) { if (n == 0) { true } else { od(n - 1) } }; let od = fn(n) { if (n == 0) { false } else { ev(n - 1) } }; ev(4) }; g()`,
+	`let mk = fn() { let a = 1; let g = fn() { a + b }; let b = 2; g }; mk()()`,
+	`let x = 1; let f = fn() { let g = fn() { x }; let a = try { g() } catch (e) { e["message"] }; let x = 2; [a, g()] }; f()`,
+	`let x = 1; let f = fn() { let g = fn() { fn() { x } }; let x = 2; g()() }; f()`,
+	`let x = 1; let f = fn() { let g = fn() { x }; let r = try { g() } catch (e) { e["message"] }; let x = 2; r }; f()`,
+	`let x = 1; let f = fn() { let g = fn() { fn() { x } }; let a = g()(); let x = 2; [a, g()(), x] }; f()`,
+	`let f = fn(x) { fn() { let g = fn() { x }; let a = g(); let x = a + 1; [a, g()] } }; f(1)()`,
+	`let f = fn() { let g = fn() { len }; let a = g()([1]); let len = 5; [a, g()] }; f()`,
+	`let f = fn() { let g = fn() { y }; let a = try { g() } catch (e) { e["message"] }; let y = 2; [a, g()] }; let y = 7; f()`,
+	`let f = fn() { let g = fn() { h() }; let h = fn() { k }; let r = try { g() } catch (e) { e["kind"] }; let k = 3; [r, g()] }; f()`,
+	`let f = fn(n) { let g = fn() { n + m }; let m = n * 2; let m = m + 1; g() }; f(5)`,
+	`let s = fn(n) { if (n == 0) { 0 } else { 1 + s(n - 1) } }; s(20000)`,
+	// 不在尾位置的递归在同样的深度报stack overflow，可以被try捕获
+	`let f = fn(n) { 1 + f(n + 1) }; f(0)`,
+	`let f = fn(n) { 1 + f(n + 1) }; try { f(0) } catch (e) { [e["message"], e["kind"], len(e["stack"]) > 0] }`,
+	fmt.Sprintf(`let s = fn(n) { if (n == 0) { 0 } else { 1 + s(n - 1) } }; s(%d)`, object.MaxCallDepth-2),
+	fmt.Sprintf(`let s = fn(n) { if (n == 0) { 0 } else { 1 + s(n - 1) } }; try { s(%d) } catch (e) { e["message"] }`, object.MaxCallDepth-1),
+	`[fn(x) { x } == fn(x) { x }, fn(x) { x } == fn(y) { y }]`,
+	`[9223372036854775808, -9223372036854775808, 18446744073709551616 - 18446744073709551615, 36893488147419103232 // 3]`,
+	`let mk = fn(n) { fn(x) { x + n } }; let s = sort([mk(3), mk(1), mk(2)]); [mk(1) == mk(1), mk(1) == mk(2), s[0](0), s[1](0), s[2](0)]`,
+	`let k = 1; let mk = fn(n) { fn() { k + n } }; [mk(1) == mk(1), mk(1) == mk(2), mk(1) != mk(2)]`,
+	`let mk = fn(n) { fn(x) { let n = 5; x + n } }; mk(1) == mk(2)`,
+	`let mk = fn() { let f = fn() { f }; f }; mk() == mk()`,
+	`let mk = fn(n) { let g = fn() { fn() { n + m } }; let m = n; g() }; [mk(1) == mk(1), mk(1) == mk(2)]`,
+	`let f = fn(x) { x }; [push([], f)[0] == f, Ok(f) == Ok(f), first([f])(3)]`,
+	`[fn(x) { x }, Some(fn() { 1 })]`,
+	`let f = fn(x) { x }; unwrap_or(map(Some(f), fn(g) { g }), 0) == f`,
+	`try { import "json"["stringify"](fn(x) { x }) } catch (e) { e["message"] }`,
+	`let build = fn(n) { if (n == 0) { [] } else { push(build(n - 1), n) } }; let sum = fn(xs) { if (len(xs) == 0) { 0 } else { let [x, ...more] = xs; x + sum(more) } }; sum(build(5000))`,
+}
+
+func TestMatchesEvaluator(t *testing.T) {
+	testMatchesEvaluatorWithRuntime(t, &object.Runtime{}, evaluatorInputs)
+}
+
+func TestDeterministicMatchesEvaluator(t *testing.T) {
+	inputs := []string{
+		`let f = fn(x) { x }; sort([Some(f), Some(f)])`,
+		`let mk = fn(n) { fn() { n } }; sort([mk(2), mk(1)])[0]()`,
+	}
+	testMatchesEvaluatorWithRuntime(t, &object.Runtime{Deterministic: true}, inputs)
+}
+
+func TestIntegerModesMatchEvaluator(t *testing.T) {
+	inputs := []string{
+		"9223372036854775807 + 1",
+		"4294967296 * -4294967296",
+		"-(-9223372036854775807 - 1)",
+		"(-9223372036854775807 - 1) / -1",
+		"9223372036854775807 - 1",
+		"try { 9223372036854775807 + 1 } catch (e) { e[\"kind\"] }",
+	}
+	for _, mode := range []object.IntegerMode{object.IntegerPromote, object.IntegerWrap, object.IntegerChecked} {
+		testMatchesEvaluatorWithRuntime(t, &object.Runtime{IntegerMode: mode}, inputs)
+	}
+}
+
+func TestTruthinessMatchesEvaluator(t *testing.T) {
+	inputs := []string{
+		`[if (0) { "yes" } else { "no" }, if ("") { "yes" } else { "no" }, if ([]) { "yes" } else { "no" }]`,
+		`[!0, !1, !"", !"a", ![], ![1], !{}, !0.0d, !(1 // 2)]`,
+		`if (1 > 0) { "yes" }`,
+		`let f = fn() { if (1) { "yes" } }; f()`,
+		`let n = if (false) { 1 }; !n`,
+		`try { !5 } catch (e) { e["message"] }`,
+	}
+	modes := []object.Truthiness{object.TruthinessDefault, object.TruthinessFalsyEmpty, object.TruthinessStrict}
+	for _, mode := range modes {
+		testMatchesEvaluatorWithRuntime(t, &object.Runtime{Truthiness: mode}, inputs)
+	}
+}
+
+func TestNativeModulesMatchEvaluator(t *testing.T) {
+	inputs := []string{
+		`let {sqrt, pow, gcd} = import "math"; [sqrt(2), pow(2, 70), pow(2, -3), gcd(12, 18)]`,
+		`let math = import "math"; let f = fn(x) { math["clamp"](x, 0, 10) }; [f(-5), f(5), f(50)]`,
+		`try { import "math"["sqrt"](-1) } catch (e) { e["message"] }`,
+		`let {split, join, upper, len} = import "strings"; let words = split("grüße 世界", " "); [len(words[0]), upper(join(words, "-"))]`,
+		`let s = import "strings"; s["substring"]("日本語", 1, 2) + s["from_char_codes"](s["char_codes"]("é"))`,
+		`let {parse, stringify} = import "json"; let v = parse("{\"a\": [1, 2.5, \"é\"]}"); [v["a"][1] + 1, stringify(v, 1)]`,
+		`try { import "json"["stringify"]([{1: 2}]) } catch (e) { e["message"] }`,
+		`let {now, add, diff, in_zone, format} = import "time"; let later = add(now(), 90000); [later, diff(later, now()), format(in_zone(later, "Asia/Tokyo"), "Kitchen"), later > now()]`,
+		`let {seed, int, shuffle, sample} = import "random"; seed(5); let f = fn() { int(1, 100) }; [f(), f(), shuffle([1, 2, 3, 4]), sample(["a", "b", "c"], 2)]`,
+	}
+	clock := object.FixedClock{Time: time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)}
+	testMatchesEvaluatorWithRuntime(t, &object.Runtime{Clock: clock}, inputs)
+}
+
+// testMatchesEvaluatorWithRuntime 在同样的运行设置下比较解释器和虚拟机的结果
+func testMatchesEvaluatorWithRuntime(t *testing.T, rt *object.Runtime, inputs []string) {
+	t.Helper()
+
+	for _, input := range inputs {
+		program := parse(input)
+		evaluator.NewResolver().Resolve(program)
+		expected := describe(evaluator.Eval(program, object.NewEnvironmentWithRuntime(rt)))
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error for %q: %s", input, err)
+		}
+		vm := NewWithRuntime(comp.Bytecode(), rt)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error for %q: %s", input, err)
+		}
+		if actual := describe(vm.LastPoppedStackElem()); actual != expected {
+			t.Errorf("runtime %+v: result differs for %q.\nevaluator=%s\nvm=%s", *rt, input, expected, actual)
+		}
+	}
+}